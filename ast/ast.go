@@ -112,6 +112,7 @@ func (es *ExpressionStatement) String() string {
 type BlockStatement struct {
 	Token      token.Token // the { token
 	Statements []Statement
+	Rbrace     token.Token // the } token
 }
 
 func (bs *BlockStatement) statementNode()       {}
@@ -225,8 +226,10 @@ func (ce *CallExpression) String() string {
 	return out.String()
 }
 
+// IndexExpression is an index such as a[1], or a?[1], which gives null
+// instead of an error when a is null.
 type IndexExpression struct {
-	Token token.Token // the '[' token
+	Token token.Token // the '[' or '?[' token
 	Left  Expression
 	Index Expression
 }
@@ -238,6 +241,9 @@ func (ie *IndexExpression) String() string {
 
 	out.WriteString("(")
 	out.WriteString(ie.Left.String())
+	if ie.Token.Type == token.OPTIONAL_LBRACKET {
+		out.WriteString("?")
+	}
 	out.WriteString("[")
 	out.WriteString(ie.Index.String())
 	out.WriteString("])")
@@ -275,6 +281,34 @@ func (fl *FunctionLiteral) String() string {
 	return out.String()
 }
 
+// MacroLiteral is a makro definition. Its body is run while macros are
+// expanded, before the program runs, and must return a sitat.
+type MacroLiteral struct {
+	Token      token.Token // the 'makro' token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (ml *MacroLiteral) expressionNode()      {}
+func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+
+	for _, p := range ml.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString(ml.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(ml.Body.String())
+
+	return out.String()
+}
+
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
@@ -289,6 +323,31 @@ func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 
+// InterpolatedString is a string with expressions in it, such as
+// "Hei {navn}!". Parts alternates between the text around the expressions,
+// as *StringLiteral, and the expressions, so it starts and ends with text,
+// which may be empty.
+type InterpolatedString struct {
+	Token token.Token // the STRING_START token
+	Parts []Expression
+}
+
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	for i, part := range is.Parts {
+		if i%2 == 0 {
+			out.WriteString(part.String())
+		} else {
+			out.WriteString("{" + part.String() + "}")
+		}
+	}
+
+	return out.String()
+}
+
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
 	Elements []Expression
@@ -313,7 +372,13 @@ func (al *ArrayLiteral) String() string {
 
 type HashLiteral struct {
 	Token token.Token // the '{' token
-	Pairs map[Expression]Expression
+	Pairs []HashPair  // in the order they were written
+}
+
+// HashPair is a key and its value in a hash literal.
+type HashPair struct {
+	Key   Expression
+	Value Expression
 }
 
 func (hl *HashLiteral) expressionNode()      {}
@@ -323,8 +388,8 @@ func (hl *HashLiteral) String() string {
 
 	pairs := []string{}
 
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+":"+pair.Value.String())
 	}
 
 	out.WriteString("{")
@@ -342,3 +407,11 @@ type Boolean struct {
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
 func (b *Boolean) String() string       { return b.Token.Literal }
+
+type NullLiteral struct {
+	Token token.Token
+}
+
+func (nl *NullLiteral) expressionNode()      {}
+func (nl *NullLiteral) TokenLiteral() string { return nl.Token.Literal }
+func (nl *NullLiteral) String() string       { return nl.Token.Literal }
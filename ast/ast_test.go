@@ -3,6 +3,12 @@
 package ast
 
 import (
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"io/fs"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/solbero/pytonskript/token"
@@ -29,3 +35,384 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong, got %q", program.String())
 	}
 }
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return &IntegerLiteral{Value: 1} }
+	two := func() Expression { return &IntegerLiteral{Value: 2} }
+
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok {
+			return node
+		}
+		if integer.Value != 1 {
+			return node
+		}
+		integer.Value = 2
+		return integer
+	}
+
+	tests := []struct {
+		input    Node
+		expected Node
+	}{
+		{one(), two()},
+		{
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			&Program{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+		},
+		{
+			&InfixExpression{Left: one(), Operator: "+", Right: two()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&InfixExpression{Left: two(), Operator: "+", Right: one()},
+			&InfixExpression{Left: two(), Operator: "+", Right: two()},
+		},
+		{
+			&PrefixExpression{Operator: "-", Right: one()},
+			&PrefixExpression{Operator: "-", Right: two()},
+		},
+		{
+			&IndexExpression{Left: one(), Index: one()},
+			&IndexExpression{Left: two(), Index: two()},
+		},
+		{
+			&IfExpression{
+				Condition:   one(),
+				Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+				Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}},
+			},
+			&IfExpression{
+				Condition:   two(),
+				Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+				Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}},
+			},
+		},
+		{&ReturnStatement{ReturnValue: one()}, &ReturnStatement{ReturnValue: two()}},
+		{
+			&LetStatement{Name: &Identifier{Value: "x"}, Value: one()},
+			&LetStatement{Name: &Identifier{Value: "x"}, Value: two()},
+		},
+		{
+			&FunctionLiteral{Parameters: []*Identifier{}, Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}}},
+			&FunctionLiteral{Parameters: []*Identifier{}, Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}}},
+		},
+		{
+			&MacroLiteral{Parameters: []*Identifier{}, Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: one()}}}},
+			&MacroLiteral{Parameters: []*Identifier{}, Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: two()}}}},
+		},
+		{
+			&CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{one(), one()}},
+			&CallExpression{Function: &Identifier{Value: "f"}, Arguments: []Expression{two(), two()}},
+		},
+		{&ArrayLiteral{Elements: []Expression{one(), one()}}, &ArrayLiteral{Elements: []Expression{two(), two()}}},
+		{
+			&HashLiteral{Pairs: []HashPair{{Key: one(), Value: one()}, {Key: one(), Value: one()}}},
+			&HashLiteral{Pairs: []HashPair{{Key: two(), Value: two()}, {Key: two(), Value: two()}}},
+		},
+	}
+
+	for _, tt := range tests {
+		modified := Modify(tt.input, turnOneIntoTwo)
+		if !reflect.DeepEqual(modified, tt.expected) {
+			t.Errorf("not equal, got %#v, want %#v", modified, tt.expected)
+		}
+	}
+}
+
+func TestModifyRenames(t *testing.T) {
+	rename := func(node Node) Node {
+		if ident, ok := node.(*Identifier); ok && ident.Value == "x" {
+			return &Identifier{Value: "y"}
+		}
+		return node
+	}
+
+	program := &Program{Statements: []Statement{
+		&LetStatement{Name: &Identifier{Value: "x"}, Value: &InfixExpression{
+			Left:     &Identifier{Value: "x"},
+			Operator: "+",
+			Right:    &IntegerLiteral{Value: 1},
+		}},
+		&ExpressionStatement{Expression: &FunctionLiteral{
+			Parameters: []*Identifier{{Value: "x"}},
+			Body:       &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: &Identifier{Value: "x"}}}},
+		}},
+	}}
+
+	Modify(program, rename)
+
+	let := program.Statements[0].(*LetStatement)
+	if let.Name.Value != "y" {
+		t.Errorf("the la binding was not renamed, got %q", let.Name.Value)
+	}
+	if left := let.Value.(*InfixExpression).Left.(*Identifier); left.Value != "y" {
+		t.Errorf("the value was not renamed, got %q", left.Value)
+	}
+
+	fn := program.Statements[1].(*ExpressionStatement).Expression.(*FunctionLiteral)
+	if fn.Parameters[0].Value != "y" {
+		t.Errorf("the parameter was not renamed, got %q", fn.Parameters[0].Value)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	block := func(exp Expression) *BlockStatement {
+		return &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: exp}}}
+	}
+
+	program := &Program{Statements: []Statement{
+		&LetStatement{Name: &Identifier{Value: "f"}, Value: &FunctionLiteral{
+			Parameters: []*Identifier{{Value: "x"}},
+			Body: block(&IfExpression{
+				Condition:   &Boolean{Value: true},
+				Consequence: block(&InfixExpression{Left: &Identifier{Value: "x"}, Operator: "+", Right: &IntegerLiteral{Value: 1}}),
+			}),
+		}},
+		&ReturnStatement{ReturnValue: &CallExpression{
+			Function: &Identifier{Value: "f"},
+			Arguments: []Expression{
+				&ArrayLiteral{Elements: []Expression{&StringLiteral{Value: "a"}}},
+				&HashLiteral{Pairs: []HashPair{{Key: &IntegerLiteral{Value: 1}, Value: &PrefixExpression{Operator: "-", Right: &IntegerLiteral{Value: 1}}}}},
+				&IndexExpression{Left: &Identifier{Value: "a"}, Index: &IntegerLiteral{Value: 1}},
+			},
+		}},
+	}}
+
+	copied := Copy(program)
+	if copied == Node(program) || copied.String() != program.String() {
+		t.Fatalf("copy differs, got %q, want %q", copied.String(), program.String())
+	}
+
+	Modify(copied, func(node Node) Node {
+		if integer, ok := node.(*IntegerLiteral); ok {
+			integer.Value = 2
+		}
+		if ident, ok := node.(*Identifier); ok {
+			ident.Value = "y"
+		}
+		return node
+	})
+
+	if program.String() == copied.String() {
+		t.Errorf("changing the copy changed the original, got %q", program.String())
+	}
+}
+
+// nodeSamples has a node of every type, with every child set, so that the
+// tests below reach every case of Walk, Modify and Copy.
+func nodeSamples() []Node {
+	ident := func(name string) *Identifier { return &Identifier{Value: name} }
+	integer := func(value int64) *IntegerLiteral { return &IntegerLiteral{Value: value} }
+	block := func(exp Expression) *BlockStatement {
+		return &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: exp}}}
+	}
+
+	return []Node{
+		&Program{Statements: []Statement{&ExpressionStatement{Expression: ident("a")}}},
+		&LetStatement{Name: ident("a"), Value: integer(1)},
+		&ReturnStatement{ReturnValue: integer(1)},
+		&ExpressionStatement{Expression: integer(1)},
+		block(integer(1)),
+		ident("a"),
+		integer(1),
+		&StringLiteral{Value: "a"},
+		&InterpolatedString{Parts: []Expression{&StringLiteral{Value: "a"}, ident("b"), &StringLiteral{Value: "c"}}},
+		&Boolean{Value: true},
+		&NullLiteral{},
+		&ArrayLiteral{Elements: []Expression{integer(1), integer(2)}},
+		&HashLiteral{Pairs: []HashPair{{Key: integer(1), Value: integer(2)}}},
+		&PrefixExpression{Operator: "-", Right: integer(1)},
+		&InfixExpression{Left: integer(1), Operator: "+", Right: integer(2)},
+		&IfExpression{Condition: ident("a"), Consequence: block(integer(1)), Alternative: block(integer(2))},
+		&IndexExpression{Left: ident("a"), Index: integer(1)},
+		&CallExpression{Function: ident("f"), Arguments: []Expression{integer(1)}},
+		&FunctionLiteral{Parameters: []*Identifier{ident("x")}, Body: block(ident("x"))},
+		&MacroLiteral{Parameters: []*Identifier{ident("x")}, Body: block(ident("x"))},
+	}
+}
+
+// TestEveryNodeType finds the node types in the source of the package, and
+// fails if nodeSamples, and so Walk, Modify and Copy, miss any of them.
+func TestEveryNodeType(t *testing.T) {
+	fset := gotoken.NewFileSet()
+	packages, err := goparser.ParseDir(fset, ".", func(info fs.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatalf("could not parse the package: %s", err)
+	}
+
+	declared := map[string]bool{}
+	for _, file := range packages["ast"].Files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*goast.FuncDecl)
+			if !ok || fn.Recv == nil || fn.Name.Name != "TokenLiteral" {
+				continue
+			}
+			if star, ok := fn.Recv.List[0].Type.(*goast.StarExpr); ok {
+				declared[star.X.(*goast.Ident).Name] = true
+			}
+		}
+	}
+
+	if len(declared) == 0 {
+		t.Fatalf("found no node types")
+	}
+
+	sampled := map[string]bool{}
+	for _, node := range nodeSamples() {
+		sampled[reflect.TypeOf(node).Elem().Name()] = true
+	}
+
+	for name := range declared {
+		if !sampled[name] {
+			t.Errorf("node type %s has no sample, add it to nodeSamples and make sure Walk, Modify and Copy handle it", name)
+		}
+	}
+
+	for _, node := range nodeSamples() {
+		Inspect(node, func(Node) bool { return true })
+		copied := Copy(node)
+		if copied.String() != node.String() {
+			t.Errorf("copy of %T differs, got %q, want %q", node, copied.String(), node.String())
+		}
+		Modify(node, func(n Node) Node { return n })
+	}
+}
+
+// counter counts the nodes it visits and the calls with nil after them.
+type counter struct {
+	nodes, ends int
+}
+
+func (c *counter) Visit(node Node) Visitor {
+	if node == nil {
+		c.ends++
+	} else {
+		c.nodes++
+	}
+	return c
+}
+
+func TestWalk(t *testing.T) {
+	for _, node := range nodeSamples() {
+		c := &counter{}
+		Walk(c, node)
+		if c.nodes == 0 || c.nodes != c.ends {
+			t.Errorf("%T: visited %d nodes, but ended %d", node, c.nodes, c.ends)
+		}
+	}
+
+	// funksjon(x) { hvis (a) { b + c } ellers { d[e] } }
+	program := &Program{Statements: []Statement{&ExpressionStatement{Expression: &FunctionLiteral{
+		Parameters: []*Identifier{{Value: "x"}},
+		Body: &BlockStatement{Statements: []Statement{&ExpressionStatement{Expression: &IfExpression{
+			Condition: &Identifier{Value: "a"},
+			Consequence: &BlockStatement{Statements: []Statement{&ExpressionStatement{
+				Expression: &InfixExpression{Left: &Identifier{Value: "b"}, Operator: "+", Right: &Identifier{Value: "c"}},
+			}}},
+			Alternative: &BlockStatement{Statements: []Statement{&ExpressionStatement{
+				Expression: &IndexExpression{Left: &Identifier{Value: "d"}, Index: &Identifier{Value: "e"}},
+			}}},
+		}}}},
+	}}}}
+
+	names := []string{}
+	Inspect(program, func(node Node) bool {
+		if ident, ok := node.(*Identifier); ok {
+			names = append(names, ident.Value)
+		}
+		return true
+	})
+	if strings.Join(names, " ") != "x a b c d e" {
+		t.Errorf("wrong order, got %v", names)
+	}
+
+	names = []string{}
+	Inspect(program, func(node Node) bool {
+		if ident, ok := node.(*Identifier); ok {
+			names = append(names, ident.Value)
+		}
+		_, isBlock := node.(*BlockStatement)
+		return !isBlock
+	})
+	if strings.Join(names, " ") != "x" {
+		t.Errorf("blocks not skipped, got %v", names)
+	}
+}
+
+func TestWalkUnknownNode(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected Walk to panic on an unknown node type")
+		}
+	}()
+
+	Inspect(&unknownNode{}, func(Node) bool { return true })
+}
+
+type unknownNode struct{}
+
+func (u *unknownNode) TokenLiteral() string { return "" }
+func (u *unknownNode) String() string       { return "" }
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, node := range nodeSamples() {
+		data, err := EncodeJSON(node)
+		if err != nil {
+			t.Fatalf("%T: encode error: %s", node, err)
+		}
+
+		decoded, err := DecodeJSON(data)
+		if err != nil {
+			t.Fatalf("%T: decode error: %s", node, err)
+		}
+
+		if reflect.TypeOf(decoded) != reflect.TypeOf(node) || decoded.String() != node.String() {
+			t.Errorf("%T: decoded to %T %q, want %q", node, decoded, decoded.String(), node.String())
+		}
+
+		again, _ := EncodeJSON(decoded)
+		if string(again) != string(data) {
+			t.Errorf("%T: encoding the decoded node differs:\n%s\nwant\n%s", node, again, data)
+		}
+	}
+}
+
+func TestJSONPositions(t *testing.T) {
+	ident := &Identifier{Token: token.Token{Type: token.IDENT, Literal: "x", Pos: token.Position{Offset: 4, Line: 2, Column: 3}}, Value: "x"}
+
+	data, err := EncodeJSON(ident)
+	if err != nil {
+		t.Fatalf("encode error: %s", err)
+	}
+
+	decoded, err := DecodeJSON(data)
+	if err != nil {
+		t.Fatalf("decode error: %s", err)
+	}
+
+	if !reflect.DeepEqual(decoded, ident) {
+		t.Errorf("decoded to %#v, want %#v", decoded, ident)
+	}
+}
+
+func TestDecodeJSONErrors(t *testing.T) {
+	tests := []string{
+		`[]`,
+		`{"type": "Nothing"}`,
+		`{"type": "Program", "statements": [{"type": "IntegerLiteral", "token": {}, "value": 1}]}`,
+		`{"type": "ExpressionStatement", "token": {}}`,
+		`{"type": "Identifier", "value": "x"}`,
+		`{"type": "IntegerLiteral", "token": {}, "value": "x"}`,
+		`{"type": "LetStatement", "token": {}, "name": {"type": "IntegerLiteral", "token": {}, "value": 1}, "value": {"type": "IntegerLiteral", "token": {}, "value": 1}}`,
+	}
+
+	for _, input := range tests {
+		if node, err := DecodeJSON([]byte(input)); err == nil {
+			t.Errorf("%s: expected an error, got %#v", input, node)
+		}
+	}
+}
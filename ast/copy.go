@@ -0,0 +1,157 @@
+// ast/copy.go
+
+package ast
+
+import "fmt"
+
+// Copy returns a deep copy of node, so that it can be changed with Modify
+// without changing node. Tokens are copied with the nodes they belong to.
+// Like Walk, Copy panics on node types it does not know.
+func Copy(node Node) Node {
+	switch node := node.(type) {
+
+	case *Program:
+		return &Program{Statements: copyStatements(node.Statements)}
+
+	case *ExpressionStatement:
+		return &ExpressionStatement{Token: node.Token, Expression: copyExpression(node.Expression)}
+
+	case *LetStatement:
+		return &LetStatement{Token: node.Token, Name: copyIdentifier(node.Name), Value: copyExpression(node.Value)}
+
+	case *ReturnStatement:
+		return &ReturnStatement{Token: node.Token, ReturnValue: copyExpression(node.ReturnValue)}
+
+	case *BlockStatement:
+		return copyBlock(node)
+
+	case *Identifier:
+		return copyIdentifier(node)
+
+	case *IntegerLiteral:
+		copied := *node
+		return &copied
+
+	case *StringLiteral:
+		copied := *node
+		return &copied
+
+	case *Boolean:
+		copied := *node
+		return &copied
+
+	case *NullLiteral:
+		copied := *node
+		return &copied
+
+	case *PrefixExpression:
+		return &PrefixExpression{Token: node.Token, Operator: node.Operator, Right: copyExpression(node.Right)}
+
+	case *InfixExpression:
+		return &InfixExpression{
+			Token:    node.Token,
+			Left:     copyExpression(node.Left),
+			Operator: node.Operator,
+			Right:    copyExpression(node.Right),
+		}
+
+	case *IfExpression:
+		return &IfExpression{
+			Token:       node.Token,
+			Condition:   copyExpression(node.Condition),
+			Consequence: copyBlock(node.Consequence),
+			Alternative: copyBlock(node.Alternative),
+		}
+
+	case *IndexExpression:
+		return &IndexExpression{Token: node.Token, Left: copyExpression(node.Left), Index: copyExpression(node.Index)}
+
+	case *CallExpression:
+		return &CallExpression{
+			Token:     node.Token,
+			Function:  copyExpression(node.Function),
+			Arguments: copyExpressions(node.Arguments),
+		}
+
+	case *FunctionLiteral:
+		return &FunctionLiteral{Token: node.Token, Parameters: copyIdentifiers(node.Parameters), Body: copyBlock(node.Body)}
+
+	case *MacroLiteral:
+		return &MacroLiteral{Token: node.Token, Parameters: copyIdentifiers(node.Parameters), Body: copyBlock(node.Body)}
+
+	case *InterpolatedString:
+		return &InterpolatedString{Token: node.Token, Parts: copyExpressions(node.Parts)}
+
+	case *ArrayLiteral:
+		return &ArrayLiteral{Token: node.Token, Elements: copyExpressions(node.Elements)}
+
+	case *HashLiteral:
+		pairs := make([]HashPair, len(node.Pairs))
+		for i, pair := range node.Pairs {
+			pairs[i] = HashPair{Key: copyExpression(pair.Key), Value: copyExpression(pair.Value)}
+		}
+		return &HashLiteral{Token: node.Token, Pairs: pairs}
+
+	case nil:
+		return nil
+
+	default:
+		panic(fmt.Sprintf("ast.Copy: unexpected node type %T", node))
+	}
+}
+
+func copyExpression(exp Expression) Expression {
+	if exp == nil {
+		return nil
+	}
+	copied, _ := Copy(exp).(Expression)
+	return copied
+}
+
+func copyExpressions(exps []Expression) []Expression {
+	if exps == nil {
+		return nil
+	}
+	copied := make([]Expression, len(exps))
+	for i, exp := range exps {
+		copied[i] = copyExpression(exp)
+	}
+	return copied
+}
+
+func copyStatements(stmts []Statement) []Statement {
+	if stmts == nil {
+		return nil
+	}
+	copied := make([]Statement, len(stmts))
+	for i, stmt := range stmts {
+		copied[i], _ = Copy(stmt).(Statement)
+	}
+	return copied
+}
+
+func copyIdentifier(ident *Identifier) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	copied := *ident
+	return &copied
+}
+
+func copyIdentifiers(idents []*Identifier) []*Identifier {
+	if idents == nil {
+		return nil
+	}
+	copied := make([]*Identifier, len(idents))
+	for i, ident := range idents {
+		copied[i] = copyIdentifier(ident)
+	}
+	return copied
+}
+
+func copyBlock(block *BlockStatement) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	return &BlockStatement{Token: block.Token, Statements: copyStatements(block.Statements), Rbrace: block.Rbrace}
+}
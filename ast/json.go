@@ -0,0 +1,365 @@
+// ast/json.go
+
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/solbero/pytonskript/token"
+)
+
+// jsonNode is how every node is written as JSON. type is the name of the
+// node type and token the token the node was made from, with its position.
+// The other fields are the children and values of the node, named as in the
+// node type, and are left out when the node type has no such field.
+type jsonNode struct {
+	Type  string     `json:"type"`
+	Token *jsonToken `json:"token,omitempty"`
+
+	Name        *jsonNode       `json:"name,omitempty"`
+	Value       json.RawMessage `json:"value,omitempty"`
+	ReturnValue *jsonNode       `json:"returnValue,omitempty"`
+	Expression  *jsonNode       `json:"expression,omitempty"`
+	Statements  []*jsonNode     `json:"statements,omitempty"`
+	Rbrace      *jsonToken      `json:"rbrace,omitempty"`
+	Operator    string          `json:"operator,omitempty"`
+	Left        *jsonNode       `json:"left,omitempty"`
+	Right       *jsonNode       `json:"right,omitempty"`
+	Condition   *jsonNode       `json:"condition,omitempty"`
+	Consequence *jsonNode       `json:"consequence,omitempty"`
+	Alternative *jsonNode       `json:"alternative,omitempty"`
+	Index       *jsonNode       `json:"index,omitempty"`
+	Function    *jsonNode       `json:"function,omitempty"`
+	Arguments   []*jsonNode     `json:"arguments,omitempty"`
+	Parameters  []*jsonNode     `json:"parameters,omitempty"`
+	Body        *jsonNode       `json:"body,omitempty"`
+	Elements    []*jsonNode     `json:"elements,omitempty"`
+	Parts       []*jsonNode     `json:"parts,omitempty"`
+	Pairs       []*jsonPair     `json:"pairs,omitempty"`
+}
+
+type jsonToken struct {
+	Type    token.TokenType `json:"type"`
+	Literal string          `json:"literal"`
+	Offset  int             `json:"offset"`
+	Line    int             `json:"line"`
+	Column  int             `json:"column"`
+}
+
+type jsonPair struct {
+	Key   *jsonNode `json:"key"`
+	Value *jsonNode `json:"value"`
+}
+
+// EncodeJSON writes node and every node under it as indented JSON.
+func EncodeJSON(node Node) ([]byte, error) {
+	n, err := toJSON(node)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(n, "", "  ")
+}
+
+// DecodeJSON reads a node written by EncodeJSON.
+func DecodeJSON(data []byte) (Node, error) {
+	var n jsonNode
+	if err := json.Unmarshal(data, &n); err != nil {
+		return nil, err
+	}
+	return fromJSON(&n)
+}
+
+func toJSONToken(t token.Token) *jsonToken {
+	return &jsonToken{Type: t.Type, Literal: t.Literal, Offset: t.Pos.Offset, Line: t.Pos.Line, Column: t.Pos.Column}
+}
+
+func toJSON(node Node) (*jsonNode, error) {
+	var err error
+	convert := func(node Node) *jsonNode {
+		if err != nil {
+			return nil
+		}
+		var n *jsonNode
+		n, err = toJSON(node)
+		return n
+	}
+	convertAll := func(nodes []Node) []*jsonNode {
+		ns := []*jsonNode{}
+		for _, node := range nodes {
+			ns = append(ns, convert(node))
+		}
+		return ns
+	}
+	value := func(v interface{}) json.RawMessage {
+		data, _ := json.Marshal(v)
+		return data
+	}
+
+	var n *jsonNode
+
+	switch node := node.(type) {
+	case *Program:
+		n = &jsonNode{Statements: convertAll(statementNodes(node.Statements))}
+	case *LetStatement:
+		n = &jsonNode{Token: toJSONToken(node.Token), Name: convert(node.Name)}
+		if v := convert(node.Value); v != nil {
+			n.Value = value(v)
+		}
+	case *ReturnStatement:
+		n = &jsonNode{Token: toJSONToken(node.Token), ReturnValue: convert(node.ReturnValue)}
+	case *ExpressionStatement:
+		n = &jsonNode{Token: toJSONToken(node.Token), Expression: convert(node.Expression)}
+	case *BlockStatement:
+		n = &jsonNode{Token: toJSONToken(node.Token), Statements: convertAll(statementNodes(node.Statements)), Rbrace: toJSONToken(node.Rbrace)}
+	case *Identifier:
+		n = &jsonNode{Token: toJSONToken(node.Token), Value: value(node.Value)}
+	case *IntegerLiteral:
+		n = &jsonNode{Token: toJSONToken(node.Token), Value: value(node.Value)}
+	case *StringLiteral:
+		n = &jsonNode{Token: toJSONToken(node.Token), Value: value(node.Value)}
+	case *Boolean:
+		n = &jsonNode{Token: toJSONToken(node.Token), Value: value(node.Value)}
+	case *NullLiteral:
+		n = &jsonNode{Token: toJSONToken(node.Token)}
+	case *InterpolatedString:
+		n = &jsonNode{Token: toJSONToken(node.Token), Parts: convertAll(expressionNodes(node.Parts))}
+	case *ArrayLiteral:
+		n = &jsonNode{Token: toJSONToken(node.Token), Elements: convertAll(expressionNodes(node.Elements))}
+	case *HashLiteral:
+		n = &jsonNode{Token: toJSONToken(node.Token), Pairs: []*jsonPair{}}
+		for _, pair := range node.Pairs {
+			n.Pairs = append(n.Pairs, &jsonPair{Key: convert(pair.Key), Value: convert(pair.Value)})
+		}
+	case *PrefixExpression:
+		n = &jsonNode{Token: toJSONToken(node.Token), Operator: node.Operator, Right: convert(node.Right)}
+	case *InfixExpression:
+		n = &jsonNode{Token: toJSONToken(node.Token), Left: convert(node.Left), Operator: node.Operator, Right: convert(node.Right)}
+	case *IfExpression:
+		n = &jsonNode{Token: toJSONToken(node.Token), Condition: convert(node.Condition), Consequence: convert(node.Consequence)}
+		if node.Alternative != nil {
+			n.Alternative = convert(node.Alternative)
+		}
+	case *IndexExpression:
+		n = &jsonNode{Token: toJSONToken(node.Token), Left: convert(node.Left), Index: convert(node.Index)}
+	case *CallExpression:
+		n = &jsonNode{Token: toJSONToken(node.Token), Function: convert(node.Function), Arguments: convertAll(expressionNodes(node.Arguments))}
+	case *FunctionLiteral:
+		n = &jsonNode{Token: toJSONToken(node.Token), Parameters: convertAll(identifierNodes(node.Parameters)), Body: convert(node.Body)}
+	case *MacroLiteral:
+		n = &jsonNode{Token: toJSONToken(node.Token), Parameters: convertAll(identifierNodes(node.Parameters)), Body: convert(node.Body)}
+	default:
+		return nil, fmt.Errorf("ast: kan ikke skrive %T som JSON", node)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	n.Type = typeName(node)
+	return n, nil
+}
+
+func typeName(node Node) string {
+	return fmt.Sprintf("%T", node)[len("*ast."):]
+}
+
+func statementNodes(stmts []Statement) []Node {
+	nodes := make([]Node, len(stmts))
+	for i, stmt := range stmts {
+		nodes[i] = stmt
+	}
+	return nodes
+}
+
+func expressionNodes(exps []Expression) []Node {
+	nodes := make([]Node, len(exps))
+	for i, exp := range exps {
+		nodes[i] = exp
+	}
+	return nodes
+}
+
+func identifierNodes(idents []*Identifier) []Node {
+	nodes := make([]Node, len(idents))
+	for i, ident := range idents {
+		nodes[i] = ident
+	}
+	return nodes
+}
+
+// decoder turns jsonNodes back into nodes. After the first error it returns
+// nil for everything, so the caller checks err once at the end.
+type decoder struct {
+	err error
+}
+
+func (d *decoder) fail(format string, a ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf("ast: "+format, a...)
+	}
+}
+
+func fromJSON(n *jsonNode) (Node, error) {
+	d := &decoder{}
+	node := d.node(n)
+	if d.err != nil {
+		return nil, d.err
+	}
+	return node, nil
+}
+
+func (d *decoder) token(t *jsonToken, what string) token.Token {
+	if t == nil {
+		d.fail("%s mangler token", what)
+		return token.Token{}
+	}
+	return token.Token{Type: t.Type, Literal: t.Literal, Pos: token.Position{Offset: t.Offset, Line: t.Line, Column: t.Column}}
+}
+
+func (d *decoder) value(n *jsonNode, v interface{}) {
+	if len(n.Value) == 0 {
+		d.fail("%s mangler value", n.Type)
+		return
+	}
+	if err := json.Unmarshal(n.Value, v); err != nil {
+		d.fail("ugyldig value i %s: %s", n.Type, err)
+	}
+}
+
+func (d *decoder) node(n *jsonNode) Node {
+	if d.err != nil {
+		return nil
+	}
+	if n == nil {
+		d.fail("en node mangler")
+		return nil
+	}
+
+	switch n.Type {
+	case "Program":
+		return &Program{Statements: d.statements(n.Statements)}
+	case "LetStatement":
+		var value jsonNode
+		d.value(n, &value)
+		return &LetStatement{Token: d.token(n.Token, n.Type), Name: d.identifier(n.Name), Value: d.expression(&value)}
+	case "ReturnStatement":
+		return &ReturnStatement{Token: d.token(n.Token, n.Type), ReturnValue: d.expression(n.ReturnValue)}
+	case "ExpressionStatement":
+		return &ExpressionStatement{Token: d.token(n.Token, n.Type), Expression: d.expression(n.Expression)}
+	case "BlockStatement":
+		return &BlockStatement{Token: d.token(n.Token, n.Type), Statements: d.statements(n.Statements), Rbrace: d.token(n.Rbrace, n.Type)}
+	case "Identifier":
+		ident := &Identifier{Token: d.token(n.Token, n.Type)}
+		d.value(n, &ident.Value)
+		return ident
+	case "IntegerLiteral":
+		lit := &IntegerLiteral{Token: d.token(n.Token, n.Type)}
+		d.value(n, &lit.Value)
+		return lit
+	case "StringLiteral":
+		lit := &StringLiteral{Token: d.token(n.Token, n.Type)}
+		d.value(n, &lit.Value)
+		return lit
+	case "Boolean":
+		lit := &Boolean{Token: d.token(n.Token, n.Type)}
+		d.value(n, &lit.Value)
+		return lit
+	case "NullLiteral":
+		return &NullLiteral{Token: d.token(n.Token, n.Type)}
+	case "InterpolatedString":
+		return &InterpolatedString{Token: d.token(n.Token, n.Type), Parts: d.expressions(n.Parts)}
+	case "ArrayLiteral":
+		return &ArrayLiteral{Token: d.token(n.Token, n.Type), Elements: d.expressions(n.Elements)}
+	case "HashLiteral":
+		hash := &HashLiteral{Token: d.token(n.Token, n.Type), Pairs: []HashPair{}}
+		for _, pair := range n.Pairs {
+			if pair == nil {
+				d.fail("et par i HashLiteral mangler")
+				return nil
+			}
+			hash.Pairs = append(hash.Pairs, HashPair{Key: d.expression(pair.Key), Value: d.expression(pair.Value)})
+		}
+		return hash
+	case "PrefixExpression":
+		return &PrefixExpression{Token: d.token(n.Token, n.Type), Operator: n.Operator, Right: d.expression(n.Right)}
+	case "InfixExpression":
+		return &InfixExpression{Token: d.token(n.Token, n.Type), Left: d.expression(n.Left), Operator: n.Operator, Right: d.expression(n.Right)}
+	case "IfExpression":
+		exp := &IfExpression{Token: d.token(n.Token, n.Type), Condition: d.expression(n.Condition), Consequence: d.block(n.Consequence)}
+		if n.Alternative != nil {
+			exp.Alternative = d.block(n.Alternative)
+		}
+		return exp
+	case "IndexExpression":
+		return &IndexExpression{Token: d.token(n.Token, n.Type), Left: d.expression(n.Left), Index: d.expression(n.Index)}
+	case "CallExpression":
+		return &CallExpression{Token: d.token(n.Token, n.Type), Function: d.expression(n.Function), Arguments: d.expressions(n.Arguments)}
+	case "FunctionLiteral":
+		return &FunctionLiteral{Token: d.token(n.Token, n.Type), Parameters: d.identifiers(n.Parameters), Body: d.block(n.Body)}
+	case "MacroLiteral":
+		return &MacroLiteral{Token: d.token(n.Token, n.Type), Parameters: d.identifiers(n.Parameters), Body: d.block(n.Body)}
+	default:
+		d.fail("ukjent nodetype %q", n.Type)
+		return nil
+	}
+}
+
+func (d *decoder) statements(ns []*jsonNode) []Statement {
+	stmts := []Statement{}
+	for _, n := range ns {
+		stmt, ok := d.node(n).(Statement)
+		if !ok {
+			d.fail("forventet en setning, fikk %s", nodeType(n))
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
+
+func (d *decoder) expression(n *jsonNode) Expression {
+	exp, ok := d.node(n).(Expression)
+	if !ok {
+		d.fail("forventet et uttrykk, fikk %s", nodeType(n))
+	}
+	return exp
+}
+
+func (d *decoder) expressions(ns []*jsonNode) []Expression {
+	exps := []Expression{}
+	for _, n := range ns {
+		exps = append(exps, d.expression(n))
+	}
+	return exps
+}
+
+func (d *decoder) identifier(n *jsonNode) *Identifier {
+	ident, ok := d.node(n).(*Identifier)
+	if !ok {
+		d.fail("forventet Identifier, fikk %s", nodeType(n))
+	}
+	return ident
+}
+
+func (d *decoder) identifiers(ns []*jsonNode) []*Identifier {
+	idents := []*Identifier{}
+	for _, n := range ns {
+		idents = append(idents, d.identifier(n))
+	}
+	return idents
+}
+
+func (d *decoder) block(n *jsonNode) *BlockStatement {
+	block, ok := d.node(n).(*BlockStatement)
+	if !ok {
+		d.fail("forventet BlockStatement, fikk %s", nodeType(n))
+	}
+	return block
+}
+
+func nodeType(n *jsonNode) string {
+	if n == nil {
+		return "ingenting"
+	}
+	return n.Type
+}
@@ -0,0 +1,103 @@
+// ast/modify.go
+
+package ast
+
+import "fmt"
+
+// ModifierFunc is called with every node Modify visits and returns the node
+// to put in its place.
+type ModifierFunc func(Node) Node
+
+// Modify rewrites node from the bottom up. The children of a node are
+// modified first, then modifier is called with the node itself, and its
+// result replaces the node. Nodes are changed in place, so Modify returns
+// node itself unless modifier replaces it. Children are modified in the order
+// Walk visits them, and like Walk, Modify panics on node types it does not
+// know.
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch node := node.(type) {
+
+	case *Identifier, *IntegerLiteral, *StringLiteral, *Boolean, *NullLiteral:
+		// No children.
+
+	case *Program:
+		for i, statement := range node.Statements {
+			node.Statements[i], _ = Modify(statement, modifier).(Statement)
+		}
+
+	case *ExpressionStatement:
+		node.Expression, _ = Modify(node.Expression, modifier).(Expression)
+
+	case *LetStatement:
+		node.Name, _ = Modify(node.Name, modifier).(*Identifier)
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+
+	case *ReturnStatement:
+		node.ReturnValue, _ = Modify(node.ReturnValue, modifier).(Expression)
+
+	case *BlockStatement:
+		for i, statement := range node.Statements {
+			node.Statements[i], _ = Modify(statement, modifier).(Statement)
+		}
+
+	case *PrefixExpression:
+		node.Right, _ = Modify(node.Right, modifier).(Expression)
+
+	case *InfixExpression:
+		node.Left, _ = Modify(node.Left, modifier).(Expression)
+		node.Right, _ = Modify(node.Right, modifier).(Expression)
+
+	case *IfExpression:
+		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
+		node.Consequence, _ = Modify(node.Consequence, modifier).(*BlockStatement)
+		if node.Alternative != nil {
+			node.Alternative, _ = Modify(node.Alternative, modifier).(*BlockStatement)
+		}
+
+	case *IndexExpression:
+		node.Left, _ = Modify(node.Left, modifier).(Expression)
+		node.Index, _ = Modify(node.Index, modifier).(Expression)
+
+	case *CallExpression:
+		node.Function, _ = Modify(node.Function, modifier).(Expression)
+		for i, arg := range node.Arguments {
+			node.Arguments[i], _ = Modify(arg, modifier).(Expression)
+		}
+
+	case *FunctionLiteral:
+		for i, param := range node.Parameters {
+			node.Parameters[i], _ = Modify(param, modifier).(*Identifier)
+		}
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+
+	case *MacroLiteral:
+		for i, param := range node.Parameters {
+			node.Parameters[i], _ = Modify(param, modifier).(*Identifier)
+		}
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+
+	case *InterpolatedString:
+		for i, part := range node.Parts {
+			node.Parts[i], _ = Modify(part, modifier).(Expression)
+		}
+
+	case *ArrayLiteral:
+		for i, element := range node.Elements {
+			node.Elements[i], _ = Modify(element, modifier).(Expression)
+		}
+
+	case *HashLiteral:
+		for i, pair := range node.Pairs {
+			node.Pairs[i].Key, _ = Modify(pair.Key, modifier).(Expression)
+			node.Pairs[i].Value, _ = Modify(pair.Value, modifier).(Expression)
+		}
+
+	case nil:
+		return nil
+
+	default:
+		panic(fmt.Sprintf("ast.Modify: unexpected node type %T", node))
+	}
+
+	return modifier(node)
+}
@@ -0,0 +1,136 @@
+// ast/walk.go
+
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is called for each node Walk meets. If it returns
+// a visitor w that is not nil, Walk visits the children of the node with w,
+// and then calls w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk visits node and then, depth first, the nodes under it in the order
+// they are written, which for a hash literal is each key followed by its
+// value, pair by pair. Walk panics on node types it does not know, so that a
+// new node type cannot be left out.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+
+	// Statements
+	case *Program:
+		walkStatements(v, n.Statements)
+	case *LetStatement:
+		walkIdentifier(v, n.Name)
+		walkExpression(v, n.Value)
+	case *ReturnStatement:
+		walkExpression(v, n.ReturnValue)
+	case *ExpressionStatement:
+		walkExpression(v, n.Expression)
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+
+	// Identifiers and literals
+	case *Identifier, *IntegerLiteral, *StringLiteral, *Boolean, *NullLiteral:
+		// No children.
+	case *InterpolatedString:
+		walkExpressions(v, n.Parts)
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+	case *HashLiteral:
+		for _, pair := range n.Pairs {
+			walkExpression(v, pair.Key)
+			walkExpression(v, pair.Value)
+		}
+
+	// Expressions
+	case *PrefixExpression:
+		walkExpression(v, n.Right)
+	case *InfixExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Right)
+	case *IfExpression:
+		walkExpression(v, n.Condition)
+		walkBlock(v, n.Consequence)
+		walkBlock(v, n.Alternative)
+	case *IndexExpression:
+		walkExpression(v, n.Left)
+		walkExpression(v, n.Index)
+	case *CallExpression:
+		walkExpression(v, n.Function)
+		walkExpressions(v, n.Arguments)
+
+	// Functions
+	case *FunctionLiteral:
+		for _, param := range n.Parameters {
+			walkIdentifier(v, param)
+		}
+		walkBlock(v, n.Body)
+	case *MacroLiteral:
+		for _, param := range n.Parameters {
+			walkIdentifier(v, param)
+		}
+		walkBlock(v, n.Body)
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+// The helpers below skip missing children, which a program with syntax
+// errors can have.
+
+func walkStatements(v Visitor, stmts []Statement) {
+	for _, stmt := range stmts {
+		if stmt != nil {
+			Walk(v, stmt)
+		}
+	}
+}
+
+func walkExpression(v Visitor, exp Expression) {
+	if exp != nil {
+		Walk(v, exp)
+	}
+}
+
+func walkExpressions(v Visitor, exps []Expression) {
+	for _, exp := range exps {
+		walkExpression(v, exp)
+	}
+}
+
+func walkIdentifier(v Visitor, ident *Identifier) {
+	if ident != nil {
+		Walk(v, ident)
+	}
+}
+
+func walkBlock(v Visitor, block *BlockStatement) {
+	if block != nil {
+		Walk(v, block)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect walks node like Walk, calling f with each node. If f returns
+// false, the nodes under that node are skipped. After the children of a node
+// f is called with nil.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
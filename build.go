@@ -0,0 +1,91 @@
+// build.go
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/solbero/pytonskript/bytecode"
+	"github.com/solbero/pytonskript/compiler"
+	"github.com/solbero/pytonskript/evaluator"
+	"github.com/solbero/pytonskript/lexer"
+	"github.com/solbero/pytonskript/optimizer"
+	"github.com/solbero/pytonskript/parser"
+	"github.com/solbero/pytonskript/token"
+)
+
+// buildCommand implements `pytonskript bygg [-o utfil] [-optimaliser] fil`,
+// which compiles a program to a bytecode file that can be run like the
+// source, without reading and parsing it again. The file is named after the
+// program with the extension .pytb unless -o says otherwise.
+func buildCommand(args []string, dialect *token.Dialect) int {
+	flags := flag.NewFlagSet("bygg", flag.ContinueOnError)
+	output := flags.String("o", "", "filen bytekoden skrives til")
+	optimize := flags.Bool("optimaliser", false, "optimaliser programmet før det kompileres")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "bruk: pytonskript bygg [-o utfil] [-optimaliser] fil\n")
+		return 2
+	}
+
+	path := flags.Arg(0)
+	input, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	l := lexer.NewWithDialect(string(input), dialect)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, err := range p.Errors() {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", path, err)
+		}
+		return 1
+	}
+
+	program, errObj := evaluator.ExpandProgram(program, l.Dialect())
+	if errObj != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, errObj.Inspect())
+		return 1
+	}
+
+	if *optimize {
+		program = optimizer.Optimize(program, l.Dialect())
+	}
+
+	comp := compiler.New(l.Dialect())
+	if err := comp.Compile(program); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+		return 1
+	}
+
+	if *output == "" {
+		*output = strings.TrimSuffix(path, filepath.Ext(path)) + ".pytb"
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	err = bytecode.Write(file, comp.Bytecode())
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,323 @@
+// bytecode/bytecode.go
+
+// Package bytecode reads and writes compiled programs as files, so that they
+// can be run without the source.
+//
+// A file starts with a header of the magic bytes "PYTB", the format version
+// as a big-endian uint16 and a CRC-32 (IEEE) of the rest of the file as a
+// big-endian uint32. The rest holds, in order, the names of the globals, the
+// constant pool and the code of the program. Numbers are varints, strings
+// and byte slices are prefixed with their length, and code is the
+// instructions followed by their source position table. Constants start with
+// a tag: 'i' for integers, 's' for strings and 'f' for function prototypes,
+// which hold the number of locals, parameters and free variables, the names
+// of the locals, the function literal and the code of the function.
+package bytecode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+
+	"github.com/solbero/pytonskript/code"
+	"github.com/solbero/pytonskript/compiler"
+	"github.com/solbero/pytonskript/diagnostic"
+	"github.com/solbero/pytonskript/object"
+)
+
+// Version is the version of the format this package reads and writes. It
+// changes whenever a program compiled with one version could run differently
+// with another.
+const Version = 6
+
+const (
+	magic      = "PYTB"
+	headerSize = len(magic) + 2 + 4
+
+	tagInteger  = 'i'
+	tagString   = 's'
+	tagFunction = 'f'
+)
+
+var (
+	// ErrNotBytecode is returned for files that do not start with the magic
+	// bytes.
+	ErrNotBytecode error = catalogError(diagnostic.NotBytecode)
+
+	// ErrCorrupt is returned, wrapped with the details, for files that are
+	// damaged or do not hold a valid program.
+	ErrCorrupt error = catalogError(diagnostic.CorruptBytecode)
+)
+
+// catalogError is an error whose message is looked up in the catalog when it
+// is printed, so that it is shown in the language chosen by then.
+type catalogError diagnostic.Code
+
+func (e catalogError) Error() string {
+	return diagnostic.Sprintf(diagnostic.Code(e))
+}
+
+// VersionError is returned for files written in another version of the
+// format.
+type VersionError struct {
+	Version int
+}
+
+func (e *VersionError) Error() string {
+	return diagnostic.Sprintf(diagnostic.WrongBytecodeVersion, e.Version, Version)
+}
+
+// IsBytecode reports whether data starts like a bytecode file.
+func IsBytecode(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(magic))
+}
+
+// Write writes bc to w.
+func Write(w io.Writer, bc *compiler.Bytecode) error {
+	e := &encoder{}
+
+	e.uvarint(len(bc.Globals))
+	for _, name := range bc.Globals {
+		e.string(name)
+	}
+
+	e.uvarint(len(bc.Constants))
+	for _, constant := range bc.Constants {
+		switch constant := constant.(type) {
+		case *object.Integer:
+			e.byte(tagInteger)
+			e.varint(constant.Value)
+		case *object.String:
+			e.byte(tagString)
+			e.string(constant.Value)
+		case *object.CompiledFunction:
+			e.byte(tagFunction)
+			e.uvarint(constant.NumLocals)
+			e.uvarint(constant.NumParameters)
+			e.uvarint(constant.NumFree)
+			e.uvarint(len(constant.LocalNames))
+			for _, name := range constant.LocalNames {
+				e.string(name)
+			}
+			e.string(constant.Literal)
+			e.code(constant.Instructions, constant.Positions)
+		default:
+			return errors.New(diagnostic.Sprintf(diagnostic.UnwritableConstant, constant.Type()))
+		}
+	}
+
+	e.code(bc.Instructions, bc.Positions)
+
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	binary.BigEndian.PutUint16(header[len(magic):], Version)
+	binary.BigEndian.PutUint32(header[len(magic)+2:], crc32.ChecksumIEEE(e.buf.Bytes()))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(e.buf.Bytes())
+	return err
+}
+
+// Read reads a program written by Write. It fails with ErrNotBytecode, a
+// *VersionError or ErrCorrupt if r does not hold a valid program of this
+// version.
+func Read(r io.Reader) (*compiler.Bytecode, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !IsBytecode(data) {
+		return nil, ErrNotBytecode
+	}
+	if len(data) < headerSize {
+		return nil, corrupt(diagnostic.HeaderTooShort)
+	}
+	if version := int(binary.BigEndian.Uint16(data[len(magic):])); version != Version {
+		return nil, &VersionError{Version: version}
+	}
+
+	body := data[headerSize:]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(data[len(magic)+2:]) {
+		return nil, corrupt(diagnostic.ChecksumMismatch)
+	}
+
+	d := &decoder{data: body}
+	bc := &compiler.Bytecode{}
+
+	bc.Globals = make([]string, d.count())
+	for i := range bc.Globals {
+		bc.Globals[i] = d.string()
+	}
+
+	bc.Constants = make([]object.Object, d.count())
+	for i := range bc.Constants {
+		switch tag := d.byte(); tag {
+		case tagInteger:
+			bc.Constants[i] = &object.Integer{Value: d.varint()}
+		case tagString:
+			bc.Constants[i] = &object.String{Value: d.string()}
+		case tagFunction:
+			fn := &object.CompiledFunction{
+				NumLocals:     d.uvarint(),
+				NumParameters: d.uvarint(),
+				NumFree:       d.uvarint(),
+			}
+			fn.LocalNames = make([]string, d.count())
+			for j := range fn.LocalNames {
+				fn.LocalNames[j] = d.string()
+			}
+			fn.Literal = d.string()
+			fn.Instructions, fn.Positions = d.code()
+			bc.Constants[i] = fn
+		default:
+			if d.err == nil {
+				d.fail(diagnostic.UnknownConstantType, tag)
+			}
+		}
+	}
+
+	bc.Instructions, bc.Positions = d.code()
+
+	if d.err != nil {
+		return nil, d.err
+	}
+	if len(d.data) != 0 {
+		return nil, corrupt(diagnostic.TrailingBytes, len(d.data))
+	}
+
+	if err := validate(bc); err != nil {
+		return nil, err
+	}
+
+	return bc, nil
+}
+
+// corrupt returns ErrCorrupt wrapped with the message for code.
+func corrupt(code diagnostic.Code, a ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrCorrupt, diagnostic.Sprintf(code, a...))
+}
+
+type encoder struct {
+	buf bytes.Buffer
+}
+
+func (e *encoder) byte(b byte) {
+	e.buf.WriteByte(b)
+}
+
+func (e *encoder) uvarint(n int) {
+	e.buf.Write(binary.AppendUvarint(nil, uint64(n)))
+}
+
+func (e *encoder) varint(n int64) {
+	e.buf.Write(binary.AppendVarint(nil, n))
+}
+
+func (e *encoder) bytes(b []byte) {
+	e.uvarint(len(b))
+	e.buf.Write(b)
+}
+
+func (e *encoder) string(s string) {
+	e.bytes([]byte(s))
+}
+
+func (e *encoder) code(ins code.Instructions, positions code.SourceMap) {
+	e.bytes(ins)
+	e.uvarint(len(positions))
+	for _, pos := range positions {
+		e.uvarint(pos.Offset)
+		e.uvarint(pos.Line)
+		e.uvarint(pos.Column)
+	}
+}
+
+// decoder reads the body of a file. After the first error it reads only
+// zeros, so the caller checks err once at the end.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) fail(code diagnostic.Code, a ...interface{}) {
+	if d.err == nil {
+		d.err = corrupt(code, a...)
+	}
+	d.data = nil
+}
+
+func (d *decoder) byte() byte {
+	if len(d.data) == 0 {
+		d.fail(diagnostic.UnexpectedEnd)
+		return 0
+	}
+	b := d.data[0]
+	d.data = d.data[1:]
+	return b
+}
+
+func (d *decoder) uvarint() int {
+	n, size := binary.Uvarint(d.data)
+	if size <= 0 || n > math.MaxInt32 {
+		d.fail(diagnostic.InvalidNumber)
+		return 0
+	}
+	d.data = d.data[size:]
+	return int(n)
+}
+
+// count reads the length of a list. Every element takes at least a byte, so
+// a length longer than the rest of the file is an error, not a reason to
+// allocate.
+func (d *decoder) count() int {
+	n := d.uvarint()
+	if n > len(d.data) {
+		d.fail(diagnostic.ListTooLong)
+		return 0
+	}
+	return n
+}
+
+func (d *decoder) varint() int64 {
+	n, size := binary.Varint(d.data)
+	if size <= 0 {
+		d.fail(diagnostic.InvalidNumber)
+		return 0
+	}
+	d.data = d.data[size:]
+	return n
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.uvarint()
+	if n > len(d.data) {
+		d.fail(diagnostic.UnexpectedEnd)
+		return nil
+	}
+	b := make([]byte, n)
+	copy(b, d.data)
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) code() (code.Instructions, code.SourceMap) {
+	ins := code.Instructions(d.bytes())
+
+	positions := make(code.SourceMap, d.count())
+	for i := range positions {
+		positions[i] = code.SourcePosition{Offset: d.uvarint(), Line: d.uvarint(), Column: d.uvarint()}
+	}
+
+	return ins, positions
+}
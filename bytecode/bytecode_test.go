@@ -0,0 +1,344 @@
+// bytecode/bytecode_test.go
+
+package bytecode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"testing"
+
+	"github.com/solbero/pytonskript/code"
+	"github.com/solbero/pytonskript/compiler"
+	"github.com/solbero/pytonskript/diagnostic"
+	"github.com/solbero/pytonskript/lexer"
+	"github.com/solbero/pytonskript/object"
+	"github.com/solbero/pytonskript/parser"
+	"github.com/solbero/pytonskript/token"
+	"github.com/solbero/pytonskript/vm"
+)
+
+func compile(t *testing.T, input string) *compiler.Bytecode {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors in %q: %v", input, p.Errors())
+	}
+
+	comp := compiler.New(token.Bokmal)
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error in %q: %s", input, err)
+	}
+	return comp.Bytecode()
+}
+
+func write(t *testing.T, bc *compiler.Bytecode) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, bc); err != nil {
+		t.Fatalf("write error: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2 * 3", "7"},
+		{"-9223372036854775807 - 1", "-9223372036854775808"},
+		{`"Hei, " + "verden!"`, "Hei, verden!"},
+		{"la a = [1, 2, 3]; lengde(a)", "3"},
+		{`{"en": 1, "to": 2}["to"]`, "2"},
+		{"la lag = funksjon(x) { funksjon(y) { x + y } }; la plussto = lag(2); plussto(3)", "5"},
+		{"la f = funksjon() { la n = 1; la g = funksjon() { n * 10 }; g() }; f()", "10"},
+		{"funksjon(x) { x * 2 }", "fn(x) {\n(x * 2)\n}"},
+		{"hvis (1 < 2) { 10 } ellers { 20 }", "10"},
+		{"5 + sant", "FEIL: typene passer ikke sammen: heltall + sannhetsverdi [K002]"},
+	}
+
+	for _, tt := range tests {
+		bc, err := Read(bytes.NewReader(write(t, compile(t, tt.input))))
+		if err != nil {
+			t.Fatalf("read error in %q: %s", tt.input, err)
+		}
+
+		machine := vm.New(bc)
+		var result object.Object
+		if err := machine.Run(); err != nil {
+			result = err.(*object.Error)
+		} else {
+			result = machine.Result()
+		}
+
+		if inspect(result) != tt.expected {
+			t.Errorf("%q gave %q, expected %q", tt.input, inspect(result), tt.expected)
+		}
+	}
+}
+
+func TestRoundTripPositions(t *testing.T) {
+	input := `la f = funksjon(x) {
+  x + sant
+};
+f(1)`
+
+	bc, err := Read(bytes.NewReader(write(t, compile(t, input))))
+	if err != nil {
+		t.Fatalf("read error: %s", err)
+	}
+
+	machine := vm.New(bc)
+	if err := machine.Run(); err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	if line, column := machine.Position(); line != 2 || column != 5 {
+		t.Errorf("wrong position, expected 2:5, got %d:%d", line, column)
+	}
+}
+
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return ""
+	}
+	return obj.Inspect()
+}
+
+// resum recomputes the checksum of a file that a test has changed, so that
+// it reaches the validation.
+func resum(data []byte) []byte {
+	binary.BigEndian.PutUint32(data[len(magic)+2:], crc32.ChecksumIEEE(data[headerSize:]))
+	return data
+}
+
+func TestReadErrors(t *testing.T) {
+	valid := write(t, compile(t, "la a = 1; hvis (a) { a }"))
+	clone := func(data []byte) []byte { return append([]byte{}, data...) }
+
+	wrongVersion := clone(valid)
+	binary.BigEndian.PutUint16(wrongVersion[len(magic):], Version+1)
+
+	flipped := clone(valid)
+	flipped[len(flipped)-1] ^= 0xff
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected error
+	}{
+		{"empty", []byte{}, ErrNotBytecode},
+		{"source", []byte("la a = 1;"), ErrNotBytecode},
+		{"short header", []byte(magic + "\x00"), ErrCorrupt},
+		{"checksum", flipped, ErrCorrupt},
+		{"truncated", resum(clone(valid[:len(valid)-3])), ErrCorrupt},
+		{"trailing", resum(append(clone(valid), 0)), ErrCorrupt},
+	}
+
+	for _, tt := range tests {
+		_, err := Read(bytes.NewReader(tt.data))
+		if !errors.Is(err, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, err)
+		}
+	}
+
+	_, err := Read(bytes.NewReader(wrongVersion))
+	var versionErr *VersionError
+	if !errors.As(err, &versionErr) || versionErr.Version != Version+1 {
+		t.Errorf("wrong version: expected a *VersionError, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	concat := func(ins ...[]byte) code.Instructions {
+		out := code.Instructions{}
+		for _, in := range ins {
+			out = append(out, in...)
+		}
+		return out
+	}
+
+	fn := &object.CompiledFunction{
+		Instructions: concat(code.Make(code.OpGetLocal, 0), code.Make(code.OpReturnValue)),
+		NumLocals:    1,
+		LocalNames:   []string{"x"},
+	}
+
+	tests := []struct {
+		name string
+		bc   *compiler.Bytecode
+	}{
+		{"empty", &compiler.Bytecode{}},
+		{"no return", &compiler.Bytecode{
+			Instructions: concat(code.Make(code.OpTrue), code.Make(code.OpPop)),
+		}},
+		{"unknown opcode", &compiler.Bytecode{
+			Instructions: concat([]byte{255}, code.Make(code.OpReturn)),
+		}},
+		{"cut operand", &compiler.Bytecode{
+			Instructions: concat(code.Make(code.OpReturn), code.Make(code.OpConstant, 0)[:2]),
+		}},
+		{"constant", &compiler.Bytecode{
+			Instructions: concat(code.Make(code.OpConstant, 0), code.Make(code.OpReturnValue)),
+		}},
+		{"global", &compiler.Bytecode{
+			Instructions: concat(code.Make(code.OpGetGlobal, 0), code.Make(code.OpReturnValue)),
+		}},
+		{"local in main", &compiler.Bytecode{
+			Instructions: concat(code.Make(code.OpGetLocal, 0), code.Make(code.OpReturnValue)),
+		}},
+		{"builtin", &compiler.Bytecode{
+			Instructions: concat(code.Make(code.OpGetBuiltin, 200), code.Make(code.OpReturnValue)),
+		}},
+		{"jump into operand", &compiler.Bytecode{
+			Instructions: concat(code.Make(code.OpJump, 1), code.Make(code.OpReturn)),
+		}},
+		{"closure of integer", &compiler.Bytecode{
+			Instructions: concat(code.Make(code.OpClosure, 0, 0), code.Make(code.OpReturnValue)),
+			Constants:    []object.Object{&object.Integer{Value: 1}},
+		}},
+		{"closure free count", &compiler.Bytecode{
+			Instructions: concat(code.Make(code.OpClosure, 0, 1), code.Make(code.OpReturnValue)),
+			Constants:    []object.Object{fn},
+		}},
+		{"local names", &compiler.Bytecode{
+			Instructions: code.Make(code.OpReturn),
+			Constants: []object.Object{&object.CompiledFunction{
+				Instructions: fn.Instructions,
+				NumLocals:    1,
+			}},
+		}},
+		{"array longer than the stack", &compiler.Bytecode{
+			Instructions: concat(code.Make(code.OpTrue), code.Make(code.OpArray, 65535), code.Make(code.OpReturnValue)),
+		}},
+		{"interpolate on empty stack", &compiler.Bytecode{
+			Instructions: concat(code.Make(code.OpInterpolate, 1), code.Make(code.OpReturnValue)),
+		}},
+		{"odd hash", &compiler.Bytecode{
+			Instructions: concat(code.Make(code.OpTrue), code.Make(code.OpHash, 1), code.Make(code.OpReturnValue)),
+		}},
+		{"call without function", &compiler.Bytecode{
+			Instructions: concat(code.Make(code.OpTrue), code.Make(code.OpCall, 1), code.Make(code.OpReturnValue)),
+		}},
+		{"stack differs where jumps meet", &compiler.Bytecode{
+			Instructions: concat(
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 6),
+				code.Make(code.OpTrue),
+				code.Make(code.OpTrue),
+				code.Make(code.OpNull),
+				code.Make(code.OpReturnValue),
+			),
+		}},
+		{"unset kind", &compiler.Bytecode{
+			Instructions: concat(code.Make(code.OpJumpUnset, 6, 9, 0), code.Make(code.OpReturn)),
+		}},
+		{"position order", &compiler.Bytecode{
+			Instructions: concat(code.Make(code.OpTrue), code.Make(code.OpReturnValue)),
+			Positions:    code.SourceMap{{Offset: 1, Line: 1, Column: 1}, {Offset: 0, Line: 1, Column: 2}},
+		}},
+	}
+
+	for _, tt := range tests {
+		_, err := Read(bytes.NewReader(write(t, tt.bc)))
+		if !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%s: expected %v, got %v", tt.name, ErrCorrupt, err)
+		}
+	}
+
+	valid := &compiler.Bytecode{
+		Instructions: concat(code.Make(code.OpClosure, 0, 0), code.Make(code.OpReturnValue)),
+		Constants:    []object.Object{fn},
+	}
+	if _, err := Read(bytes.NewReader(write(t, valid))); err != nil {
+		t.Errorf("valid: unexpected error %s", err)
+	}
+}
+
+func TestErrorsInEnglish(t *testing.T) {
+	defer diagnostic.SetLanguage(diagnostic.CurrentLanguage())
+	diagnostic.SetLanguage(diagnostic.English)
+
+	_, err := Read(bytes.NewReader([]byte(magic + "\x00")))
+	if err == nil || err.Error() != "bytecode: the file is damaged: the header is too short" {
+		t.Errorf("wrong error: %v", err)
+	}
+}
+
+// opcodes are the opcodes added in each version of the format, with the
+// widths of their operands. The entry for a version never changes: adding or
+// changing an opcode needs a new Version and a new entry. Versions that only
+// changed how programs run have no opcodes of their own.
+var opcodes = map[int]string{
+	1: `OpConstant [2]
+OpPop []
+OpAdd []
+OpSub []
+OpMul []
+OpDiv []
+OpTrue []
+OpFalse []
+OpNull []
+OpEqual []
+OpNotEqual []
+OpGreaterThan []
+OpLessThan []
+OpMinus []
+OpBang []
+OpJumpNotTruthy [2]
+OpJump [2]
+OpGetGlobal [2]
+OpSetGlobal [2]
+OpGetLocal [1]
+OpSetLocal [1]
+OpNewCell [1]
+OpGetCell [1]
+OpSetCell [1]
+OpLoadCell [1]
+OpGetFree [1]
+OpLoadFree [1]
+OpGetBuiltin [1]
+OpArray [2]
+OpHash [2]
+OpIndex []
+OpCall [1]
+OpReturnValue []
+OpReturn []
+OpClosure [2 1]
+`,
+	2: `OpIn []
+OpInterpolate [2]
+OpJumpNull [2]
+OpJumpNotNull [2]
+`,
+	3: `OpJumpUnset [2 1 2]
+`,
+	4: "", // negative array indices
+	5: "", // null shown as ingenting
+	6: "", // float division by zero
+}
+
+func TestVersionCoversOpcodes(t *testing.T) {
+	var expected strings.Builder
+	for version := 1; version <= Version; version++ {
+		expected.WriteString(opcodes[version])
+	}
+
+	var defined strings.Builder
+	for op := 0; op < 256; op++ {
+		if def, err := code.Lookup(byte(op)); err == nil {
+			fmt.Fprintf(&defined, "%s %v\n", def.Name, def.OperandWidths)
+		}
+	}
+
+	if defined.String() != expected.String() {
+		t.Errorf("the opcodes have changed without a new Version. Increase Version and add the new opcodes to the list for it.\nwant=%s\ngot=%s", expected.String(), defined.String())
+	}
+}
@@ -0,0 +1,228 @@
+// bytecode/validate.go
+
+package bytecode
+
+import (
+	"github.com/solbero/pytonskript/code"
+	"github.com/solbero/pytonskript/compiler"
+	"github.com/solbero/pytonskript/diagnostic"
+	"github.com/solbero/pytonskript/evaluator"
+	"github.com/solbero/pytonskript/object"
+)
+
+// maxLocals is the most locals a function can have, since instructions
+// refer to them with one byte.
+const maxLocals = 256
+
+// validate checks that the instructions of a program only refer to things
+// that exist, so that a damaged file is rejected before it runs instead of
+// making the virtual machine crash.
+func validate(bc *compiler.Bytecode) error {
+	if len(bc.Globals) > 1<<16 {
+		return corrupt(diagnostic.TooManyGlobals, len(bc.Globals))
+	}
+
+	if err := validateCode(bc, diagnostic.Sprintf(diagnostic.MainProgram), bc.Instructions, bc.Positions, 0, 0); err != nil {
+		return err
+	}
+
+	for i, constant := range bc.Constants {
+		fn, ok := constant.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case fn.NumLocals > maxLocals || fn.NumParameters > fn.NumLocals:
+			return corrupt(diagnostic.InvalidLocalCount, i, fn.NumLocals, fn.NumParameters)
+		case len(fn.LocalNames) != fn.NumLocals:
+			return corrupt(diagnostic.LocalNamesMismatch, i, fn.NumLocals, len(fn.LocalNames))
+		case fn.NumFree > maxLocals:
+			return corrupt(diagnostic.TooManyFree, i, fn.NumFree)
+		}
+
+		if err := validateCode(bc, diagnostic.Sprintf(diagnostic.FunctionInConstant, i), fn.Instructions, fn.Positions, fn.NumLocals, fn.NumFree); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateCode(bc *compiler.Bytecode, where string, ins code.Instructions, positions code.SourceMap, numLocals, numFree int) error {
+	starts := map[int]bool{}
+	jumps := []int{}
+	var last code.Opcode
+
+	for ip := 0; ip < len(ins); {
+		def, err := code.Lookup(ins[ip])
+		if err != nil {
+			return corrupt(diagnostic.UnknownInstruction, where, ins[ip], ip)
+		}
+
+		width := 0
+		for _, w := range def.OperandWidths {
+			width += w
+		}
+		if ip+1+width > len(ins) {
+			return corrupt(diagnostic.TruncatedInstruction, where, def.Name, ip)
+		}
+
+		operands, _ := code.ReadOperands(def, ins[ip+1:])
+		op := code.Opcode(ins[ip])
+
+		valid := true
+		switch op {
+		case code.OpConstant:
+			valid = operands[0] < len(bc.Constants)
+		case code.OpClosure:
+			if operands[0] < len(bc.Constants) {
+				fn, ok := bc.Constants[operands[0]].(*object.CompiledFunction)
+				valid = ok && fn.NumFree == operands[1]
+			} else {
+				valid = false
+			}
+		case code.OpJump, code.OpJumpNotTruthy, code.OpJumpNull, code.OpJumpNotNull:
+			jumps = append(jumps, operands[0])
+		case code.OpJumpUnset:
+			jumps = append(jumps, operands[0])
+			switch operands[1] {
+			case code.VariableGlobal:
+				valid = operands[2] < len(bc.Globals)
+			case code.VariableLocal, code.VariableCell:
+				valid = operands[2] < numLocals
+			case code.VariableFree:
+				valid = operands[2] < numFree
+			default:
+				valid = false
+			}
+		case code.OpGetGlobal, code.OpSetGlobal:
+			valid = operands[0] < len(bc.Globals)
+		case code.OpGetLocal, code.OpSetLocal, code.OpNewCell, code.OpGetCell, code.OpSetCell, code.OpLoadCell:
+			valid = operands[0] < numLocals
+		case code.OpGetFree, code.OpLoadFree:
+			valid = operands[0] < numFree
+		case code.OpGetBuiltin:
+			valid = operands[0] < len(evaluator.BuiltinNames)
+		case code.OpHash:
+			valid = operands[0]%2 == 0
+		}
+		if !valid {
+			return corrupt(diagnostic.InvalidInstruction, where, def.Name, ip)
+		}
+
+		starts[ip] = true
+		last = op
+		ip += 1 + width
+	}
+
+	if len(ins) == 0 || (last != code.OpReturn && last != code.OpReturnValue) {
+		return corrupt(diagnostic.MissingReturn, where)
+	}
+
+	for _, target := range jumps {
+		if !starts[target] {
+			return corrupt(diagnostic.InvalidJump, where, target)
+		}
+	}
+
+	if err := checkStack(where, ins); err != nil {
+		return err
+	}
+
+	for i, pos := range positions {
+		if pos.Offset >= len(ins) || (i > 0 && pos.Offset <= positions[i-1].Offset) || pos.Line < 1 || pos.Column < 1 {
+			return corrupt(diagnostic.InvalidPosition, where, i)
+		}
+	}
+
+	return nil
+}
+
+// checkStack follows the height of the stack through the instructions, and
+// rejects instructions that take more values than there are, such as an
+// OpArray with more elements than the stack holds. Where jumps meet, the
+// height must be the same. Code after a jump or a return that no jump leads
+// to keeps the height it would have had, as the compiler emits it.
+func checkStack(where string, ins code.Instructions) error {
+	heights := map[int]int{} // at every instruction seen so far
+	targets := map[int]int{} // at jump targets not reached yet
+	height := 0
+	reachable := true
+
+	jump := func(target, height int) error {
+		h, ok := heights[target]
+		if !ok {
+			h, ok = targets[target]
+		}
+		if ok && h != height {
+			return corrupt(diagnostic.StackMismatch, where, target)
+		}
+		targets[target] = height
+		return nil
+	}
+
+	for ip := 0; ip < len(ins); {
+		def, _ := code.Lookup(ins[ip])
+		operands, width := code.ReadOperands(def, ins[ip+1:])
+		op := code.Opcode(ins[ip])
+
+		if h, ok := targets[ip]; ok {
+			if reachable && h != height {
+				return corrupt(diagnostic.StackMismatch, where, ip)
+			}
+			height = h
+		}
+		heights[ip] = height
+
+		pops, pushes := stackEffect(op, operands)
+		if pops > height {
+			return corrupt(diagnostic.StackUnderflow, where, def.Name, ip)
+		}
+		height += pushes - pops
+
+		var err error
+		switch op {
+		case code.OpJump, code.OpJumpNotTruthy, code.OpJumpNull, code.OpJumpUnset:
+			err = jump(operands[0], height)
+		case code.OpJumpNotNull:
+			// The value is popped only when it does not jump.
+			err = jump(operands[0], height+1)
+		}
+		if err != nil {
+			return err
+		}
+
+		reachable = op != code.OpJump && op != code.OpReturn && op != code.OpReturnValue
+		ip += 1 + width
+	}
+
+	return nil
+}
+
+// stackEffect returns how many values an instruction takes from the stack
+// and how many it puts back.
+func stackEffect(op code.Opcode, operands []int) (pops, pushes int) {
+	switch op {
+	case code.OpConstant, code.OpTrue, code.OpFalse, code.OpNull,
+		code.OpGetGlobal, code.OpGetLocal, code.OpGetCell, code.OpLoadCell,
+		code.OpGetFree, code.OpLoadFree, code.OpGetBuiltin:
+		return 0, 1
+	case code.OpPop, code.OpJumpNotTruthy, code.OpJumpNotNull, code.OpSetGlobal,
+		code.OpSetLocal, code.OpSetCell, code.OpReturnValue:
+		return 1, 0
+	case code.OpMinus, code.OpBang, code.OpJumpNull:
+		return 1, 1
+	case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpEqual, code.OpNotEqual,
+		code.OpGreaterThan, code.OpLessThan, code.OpIndex, code.OpIn:
+		return 2, 1
+	case code.OpArray, code.OpHash, code.OpInterpolate:
+		return operands[0], 1
+	case code.OpCall:
+		return operands[0] + 1, 1
+	case code.OpClosure:
+		return operands[1], 1
+	default:
+		return 0, 0
+	}
+}
@@ -0,0 +1,49 @@
+// check.go
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/solbero/pytonskript/checker"
+	"github.com/solbero/pytonskript/diagnostic"
+	"github.com/solbero/pytonskript/token"
+)
+
+// checkCommand implements `pytonskript sjekk fil...`, which lists likely
+// mistakes in the files without running them. It fails if an error is found,
+// or with -streng if a warning is found too.
+func checkCommand(args []string, dialect *token.Dialect) int {
+	flags := flag.NewFlagSet("sjekk", flag.ContinueOnError)
+	strict := flags.Bool("streng", false, "avslutt med feilkode også for advarsler")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "bruk: pytonskript sjekk [-streng] fil...\n")
+		return 2
+	}
+
+	status := 0
+
+	for _, path := range flags.Args() {
+		input, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+
+		for _, d := range checker.CheckSource(string(input), dialect) {
+			fmt.Printf("%s:%s\n", path, d)
+			if d.Severity == diagnostic.SeverityError || *strict {
+				status = 1
+			}
+		}
+	}
+
+	return status
+}
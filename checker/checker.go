@@ -0,0 +1,455 @@
+// checker/checker.go
+
+package checker
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/solbero/pytonskript/ast"
+	"github.com/solbero/pytonskript/diagnostic"
+	"github.com/solbero/pytonskript/evaluator"
+	"github.com/solbero/pytonskript/lexer"
+	"github.com/solbero/pytonskript/parser"
+	"github.com/solbero/pytonskript/token"
+)
+
+// CheckSource parses input and checks the program. Syntax errors are
+// returned on their own, since a program that does not parse cannot be
+// checked.
+func CheckSource(input string, dialect *token.Dialect) []*diagnostic.Diagnostic {
+	l := lexer.NewWithDialect(input, dialect)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return p.Errors()
+	}
+
+	return Check(program, l.Dialect())
+}
+
+// Check looks for common mistakes in program without running it: names that
+// are not defined, names that are defined but never used, names that shadow
+// other names, calls with the wrong number of arguments, code after
+// returner and conditions compared with sant or falskt.
+//
+// Problems that would stop the program when it runs are reported as errors,
+// the rest as warnings. The diagnostics are sorted by position.
+func Check(program *ast.Program, dialect *token.Dialect) []*diagnostic.Diagnostic {
+	c := &checker{dialect: dialect}
+
+	c.openScope()
+	c.statements(program.Statements)
+	c.closeScope()
+
+	sort.SliceStable(c.diagnostics, func(i, j int) bool {
+		return c.diagnostics[i].Pos.Offset < c.diagnostics[j].Pos.Offset
+	})
+
+	return c.diagnostics
+}
+
+// Definitions returns the identifier that binds each name used in program
+// to where it is bound by la or as a function parameter. The identifiers that
+// bind names map to themselves, and builtins and undefined names are left
+// out. The program must have parsed without errors.
+func Definitions(program *ast.Program, dialect *token.Dialect) map[*ast.Identifier]*ast.Identifier {
+	c := &checker{dialect: dialect, definitions: make(map[*ast.Identifier]*ast.Identifier)}
+
+	c.openScope()
+	c.statements(program.Statements)
+	c.closeScope()
+
+	return c.definitions
+}
+
+// binding is a name bound by la or as a function parameter.
+type binding struct {
+	name      *ast.Identifier
+	parameter bool
+	used      bool
+
+	// function is the function literal bound to the name, if any, so calls
+	// through the name can be checked.
+	function *ast.FunctionLiteral
+}
+
+// scope holds the names of a program or a function body. Blocks in hvis do
+// not make scopes of their own, just like when the program runs.
+type scope struct {
+	outer *scope
+
+	bindings map[string]*binding   // the latest binding of each name so far
+	pending  map[string][]*binding // bindings further down in the scope
+	all      []*binding
+}
+
+type checker struct {
+	dialect     *token.Dialect
+	scope       *scope
+	diagnostics []*diagnostic.Diagnostic
+	definitions map[*ast.Identifier]*ast.Identifier // only kept if not nil
+}
+
+func (c *checker) define(ident *ast.Identifier, b *binding) {
+	if c.definitions != nil {
+		c.definitions[ident] = b.name
+	}
+}
+
+func (c *checker) error(code diagnostic.Code, pos token.Position, a ...interface{}) {
+	c.diagnostics = append(c.diagnostics, diagnostic.New(code, pos, a...))
+}
+
+func (c *checker) warn(code diagnostic.Code, pos token.Position, a ...interface{}) {
+	c.diagnostics = append(c.diagnostics, diagnostic.NewWarning(code, pos, a...))
+}
+
+func (c *checker) openScope() {
+	c.scope = &scope{
+		outer:    c.scope,
+		bindings: make(map[string]*binding),
+		pending:  make(map[string][]*binding),
+	}
+}
+
+func (c *checker) closeScope() {
+	for _, b := range c.scope.all {
+		if !b.used && !b.parameter && !strings.HasPrefix(b.name.Value, "_") {
+			c.warn(diagnostic.UnusedName, b.name.Token.Pos, b.name.Value)
+		}
+	}
+	c.scope = c.scope.outer
+}
+
+// declareAhead records the la statements in stmts, so that functions that
+// refer to names defined further down can be checked.
+func (c *checker) declareAhead(stmts []ast.Statement) {
+	forEachLet(stmts, func(let *ast.LetStatement) {
+		b := &binding{name: let.Name}
+		if fn, ok := let.Value.(*ast.FunctionLiteral); ok {
+			b.function = fn
+		}
+		c.scope.pending[let.Name.Value] = append(c.scope.pending[let.Name.Value], b)
+		c.scope.all = append(c.scope.all, b)
+	})
+}
+
+// bind makes the pending binding of name visible from here on.
+func (c *checker) bind(name *ast.Identifier) {
+	pending := c.scope.pending[name.Value]
+	var b *binding
+	for i, p := range pending {
+		if p.name == name {
+			b = p
+			c.scope.pending[name.Value] = append(pending[:i:i], pending[i+1:]...)
+			break
+		}
+	}
+
+	if _, ok := c.scope.bindings[name.Value]; !ok {
+		c.checkShadowing(name)
+	}
+	c.scope.bindings[name.Value] = b
+	c.define(name, b)
+}
+
+func (c *checker) bindParameter(name *ast.Identifier) {
+	if _, ok := c.scope.bindings[name.Value]; !ok {
+		c.checkShadowing(name)
+	}
+	b := &binding{name: name, parameter: true}
+	c.scope.bindings[name.Value] = b
+	c.define(name, b)
+	c.scope.all = append(c.scope.all, b)
+}
+
+func (c *checker) checkShadowing(name *ast.Identifier) {
+	for s := c.scope.outer; s != nil; s = s.outer {
+		if b := s.lookup(name.Value); b != nil {
+			c.warn(diagnostic.ShadowedName, name.Token.Pos, name.Value, b.name.Token.Pos.Line)
+			return
+		}
+	}
+
+	if _, ok := c.builtin(name.Value); ok {
+		c.warn(diagnostic.ShadowedBuiltin, name.Token.Pos, name.Value)
+	}
+}
+
+// lookup returns a binding of name anywhere in the scope.
+func (s *scope) lookup(name string) *binding {
+	if b, ok := s.bindings[name]; ok {
+		return b
+	}
+	if pending := s.pending[name]; len(pending) > 0 {
+		return pending[0]
+	}
+	return nil
+}
+
+// resolve finds the binding ident refers to and marks it as used. Names in
+// the current scope must be bound before they are used, but a function body
+// may use names bound anywhere in the scopes around it, since they are looked
+// up when the function is called. It returns nil for builtins and undefined
+// names.
+func (c *checker) resolve(ident *ast.Identifier) *binding {
+	for s := c.scope; s != nil; s = s.outer {
+		if s == c.scope {
+			if b, ok := s.bindings[ident.Value]; ok {
+				b.used = true
+				c.define(ident, b)
+				return b
+			}
+			continue
+		}
+
+		// The function may be called after the name is bound again, so
+		// every binding of the name counts as used.
+		var found *binding
+		if b, ok := s.bindings[ident.Value]; ok {
+			b.used = true
+			found = b
+		}
+		for _, b := range s.pending[ident.Value] {
+			b.used = true
+			if found == nil {
+				found = b
+			}
+		}
+		if found != nil {
+			c.define(ident, found)
+			return found
+		}
+	}
+
+	if _, ok := c.builtin(ident.Value); !ok {
+		c.error(diagnostic.IdentifierNotFound, ident.Token.Pos, ident.Value)
+	}
+	return nil
+}
+
+// builtin returns the builtin function called name in the dialect.
+func (c *checker) builtin(name string) (string, bool) {
+	canonical, ok := c.dialect.Builtin(name)
+	if !ok {
+		return "", false
+	}
+	if _, ok := evaluator.LookupBuiltin(canonical); !ok {
+		return "", false
+	}
+	return canonical, true
+}
+
+func (c *checker) statements(stmts []ast.Statement) {
+	c.declareAhead(stmts)
+	c.block(stmts)
+}
+
+// block checks stmts in the current scope, whose la statements have already
+// been declared ahead.
+func (c *checker) block(stmts []ast.Statement) {
+	returned := false
+	for _, stmt := range stmts {
+		if returned {
+			keyword, _ := c.dialect.Keyword(token.RETURN)
+			c.warn(diagnostic.UnreachableCode, statementPos(stmt), keyword)
+			returned = false
+		}
+
+		c.statement(stmt)
+
+		if _, ok := stmt.(*ast.ReturnStatement); ok {
+			returned = true
+		}
+	}
+}
+
+func (c *checker) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		c.expression(stmt.Value)
+		c.bind(stmt.Name)
+	case *ast.ReturnStatement:
+		c.expression(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		c.expression(stmt.Expression)
+	case *ast.BlockStatement:
+		c.block(stmt.Statements)
+	}
+}
+
+func (c *checker) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		c.resolve(exp)
+	case *ast.PrefixExpression:
+		c.expression(exp.Right)
+	case *ast.InfixExpression:
+		c.expression(exp.Left)
+		c.expression(exp.Right)
+	case *ast.IfExpression:
+		c.condition(exp.Condition)
+		c.expression(exp.Condition)
+		c.block(exp.Consequence.Statements)
+		if exp.Alternative != nil {
+			c.block(exp.Alternative.Statements)
+		}
+	case *ast.FunctionLiteral:
+		c.openScope()
+		for _, param := range exp.Parameters {
+			c.bindParameter(param)
+		}
+		c.statements(exp.Body.Statements)
+		c.closeScope()
+	case *ast.MacroLiteral:
+		c.openScope()
+		for _, param := range exp.Parameters {
+			c.bindParameter(param)
+		}
+		c.statements(exp.Body.Statements)
+		c.closeScope()
+	case *ast.CallExpression:
+		if c.isQuote(exp) {
+			c.quote(exp)
+			return
+		}
+		c.call(exp)
+		for _, arg := range exp.Arguments {
+			c.expression(arg)
+		}
+	case *ast.IndexExpression:
+		c.expression(exp.Left)
+		c.expression(exp.Index)
+	case *ast.InterpolatedString:
+		for _, part := range exp.Parts {
+			c.expression(part)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			c.expression(el)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range exp.Pairs {
+			c.expression(pair.Key)
+			c.expression(pair.Value)
+		}
+	}
+}
+
+// call checks the number of arguments in calls to builtins and to functions
+// bound by la.
+func (c *checker) call(call *ast.CallExpression) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		c.expression(call.Function)
+		return
+	}
+
+	got := len(call.Arguments)
+	pos := ident.Token.Pos
+
+	b := c.resolve(ident)
+	if b != nil {
+		if b.function == nil {
+			return
+		}
+		if want := len(b.function.Parameters); got != want {
+			c.error(diagnostic.WrongArgumentCount, pos, got, want)
+		}
+		return
+	}
+
+	name, ok := c.builtin(ident.Value)
+	if !ok {
+		return
+	}
+	builtin, _ := evaluator.LookupBuiltin(name)
+	switch {
+	case builtin.MinArgs == builtin.MaxArgs && got != builtin.MinArgs:
+		c.error(diagnostic.WrongArgumentCount, pos, got, builtin.MinArgs)
+	case got < builtin.MinArgs:
+		c.error(diagnostic.TooFewArguments, pos, got, builtin.MinArgs)
+	case builtin.MaxArgs >= 0 && got > builtin.MaxArgs:
+		c.error(diagnostic.TooManyArguments, pos, got, builtin.MaxArgs)
+	}
+}
+
+// isQuote reports whether call is a call to sitat, whose argument is kept as
+// it is written.
+func (c *checker) isQuote(call *ast.CallExpression) bool {
+	return c.isSpecialCall(call, "sitat")
+}
+
+func (c *checker) isSpecialCall(call *ast.CallExpression, name string) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return false
+	}
+	canonical, _ := c.dialect.Builtin(ident.Value)
+	return canonical == name
+}
+
+// quote checks the arguments to avsitat in a call to sitat. The rest of the
+// quoted code is not run where it is written, so the names in it need not be
+// defined there.
+func (c *checker) quote(call *ast.CallExpression) {
+	for _, arg := range call.Arguments {
+		ast.Inspect(arg, func(node ast.Node) bool {
+			unquote, ok := node.(*ast.CallExpression)
+			if !ok || !c.isSpecialCall(unquote, "avsitat") {
+				return true
+			}
+			for _, arg := range unquote.Arguments {
+				c.expression(arg)
+			}
+			return false
+		})
+	}
+}
+
+// condition warns about conditions such as `x == sant`, which mean the same
+// as `x`.
+func (c *checker) condition(exp ast.Expression) {
+	infix, ok := exp.(*ast.InfixExpression)
+	if !ok || (infix.Operator != "==" && infix.Operator != "!=") {
+		return
+	}
+
+	for _, side := range []ast.Expression{infix.Left, infix.Right} {
+		if boolean, ok := side.(*ast.Boolean); ok {
+			c.warn(diagnostic.RedundantBoolComparison, infix.Token.Pos, boolean.Token.Literal)
+			return
+		}
+	}
+}
+
+// forEachLet calls fn for every la statement in stmts, including those in
+// the blocks of hvis, but not those in function or macro bodies.
+func forEachLet(stmts []ast.Statement, fn func(*ast.LetStatement)) {
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.FunctionLiteral, *ast.MacroLiteral:
+				return false
+			case *ast.LetStatement:
+				fn(node)
+			}
+			return true
+		})
+	}
+}
+
+func statementPos(stmt ast.Statement) token.Position {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token.Pos
+	case *ast.ReturnStatement:
+		return stmt.Token.Pos
+	case *ast.ExpressionStatement:
+		return stmt.Token.Pos
+	default:
+		return token.Position{}
+	}
+}
@@ -0,0 +1,209 @@
+// checker/checker_test.go
+
+package checker
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/solbero/pytonskript/diagnostic"
+	"github.com/solbero/pytonskript/lexer"
+	"github.com/solbero/pytonskript/parser"
+	"github.com/solbero/pytonskript/token"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"la x = 1; skriv(x);",
+			[]string{},
+		},
+		{
+			"skriv(y);",
+			[]string{"1:7: navnet er ikke definert: y [K001]"},
+		},
+		{
+			"skriv(x); la x = 1;",
+			[]string{
+				"1:7: navnet er ikke definert: x [K001]",
+				"1:14: advarsel: 'x' er definert, men blir aldri brukt [A001]",
+			},
+		},
+		{
+			"la x = 1;",
+			[]string{"1:4: advarsel: 'x' er definert, men blir aldri brukt [A001]"},
+		},
+		{
+			"la _x = 1;",
+			[]string{},
+		},
+		{
+			"la x = 1; la x = x + 1; skriv(x);",
+			[]string{},
+		},
+		{
+			"la f = funksjon(n) { hvis (n < 1) { 0 } ellers { f(n - 1) } }; f(3);",
+			[]string{},
+		},
+		{
+			"la a = funksjon() { b() }; la b = funksjon() { 1 }; a();",
+			[]string{},
+		},
+		{
+			"la x = 1; la f = funksjon(x) { x }; f(x);",
+			[]string{"1:27: advarsel: 'x' skygger for navnet som er definert på linje 1 [A002]"},
+		},
+		{
+			"la f = funksjon() { la y = 2; y }; la y = 1; skriv(f(), y);",
+			[]string{"1:24: advarsel: 'y' skygger for navnet som er definert på linje 1 [A002]"},
+		},
+		{
+			"la lengde = 1; skriv(lengde);",
+			[]string{"1:4: advarsel: 'lengde' skygger for den innebygde funksjonen med samme navn [A003]"},
+		},
+		{
+			"hvis (sant) { la x = 1 }; skriv(x);",
+			[]string{},
+		},
+		{
+			"la f = funksjon(a, b) { a + b }; f(1); f(1, 2, 3);",
+			[]string{
+				"1:34: feil antall argumenter, fikk 1, forventet 2 [K008]",
+				"1:40: feil antall argumenter, fikk 3, forventet 2 [K008]",
+			},
+		},
+		{
+			`lengde(); kutt([1]); kutt([1], 0, 1, 2); skriv();`,
+			[]string{
+				"1:1: feil antall argumenter, fikk 0, forventet 1 [K008]",
+				"1:11: feil antall argumenter, fikk 1, forventet minst 2 [K009]",
+				"1:22: feil antall argumenter, fikk 4, forventet høyst 3 [K010]",
+			},
+		},
+		{
+			"la f = funksjon() { returner 1; skriv(2); skriv(3) }; f();",
+			[]string{"1:33: advarsel: koden etter 'returner' blir aldri kjørt [A004]"},
+		},
+		{
+			"la x = sant; hvis (x == sant) { 1 }; hvis (falskt != x) { 2 }",
+			[]string{
+				"1:22: advarsel: unødvendig sammenligning med sant, bruk betingelsen direkte [A005]",
+				"1:51: advarsel: unødvendig sammenligning med falskt, bruk betingelsen direkte [A005]",
+			},
+		},
+		{
+			"la x = ;",
+			[]string{"1:8: uventet ';' i starten av et uttrykk [S002]"},
+		},
+	}
+
+	for i, tt := range tests {
+		diagnostics := CheckSource(tt.input, token.Bokmal)
+
+		if len(diagnostics) != len(tt.expected) {
+			t.Errorf("tests[%d] - wrong number of diagnostics, expected %d, got %d: %v",
+				i, len(tt.expected), len(diagnostics), diagnostics)
+			continue
+		}
+
+		for j, d := range diagnostics {
+			if d.Error() != tt.expected[j] {
+				t.Errorf("tests[%d][%d] - wrong diagnostic, expected %q, got %q", i, j, tt.expected[j], d.Error())
+			}
+		}
+	}
+}
+
+func TestCheckMacros(t *testing.T) {
+	input := `la m = makro(a) { la b = 1; sitat(avsitat(a) + c + avsitat(e)) }; m(d)`
+
+	diagnostics := CheckSource(input, token.Bokmal)
+
+	expected := []diagnostic.Code{diagnostic.UnusedName, diagnostic.IdentifierNotFound, diagnostic.IdentifierNotFound}
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("wrong number of diagnostics, expected %d, got %d: %v", len(expected), len(diagnostics), diagnostics)
+	}
+
+	for i, d := range diagnostics {
+		if d.Code != expected[i] {
+			t.Errorf("diagnostics[%d] - wrong code, expected %s, got %s", i, expected[i], d.Code)
+		}
+	}
+}
+
+func TestCheckDialect(t *testing.T) {
+	input := "let f = fn(len) { len }; return puts(f(1), first());"
+
+	diagnostics := CheckSource(input, token.English)
+
+	expected := []diagnostic.Code{diagnostic.ShadowedBuiltin, diagnostic.WrongArgumentCount}
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("wrong number of diagnostics, expected %d, got %d: %v", len(expected), len(diagnostics), diagnostics)
+	}
+
+	for i, d := range diagnostics {
+		if d.Code != expected[i] {
+			t.Errorf("diagnostics[%d] - wrong code, expected %s, got %s", i, expected[i], d.Code)
+		}
+	}
+}
+
+func TestCheckSeverity(t *testing.T) {
+	diagnostics := CheckSource("la x = 1; y", token.Bokmal)
+
+	if len(diagnostics) != 2 {
+		t.Fatalf("wrong number of diagnostics, expected 2, got %d: %v", len(diagnostics), diagnostics)
+	}
+
+	if diagnostics[0].Severity != diagnostic.SeverityWarning {
+		t.Errorf("unused name should be a warning")
+	}
+
+	if diagnostics[1].Severity != diagnostic.SeverityError {
+		t.Errorf("undefined name should be an error")
+	}
+}
+
+func TestDefinitions(t *testing.T) {
+	input := `la x = 1;
+la f = funksjon(x) { x + y };
+la y = f(x);
+lengde(y);`
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	definitions := Definitions(program, token.Bokmal)
+
+	// Each use, as line:column, and the line:column of its definition.
+	expected := map[string]string{
+		"1:4":  "1:4",
+		"2:4":  "2:4",
+		"2:17": "2:17",
+		"2:22": "2:17",
+		"2:26": "3:4",
+		"3:4":  "3:4",
+		"3:8":  "2:4",
+		"3:10": "1:4",
+		"4:8":  "3:4",
+	}
+
+	if len(definitions) != len(expected) {
+		t.Errorf("wrong number of definitions, expected %d, got %d", len(expected), len(definitions))
+	}
+
+	for use, def := range definitions {
+		key := fmt.Sprintf("%d:%d", use.Token.Pos.Line, use.Token.Pos.Column)
+		got := fmt.Sprintf("%d:%d", def.Token.Pos.Line, def.Token.Pos.Column)
+		if expected[key] != got {
+			t.Errorf("definition of %s at %s wrong, expected %s, got %s", use.Value, key, expected[key], got)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+// code/code.go
+
+// Package code defines the bytecode instructions the compiler produces and
+// the virtual machine runs.
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+type Instructions []byte
+
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	case 3:
+		return fmt.Sprintf("%s %d %d %d", def.Name, operands[0], operands[1], operands[2])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpPop
+
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+
+	OpTrue
+	OpFalse
+	OpNull
+
+	OpEqual
+	OpNotEqual
+	OpGreaterThan
+	OpLessThan
+
+	OpMinus
+	OpBang
+
+	OpJumpNotTruthy
+	OpJump
+
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+
+	// Locals that closures refer to live in cells, so that the closures see
+	// the values they are given later. OpNewCell puts the value of a local in
+	// a new cell, OpGetCell and OpSetCell read and write the value in it, and
+	// OpLoadCell pushes the cell itself for OpClosure to capture.
+	OpNewCell
+	OpGetCell
+	OpSetCell
+	OpLoadCell
+
+	// OpGetFree pushes the value in a cell the closure captured, and
+	// OpLoadFree pushes the cell itself, for closures made inside closures.
+	OpGetFree
+	OpLoadFree
+
+	OpGetBuiltin
+
+	OpArray
+	OpHash
+	OpIndex
+
+	OpCall
+	OpReturnValue
+	OpReturn
+	OpClosure
+
+	// New opcodes are added at the end, so that the ones above keep their
+	// numbers and programs compiled earlier still run.
+
+	// OpIn pushes whether the value below the top is in the collection on
+	// top: a key in a hash, an element in a list or a part of a string.
+	OpIn
+
+	// OpInterpolate joins the values on top of the stack, as Inspect shows
+	// them, into one string.
+	OpInterpolate
+
+	// OpJumpNull jumps if the value on top of the stack is null, and leaves
+	// it there either way. OpJumpNotNull jumps if it is not null, and pops it
+	// if it is.
+	OpJumpNull
+	OpJumpNotNull
+
+	// OpJumpUnset jumps if a variable is not set, as a name bound with la is
+	// not until the la has run. The operands are the target, the kind of
+	// variable and its index.
+	OpJumpUnset
+
+	// opCount is the number of opcodes, and stays after the last one.
+	opCount
+)
+
+// The kinds of variables OpJumpUnset looks at.
+const (
+	VariableGlobal = iota
+	VariableLocal
+	VariableCell
+	VariableFree
+)
+
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+
+	OpAdd: {"OpAdd", []int{}},
+	OpSub: {"OpSub", []int{}},
+	OpMul: {"OpMul", []int{}},
+	OpDiv: {"OpDiv", []int{}},
+
+	OpTrue:  {"OpTrue", []int{}},
+	OpFalse: {"OpFalse", []int{}},
+	OpNull:  {"OpNull", []int{}},
+
+	OpEqual:       {"OpEqual", []int{}},
+	OpNotEqual:    {"OpNotEqual", []int{}},
+	OpGreaterThan: {"OpGreaterThan", []int{}},
+	OpLessThan:    {"OpLessThan", []int{}},
+
+	OpMinus: {"OpMinus", []int{}},
+	OpBang:  {"OpBang", []int{}},
+
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpJump:          {"OpJump", []int{2}},
+
+	OpGetGlobal: {"OpGetGlobal", []int{2}},
+	OpSetGlobal: {"OpSetGlobal", []int{2}},
+	OpGetLocal:  {"OpGetLocal", []int{1}},
+	OpSetLocal:  {"OpSetLocal", []int{1}},
+
+	OpNewCell:  {"OpNewCell", []int{1}},
+	OpGetCell:  {"OpGetCell", []int{1}},
+	OpSetCell:  {"OpSetCell", []int{1}},
+	OpLoadCell: {"OpLoadCell", []int{1}},
+
+	OpGetFree:  {"OpGetFree", []int{1}},
+	OpLoadFree: {"OpLoadFree", []int{1}},
+
+	OpGetBuiltin: {"OpGetBuiltin", []int{1}},
+
+	OpArray: {"OpArray", []int{2}},
+	OpHash:  {"OpHash", []int{2}},
+	OpIndex: {"OpIndex", []int{}},
+
+	OpCall:        {"OpCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+	OpClosure:     {"OpClosure", []int{2, 1}},
+
+	OpIn:          {"OpIn", []int{}},
+	OpInterpolate: {"OpInterpolate", []int{2}},
+	OpJumpNull:    {"OpJumpNull", []int{2}},
+	OpJumpNotNull: {"OpJumpNotNull", []int{2}},
+	OpJumpUnset:   {"OpJumpUnset", []int{2, 1, 2}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+
+	return def, nil
+}
+
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 { return uint8(ins[0]) }
+
+// SourcePosition says that the instructions from Offset on come from the
+// source at Line and Column, which count from 1.
+type SourcePosition struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+// SourceMap maps instructions back to the source. It is ordered by Offset.
+type SourceMap []SourcePosition
+
+// Lookup returns the position of the instruction at offset, or false if the
+// map has none for it.
+func (m SourceMap) Lookup(offset int) (SourcePosition, bool) {
+	i := sort.Search(len(m), func(i int) bool { return m[i].Offset > offset })
+	if i == 0 {
+		return SourcePosition{}, false
+	}
+	return m[i-1], true
+}
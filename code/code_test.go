@@ -0,0 +1,130 @@
+// code/code_test.go
+
+package code
+
+import "testing"
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpAdd, []int{}, []byte{byte(OpAdd)}},
+		{OpGetLocal, []int{255}, []byte{byte(OpGetLocal), 255}},
+		{OpClosure, []int{65534, 255}, []byte{byte(OpClosure), 255, 254, 255}},
+		{OpJumpUnset, []int{65534, VariableFree, 2}, []byte{byte(OpJumpUnset), 255, 254, VariableFree, 0, 2}},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		if len(instruction) != len(tt.expected) {
+			t.Errorf("instruction has wrong length. want=%d, got=%d", len(tt.expected), len(instruction))
+			continue
+		}
+
+		for i, b := range tt.expected {
+			if instruction[i] != b {
+				t.Errorf("wrong byte at pos %d. want=%d, got=%d", i, b, instruction[i])
+			}
+		}
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	instructions := []Instructions{
+		Make(OpAdd),
+		Make(OpGetLocal, 1),
+		Make(OpConstant, 2),
+		Make(OpConstant, 65535),
+		Make(OpClosure, 65535, 255),
+		Make(OpJumpUnset, 3, VariableCell, 1),
+	}
+
+	expected := `0000 OpAdd
+0001 OpGetLocal 1
+0003 OpConstant 2
+0006 OpConstant 65535
+0009 OpClosure 65535 255
+0013 OpJumpUnset 3 2 1
+`
+
+	concatted := Instructions{}
+	for _, ins := range instructions {
+		concatted = append(concatted, ins...)
+	}
+
+	if concatted.String() != expected {
+		t.Errorf("instructions wrongly formatted.\nwant=%q\ngot=%q", expected, concatted.String())
+	}
+}
+
+func TestReadOperands(t *testing.T) {
+	tests := []struct {
+		op        Opcode
+		operands  []int
+		bytesRead int
+	}{
+		{OpConstant, []int{65535}, 2},
+		{OpGetLocal, []int{255}, 1},
+		{OpClosure, []int{65535, 255}, 3},
+		{OpJumpUnset, []int{65535, VariableGlobal, 65535}, 5},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		def, err := Lookup(byte(tt.op))
+		if err != nil {
+			t.Fatalf("definition not found: %q\n", err)
+		}
+
+		operandsRead, n := ReadOperands(def, instruction[1:])
+		if n != tt.bytesRead {
+			t.Fatalf("n wrong. want=%d, got=%d", tt.bytesRead, n)
+		}
+
+		for i, want := range tt.operands {
+			if operandsRead[i] != want {
+				t.Errorf("operand wrong. want=%d, got=%d", want, operandsRead[i])
+			}
+		}
+	}
+}
+
+func TestEveryOpcodeDefined(t *testing.T) {
+	for op := OpConstant; op < opCount; op++ {
+		if _, err := Lookup(byte(op)); err != nil {
+			t.Errorf("opcode %d has no definition", op)
+		}
+	}
+}
+
+func TestSourceMapLookup(t *testing.T) {
+	m := SourceMap{{Offset: 0, Line: 1, Column: 1}, {Offset: 3, Line: 1, Column: 5}, {Offset: 7, Line: 2, Column: 1}}
+
+	tests := []struct {
+		offset   int
+		expected int // the column
+	}{
+		{0, 1},
+		{2, 1},
+		{3, 5},
+		{6, 5},
+		{7, 1},
+		{100, 1},
+	}
+
+	for _, tt := range tests {
+		pos, ok := m.Lookup(tt.offset)
+		if !ok || pos.Column != tt.expected {
+			t.Errorf("wrong position for offset %d, expected column %d, got %+v", tt.offset, tt.expected, pos)
+		}
+	}
+
+	if _, ok := (SourceMap{{Offset: 2, Line: 1, Column: 1}}).Lookup(1); ok {
+		t.Errorf("offsets before the first position should have none")
+	}
+}
@@ -0,0 +1,735 @@
+// compiler/compiler.go
+
+// Package compiler compiles programs to bytecode for the virtual machine.
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/solbero/pytonskript/ast"
+	"github.com/solbero/pytonskript/code"
+	"github.com/solbero/pytonskript/diagnostic"
+	"github.com/solbero/pytonskript/evaluator"
+	"github.com/solbero/pytonskript/object"
+	"github.com/solbero/pytonskript/token"
+)
+
+// The most arguments a call and locals a function can have, since the
+// argument count and the local indices are operands of one byte.
+const (
+	maxArguments = 255
+	maxLocals    = 256
+)
+
+type Compiler struct {
+	dialect     *token.Dialect
+	constants   []object.Object
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	// position is where in the source the node being compiled is.
+	position token.Position
+
+	// err is the first operand found too large for its instruction, which
+	// Compile returns when it is done.
+	err error
+}
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+// CompilationScope holds the instructions of the function being compiled.
+type CompilationScope struct {
+	instructions        code.Instructions
+	positions           code.SourceMap
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+
+	// scope is the analysis of the function, or the program, being compiled.
+	scope *scope
+}
+
+// Bytecode is a compiled program. Globals are the names of the global
+// variables, by index.
+type Bytecode struct {
+	Instructions code.Instructions
+	Positions    code.SourceMap
+	Constants    []object.Object
+	Globals      []string
+}
+
+// New returns a compiler for programs whose builtins are named in dialect.
+func New(dialect *token.Dialect) *Compiler {
+	return &Compiler{
+		dialect:     dialect,
+		constants:   []object.Object{},
+		symbolTable: NewSymbolTable(),
+		scopes:      []CompilationScope{{}},
+	}
+}
+
+// NewWithState returns a compiler that goes on from the globals and
+// constants of earlier programs, as the REPL does line by line.
+func NewWithState(dialect *token.Dialect, s *SymbolTable, constants []object.Object) *Compiler {
+	c := New(dialect)
+	c.symbolTable = s
+	c.constants = constants
+	return c
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	if pos, ok := nodePosition(node); ok {
+		outer := c.position
+		c.position = pos
+		defer func() { c.position = outer }()
+	}
+
+	switch node := node.(type) {
+	case *ast.Program:
+		s := analyse(nil, node.Statements)
+		for _, name := range s.declared {
+			c.symbolTable.Define(name)
+		}
+		c.scopes[c.scopeIndex].scope = s
+		return c.compileBody(node.Statements)
+
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.LetStatement:
+		symbol := c.symbolTable.Define(node.Name.Value)
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.setSymbol(symbol)
+
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+		// A block leaves the value of its last expression on the stack, or
+		// null if it does not end in one.
+		if c.lastInstructionIs(code.OpPop) && endsInExpression(node.Statements) {
+			c.removeLastPop()
+		} else {
+			c.emit(code.OpNull)
+		}
+
+	case *ast.Identifier:
+		b := maybeBound
+		if s := c.scopes[c.scopeIndex].scope; s != nil {
+			b = s.uses[node]
+		}
+		c.loadName(node.Value, b)
+
+	case *ast.IntegerLiteral:
+		integer := &object.Integer{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(integer))
+
+	case *ast.StringLiteral:
+		str := &object.String{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(str))
+
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			if err := c.Compile(part); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpInterpolate, len(node.Parts))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.NullLiteral:
+		c.emit(code.OpNull)
+
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			if err := c.Compile(pair.Key); err != nil {
+				return err
+			}
+			if err := c.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return c.newError(diagnostic.UnknownOperator, node.Operator)
+		}
+
+	case *ast.InfixExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+
+		// The right side of ?? is only run when the left side is null.
+		if node.Token.Type == token.NULLISH {
+			jumpPos := c.emit(code.OpJumpNotNull, 9999)
+			if err := c.Compile(node.Right); err != nil {
+				return err
+			}
+			c.changeOperand(jumpPos, len(c.currentInstructions()))
+			break
+		}
+
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+
+		// i is spelled differently in each dialect.
+		if node.Token.Type == token.IN {
+			c.emit(code.OpIn)
+			break
+		}
+
+		switch node.Operator {
+		case "+":
+			c.emit(code.OpAdd)
+		case "-":
+			c.emit(code.OpSub)
+		case "*":
+			c.emit(code.OpMul)
+		case "/":
+			c.emit(code.OpDiv)
+		case ">":
+			c.emit(code.OpGreaterThan)
+		case "<":
+			c.emit(code.OpLessThan)
+		case "==":
+			c.emit(code.OpEqual)
+		case "!=":
+			c.emit(code.OpNotEqual)
+		default:
+			return c.newError(diagnostic.UnknownOperator, node.Operator)
+		}
+
+	case *ast.IfExpression:
+		if err := c.Compile(node.Condition); err != nil {
+			return err
+		}
+
+		// Emit an `OpJumpNotTruthy` with a bogus value
+		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+		if err := c.Compile(node.Consequence); err != nil {
+			return err
+		}
+
+		// Emit an `OpJump` with a bogus value
+		jumpPos := c.emit(code.OpJump, 9999)
+
+		c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+		if node.Alternative == nil {
+			c.emit(code.OpNull)
+		} else {
+			if err := c.Compile(node.Alternative); err != nil {
+				return err
+			}
+		}
+
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+
+		// ?[ leaves a null it indexes on the stack as the result.
+		jumpPos := -1
+		if node.Token.Type == token.OPTIONAL_LBRACKET {
+			jumpPos = c.emit(code.OpJumpNull, 9999)
+		}
+
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+
+		if jumpPos >= 0 {
+			c.changeOperand(jumpPos, len(c.currentInstructions()))
+		}
+
+	case *ast.FunctionLiteral:
+		return c.compileFunction(node)
+
+	case *ast.CallExpression:
+		if len(node.Arguments) > maxArguments {
+			return c.newError(diagnostic.TooManyCallArguments, len(node.Arguments), maxArguments)
+		}
+		if c.isSpecialCall(node, "sitat") {
+			return c.compileQuote(node)
+		}
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+
+	default:
+		return c.newError(diagnostic.CannotCompile, fmt.Sprintf("%T", node))
+	}
+
+	return c.err
+}
+
+// isSpecialCall reports whether call calls the special form with the bokmål
+// name, spelled as in the dialect of the program.
+func (c *Compiler) isSpecialCall(call *ast.CallExpression, name string) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return false
+	}
+	canonical, _ := c.dialect.Builtin(ident.Value)
+	return canonical == name
+}
+
+// compileQuote compiles a call to sitat outside a macro to a constant holding
+// the quoted syntax tree, as the evaluator gives. The calls to avsitat in it
+// would need the evaluator to run them, so they cannot be compiled.
+func (c *Compiler) compileQuote(call *ast.CallExpression) error {
+	name := call.Function.(*ast.Identifier).Value
+	if len(call.Arguments) != 1 {
+		return c.newError(diagnostic.QuoteArgumentCount, name, len(call.Arguments))
+	}
+
+	var unquote *ast.CallExpression
+	ast.Inspect(call.Arguments[0], func(node ast.Node) bool {
+		if call, ok := node.(*ast.CallExpression); ok && unquote == nil && c.isSpecialCall(call, "avsitat") {
+			unquote = call
+		}
+		return unquote == nil
+	})
+	if unquote != nil {
+		if pos, ok := nodePosition(unquote); ok {
+			c.position = pos
+		}
+		return c.newError(diagnostic.UnquoteNotCompiled, unquote.Function.(*ast.Identifier).Value)
+	}
+
+	quote := &object.Quote{Node: ast.Copy(call.Arguments[0])}
+	c.emit(code.OpConstant, c.addConstant(quote))
+	return c.err
+}
+
+// compileBody compiles the statements of a function or the program, which
+// returns the value of its last expression.
+func (c *Compiler) compileBody(stmts []ast.Statement) error {
+	for _, s := range stmts {
+		if err := c.Compile(s); err != nil {
+			return err
+		}
+	}
+
+	if c.lastInstructionIs(code.OpPop) && endsInExpression(stmts) {
+		c.replaceLastPopWithReturn()
+	} else {
+		c.emit(code.OpReturn)
+	}
+
+	return c.err
+}
+
+func (c *Compiler) compileFunction(node *ast.FunctionLiteral) error {
+	var outerBound map[string]bool
+	if outer := c.scopes[c.scopeIndex].scope; outer != nil {
+		outerBound = outer.boundAt[node]
+	}
+
+	c.enterScope()
+	c.symbolTable.outerBound = outerBound
+
+	s := analyse(node.Parameters, node.Body.Statements)
+	c.scopes[c.scopeIndex].scope = s
+	if len(s.declared) > maxLocals {
+		return c.newError(diagnostic.TooManyLocals, len(s.declared), maxLocals)
+	}
+	for _, name := range s.declared {
+		if s.captured[name] {
+			symbol := c.symbolTable.defineCell(name)
+			c.emit(code.OpNewCell, symbol.Index)
+		} else {
+			c.symbolTable.Define(name)
+		}
+	}
+
+	if err := c.compileBody(node.Body.Statements); err != nil {
+		return err
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.NumDefinitions()
+	localNames := c.symbolTable.Names()
+	positions := c.scopes[c.scopeIndex].positions
+	instructions := c.leaveScope()
+
+	for _, symbol := range freeSymbols {
+		c.loadCell(symbol)
+	}
+
+	fn := &object.CompiledFunction{
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		NumFree:       len(freeSymbols),
+		Positions:     positions,
+		LocalNames:    localNames,
+		Literal:       (&object.Function{Parameters: node.Parameters, Body: node.Body}).Inspect(),
+	}
+
+	c.emit(code.OpClosure, c.addConstant(fn), len(freeSymbols))
+	return c.err
+}
+
+// loadName pushes the value of a name. b tells whether the name is bound in
+// the function being compiled. If it may not be, the variables it
+// may be in are tried from the inside out, and the builtin with the name, if
+// there is one, is used when none of them is set. Names that are neither
+// bound nor builtins become globals that are never set, so that using them
+// is an error when the program runs, as in the evaluator.
+func (c *Compiler) loadName(name string, b binding) {
+	builtin := -1
+	if name, ok := c.dialect.Builtin(name); ok {
+		if index, ok := builtinIndex(name); ok {
+			builtin = index
+		}
+	}
+
+	symbols, certain := c.symbolTable.lookup(name, b)
+	if len(symbols) == 0 {
+		if builtin >= 0 {
+			c.emit(code.OpGetBuiltin, builtin)
+			return
+		}
+		symbols = []Symbol{c.symbolTable.global().Define(name)}
+	}
+
+	var ends []int
+	for i, symbol := range symbols {
+		if i == len(symbols)-1 && (certain || builtin < 0) {
+			c.loadSymbol(symbol)
+			break
+		}
+
+		unset := c.emit(code.OpJumpUnset, 9999, variableKind(symbol), symbol.Index)
+		c.loadSymbol(symbol)
+		ends = append(ends, c.emit(code.OpJump, 9999))
+		c.changeOperand(unset, len(c.currentInstructions()))
+
+		if i == len(symbols)-1 {
+			c.emit(code.OpGetBuiltin, builtin)
+		}
+	}
+	for _, end := range ends {
+		c.changeOperand(end, len(c.currentInstructions()))
+	}
+}
+
+// variableKind tells OpJumpUnset where the variable of a symbol is.
+func variableKind(s Symbol) int {
+	switch {
+	case s.Scope == GlobalScope:
+		return code.VariableGlobal
+	case s.Scope == FreeScope:
+		return code.VariableFree
+	case s.Cell:
+		return code.VariableCell
+	default:
+		return code.VariableLocal
+	}
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		if s.Cell {
+			c.emit(code.OpGetCell, s.Index)
+		} else {
+			c.emit(code.OpGetLocal, s.Index)
+		}
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	}
+}
+
+func (c *Compiler) setSymbol(s Symbol) {
+	switch {
+	case s.Scope == GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case s.Cell:
+		c.emit(code.OpSetCell, s.Index)
+	default:
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
+// loadCell pushes the cell of a variable a closure captures.
+func (c *Compiler) loadCell(s Symbol) {
+	switch s.Scope {
+	case LocalScope:
+		c.emit(code.OpLoadCell, s.Index)
+	case FreeScope:
+		c.emit(code.OpLoadFree, s.Index)
+	}
+}
+
+func builtinIndex(name string) (int, bool) {
+	for i, n := range evaluator.BuiltinNames {
+		if n == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// nodePosition returns where in the source a node is. The program and
+// blocks have no position of their own.
+func nodePosition(node ast.Node) (token.Position, bool) {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		return node.Token.Pos, true
+	case *ast.ReturnStatement:
+		return node.Token.Pos, true
+	case *ast.ExpressionStatement:
+		return node.Token.Pos, true
+	case *ast.Identifier:
+		return node.Token.Pos, true
+	case *ast.IntegerLiteral:
+		return node.Token.Pos, true
+	case *ast.StringLiteral:
+		return node.Token.Pos, true
+	case *ast.InterpolatedString:
+		return node.Token.Pos, true
+	case *ast.Boolean:
+		return node.Token.Pos, true
+	case *ast.NullLiteral:
+		return node.Token.Pos, true
+	case *ast.ArrayLiteral:
+		return node.Token.Pos, true
+	case *ast.HashLiteral:
+		return node.Token.Pos, true
+	case *ast.PrefixExpression:
+		return node.Token.Pos, true
+	case *ast.InfixExpression:
+		return node.Token.Pos, true
+	case *ast.IfExpression:
+		return node.Token.Pos, true
+	case *ast.IndexExpression:
+		return node.Token.Pos, true
+	case *ast.FunctionLiteral:
+		return node.Token.Pos, true
+	case *ast.CallExpression:
+		return node.Token.Pos, true
+	default:
+		return token.Position{}, false
+	}
+}
+
+func endsInExpression(stmts []ast.Statement) bool {
+	if len(stmts) == 0 {
+		return false
+	}
+	_, ok := stmts[len(stmts)-1].(*ast.ExpressionStatement)
+	return ok
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Positions:    c.scopes[c.scopeIndex].positions,
+		Constants:    c.constants,
+		Globals:      c.symbolTable.global().Names(),
+	}
+}
+
+// newError reports a program the compiler cannot compile, at the node being
+// compiled.
+func (c *Compiler) newError(code diagnostic.Code, a ...interface{}) error {
+	return diagnostic.New(code, c.position, a...)
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	c.checkOperands(op, operands)
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.addPosition(pos)
+	c.setLastInstruction(op, pos)
+
+	return pos
+}
+
+// checkOperands keeps an error in c.err if an operand is too large for its
+// width in the instruction, such as the index of constant 65536, since it
+// would be cut off and make the program run differently.
+func (c *Compiler) checkOperands(op code.Opcode, operands []int) {
+	def, err := code.Lookup(byte(op))
+	if err != nil || c.err != nil {
+		return
+	}
+
+	for i, operand := range operands {
+		max := 1<<(8*def.OperandWidths[i]) - 1
+		if operand > max {
+			c.err = c.newError(diagnostic.OperandTooLarge, def.Name, operand, max)
+			return
+		}
+	}
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), ins...)
+	return posNewInstruction
+}
+
+// addPosition records that the instruction at offset comes from the node
+// being compiled, unless it comes from the same place as the one before.
+func (c *Compiler) addPosition(offset int) {
+	if c.position.Line == 0 {
+		return
+	}
+
+	positions := c.scopes[c.scopeIndex].positions
+	if n := len(positions); n > 0 && positions[n-1].Line == c.position.Line && positions[n-1].Column == c.position.Column {
+		return
+	}
+
+	c.scopes[c.scopeIndex].positions = append(positions, code.SourcePosition{
+		Offset: offset,
+		Line:   c.position.Line,
+		Column: c.position.Column,
+	})
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	old := c.currentInstructions()
+	c.scopes[c.scopeIndex].instructions = old[:last.Position]
+	c.scopes[c.scopeIndex].lastInstruction = previous
+
+	positions := c.scopes[c.scopeIndex].positions
+	for len(positions) > 0 && positions[len(positions)-1].Offset >= last.Position {
+		positions = positions[:len(positions)-1]
+	}
+	c.scopes[c.scopeIndex].positions = positions
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+// changeOperand changes the first operand of the instruction at opPos,
+// keeping any others.
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	ins := c.currentInstructions()
+	op := code.Opcode(ins[opPos])
+	def, _ := code.Lookup(byte(op))
+	operands, _ := code.ReadOperands(def, ins[opPos+1:])
+	operands[0] = operand
+
+	c.checkOperands(op, operands)
+	c.replaceInstruction(opPos, code.Make(op, operands...))
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{})
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
@@ -0,0 +1,541 @@
+// compiler/compiler_test.go
+
+package compiler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/solbero/pytonskript/ast"
+	"github.com/solbero/pytonskript/code"
+	"github.com/solbero/pytonskript/diagnostic"
+	"github.com/solbero/pytonskript/lexer"
+	"github.com/solbero/pytonskript/object"
+	"github.com/solbero/pytonskript/parser"
+	"github.com/solbero/pytonskript/token"
+)
+
+type compilerTestCase struct {
+	input                string
+	expectedConstants    []interface{}
+	expectedInstructions []code.Instructions
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 + 2",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "1; 2 < 3",
+			expectedConstants: []interface{}{1, 2, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpLessThan),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "-1",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpMinus),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "1 i [1]",
+			expectedConstants: []interface{}{1, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpIn),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestNull(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "ingenting ?? 1",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpNull),
+				// 0001
+				code.Make(code.OpJumpNotNull, 7),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "[1]?[0]",
+			expectedConstants: []interface{}{1, 0},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpArray, 1),
+				// 0006
+				code.Make(code.OpJumpNull, 13),
+				// 0009
+				code.Make(code.OpConstant, 1),
+				// 0012
+				code.Make(code.OpIndex),
+				// 0013
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "hvis (sant) { 10 }; 3333;",
+			expectedConstants: []interface{}{10, 3333},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 10),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpJump, 11),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpPop),
+				// 0012
+				code.Make(code.OpConstant, 1),
+				// 0015
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "hvis (sant) { la x = 1 } ellers { }",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTrue),
+				// 0001
+				code.Make(code.OpJumpNotTruthy, 14),
+				// 0004
+				code.Make(code.OpConstant, 0),
+				// 0007
+				code.Make(code.OpSetGlobal, 0),
+				// 0010
+				code.Make(code.OpNull),
+				// 0011
+				code.Make(code.OpJump, 15),
+				// 0014
+				code.Make(code.OpNull),
+				// 0015
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "la one = 1; la two = one;",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpReturn),
+			},
+		},
+		{
+			// Globals are known before their la, and undefined names are
+			// globals that are never set.
+			input: "la f = funksjon() { x + y }; la x = 1;",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetGlobal, 1),
+					code.Make(code.OpGetGlobal, 2),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSetGlobal, 1),
+				code.Make(code.OpReturn),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "funksjon(a) { la b = a; }",
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpReturn),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "lengde([]); skriv(1, 2)",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetBuiltin, 0),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpGetBuiltin, 5),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpCall, 2),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `
+			funksjon(a) {
+				la g = funksjon() { a + b };
+				la b = 1;
+				g
+			}`,
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpGetFree, 1),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+				[]code.Instructions{
+					code.Make(code.OpNewCell, 0),
+					code.Make(code.OpNewCell, 2),
+					code.Make(code.OpLoadCell, 0),
+					code.Make(code.OpLoadCell, 2),
+					code.Make(code.OpClosure, 0, 2),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpSetCell, 2),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: `
+			funksjon(a) {
+				funksjon() {
+					funksjon() { a }
+				}
+			}`,
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpLoadFree, 0),
+					code.Make(code.OpClosure, 0, 1),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpNewCell, 0),
+					code.Make(code.OpLoadCell, 0),
+					code.Make(code.OpClosure, 1, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestNamesBeforeLet(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `la x = 1; funksjon() { la y = x; la x = 2; y }`,
+			expectedConstants: []interface{}{
+				1,
+				2,
+				[]code.Instructions{
+					code.Make(code.OpGetGlobal, 0),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input: `funksjon(c) { hvis (c) { la lengde = 1 }; lengde }`,
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					// 0000
+					code.Make(code.OpGetLocal, 0),
+					// 0002
+					code.Make(code.OpJumpNotTruthy, 14),
+					// 0005
+					code.Make(code.OpConstant, 0),
+					// 0008
+					code.Make(code.OpSetLocal, 1),
+					// 0010
+					code.Make(code.OpNull),
+					// 0011
+					code.Make(code.OpJump, 15),
+					// 0014
+					code.Make(code.OpNull),
+					// 0015
+					code.Make(code.OpPop),
+					// 0016
+					code.Make(code.OpJumpUnset, 27, code.VariableLocal, 1),
+					// 0022
+					code.Make(code.OpGetLocal, 1),
+					// 0024
+					code.Make(code.OpJump, 29),
+					// 0027
+					code.Make(code.OpGetBuiltin, 0),
+					// 0029
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestCompilerDialect(t *testing.T) {
+	program := parseWithDialect(t, "first([]) in []", token.English)
+
+	compiler := New(token.English)
+	if err := compiler.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	expected := concatInstructions([]code.Instructions{
+		code.Make(code.OpGetBuiltin, 1),
+		code.Make(code.OpArray, 0),
+		code.Make(code.OpCall, 1),
+		code.Make(code.OpArray, 0),
+		code.Make(code.OpIn),
+		code.Make(code.OpReturnValue),
+	})
+	if compiler.Bytecode().Instructions.String() != expected.String() {
+		t.Errorf("wrong instructions.\nwant=%s\ngot=%s", expected, compiler.Bytecode().Instructions)
+	}
+}
+
+func TestCompilerErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected diagnostic.Code
+	}{
+		{"f(" + strings.Repeat("1, ", 255) + "1)", diagnostic.TooManyCallArguments},
+		{"funksjon() { " + manyLets(257) + " }", diagnostic.TooManyLocals},
+	}
+
+	for _, tt := range tests {
+		program := parseWithDialect(t, tt.input, token.Bokmal)
+
+		err := New(token.Bokmal).Compile(program)
+		d, ok := err.(*diagnostic.Diagnostic)
+		if !ok {
+			t.Errorf("%.20s...: expected a diagnostic, got %v", tt.input, err)
+			continue
+		}
+		if d.Code != tt.expected {
+			t.Errorf("%.20s...: expected %s, got %s", tt.input, tt.expected, d)
+		}
+	}
+}
+
+// manyLets binds n different names, made of letters since names cannot
+// have digits.
+func manyLets(n int) string {
+	var out strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&out, "la x%c%c = %d; ", 'a'+i/26, 'a'+i%26, i)
+	}
+	return out.String()
+}
+
+func TestResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	first := NewEnclosedSymbolTable(global)
+	first.defineCell("c")
+
+	second := NewEnclosedSymbolTable(first)
+	second.Define("e")
+
+	expected := []Symbol{
+		{Name: "a", Scope: GlobalScope, Index: 0},
+		{Name: "c", Scope: FreeScope, Index: 0},
+		{Name: "e", Scope: LocalScope, Index: 0},
+	}
+	for _, sym := range expected {
+		result, ok := second.Resolve(sym.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", sym.Name)
+			continue
+		}
+		if result != sym {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+		}
+	}
+
+	if len(second.FreeSymbols) != 1 || second.FreeSymbols[0] != (Symbol{Name: "c", Scope: LocalScope, Index: 0, Cell: true}) {
+		t.Errorf("wrong free symbols: %+v", second.FreeSymbols)
+	}
+
+	if _, ok := second.Resolve("x"); ok {
+		t.Errorf("x should not resolve")
+	}
+
+	if redefined := global.Define("a"); redefined.Index != 0 || global.NumDefinitions() != 1 {
+		t.Errorf("defining a name again should reuse its slot, got %+v", redefined)
+	}
+}
+
+func parseWithDialect(t *testing.T, input string, dialect *token.Dialect) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.NewWithDialect(input, dialect))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func runCompilerTests(t *testing.T, tests []compilerTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		program := parseWithDialect(t, tt.input, token.Bokmal)
+
+		compiler := New(token.Bokmal)
+		if err := compiler.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		bytecode := compiler.Bytecode()
+
+		if err := testInstructions(tt.expectedInstructions, bytecode.Instructions); err != "" {
+			t.Errorf("%q: %s", tt.input, err)
+		}
+
+		if err := testConstants(tt.expectedConstants, bytecode.Constants); err != "" {
+			t.Errorf("%q: %s", tt.input, err)
+		}
+	}
+}
+
+func concatInstructions(s []code.Instructions) code.Instructions {
+	out := code.Instructions{}
+	for _, ins := range s {
+		out = append(out, ins...)
+	}
+	return out
+}
+
+func testInstructions(expected []code.Instructions, actual code.Instructions) string {
+	concatted := concatInstructions(expected)
+	if concatted.String() != actual.String() {
+		return "wrong instructions.\nwant=\n" + concatted.String() + "got=\n" + actual.String()
+	}
+	return ""
+}
+
+func testConstants(expected []interface{}, actual []object.Object) string {
+	if len(expected) != len(actual) {
+		return "wrong number of constants"
+	}
+
+	for i, constant := range expected {
+		switch constant := constant.(type) {
+		case int:
+			integer, ok := actual[i].(*object.Integer)
+			if !ok || integer.Value != int64(constant) {
+				return "constant " + actual[i].Inspect() + " is not the expected integer"
+			}
+		case []code.Instructions:
+			fn, ok := actual[i].(*object.CompiledFunction)
+			if !ok {
+				return "constant " + actual[i].Inspect() + " is not a function"
+			}
+			if err := testInstructions(constant, fn.Instructions); err != "" {
+				return fmt.Sprintf("constant %d: %s", i, err)
+			}
+		}
+	}
+
+	return ""
+}
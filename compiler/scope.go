@@ -0,0 +1,136 @@
+// compiler/scope.go
+
+package compiler
+
+import "github.com/solbero/pytonskript/ast"
+
+// scope describes the names a function, or the program, binds and refers
+// to. A name bound by la anywhere in a function, also inside if blocks, gets
+// a slot in the function. Until the la has run, the evaluator looks the name
+// up around the function instead, so the analysis also records where a name
+// is certainly bound and where it may not be yet.
+type scope struct {
+	declared []string // parameters and names bound by la, in order
+	captured map[string]bool
+	free     map[string]bool
+
+	// uses tells for each use of a name whether it is bound in this function
+	// when it runs, and boundAt holds the names that are certainly bound when
+	// each function literal inside is evaluated.
+	uses    map[*ast.Identifier]binding
+	boundAt map[*ast.FunctionLiteral]map[string]bool
+
+	isDeclared map[string]bool
+	nestedFree map[string]bool
+	current    map[string]bool // the names certainly bound at this point
+}
+
+// binding tells whether a name is bound in a function at some point.
+type binding int
+
+const (
+	unbound    binding = iota // no la for it has run
+	maybeBound                // a la for it may have run, in an if block
+	bound                     // it is a parameter, or a la for it has run
+)
+
+// analyse returns the scope of a function with the given parameters and
+// body. Captured are the declared names the functions inside refer to, and
+// free the names it may have to look up around it.
+func analyse(params []*ast.Identifier, body []ast.Statement) *scope {
+	s := &scope{
+		captured:   make(map[string]bool),
+		free:       make(map[string]bool),
+		uses:       make(map[*ast.Identifier]binding),
+		boundAt:    make(map[*ast.FunctionLiteral]map[string]bool),
+		isDeclared: make(map[string]bool),
+		nestedFree: make(map[string]bool),
+		current:    make(map[string]bool),
+	}
+
+	for _, p := range params {
+		s.declare(p.Value)
+	}
+	s.statements(body)
+
+	for name := range s.nestedFree {
+		if s.isDeclared[name] {
+			s.captured[name] = true
+		}
+	}
+
+	return s
+}
+
+func (s *scope) declare(name string) {
+	if !s.isDeclared[name] {
+		s.isDeclared[name] = true
+		s.declared = append(s.declared, name)
+	}
+	s.current[name] = true
+}
+
+// statements records the names the statements bind and refer to, in the
+// order they run. Function literals are analysed on their own, and only the
+// names they need from around them are recorded.
+func (s *scope) statements(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, s.visit)
+	}
+}
+
+func (s *scope) visit(node ast.Node) bool {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		// The name is bound after the value is evaluated, and is not a use.
+		ast.Inspect(node.Value, s.visit)
+		s.declare(node.Name.Value)
+		return false
+	case *ast.Identifier:
+		switch {
+		case s.current[node.Value]:
+			s.uses[node] = bound
+		case s.isDeclared[node.Value]:
+			s.uses[node] = maybeBound
+			s.free[node.Value] = true
+		default:
+			s.free[node.Value] = true
+		}
+	case *ast.IfExpression:
+		// Only one branch runs, so what either binds is not certain after it.
+		ast.Inspect(node.Condition, s.visit)
+		s.branch(node.Consequence)
+		if node.Alternative != nil {
+			s.branch(node.Alternative)
+		}
+		return false
+	case *ast.FunctionLiteral:
+		boundAt := copySet(s.current)
+		s.boundAt[node] = boundAt
+
+		inner := analyse(node.Parameters, node.Body.Statements)
+		for name := range inner.free {
+			s.nestedFree[name] = true
+			if !boundAt[name] {
+				s.free[name] = true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (s *scope) branch(block *ast.BlockStatement) {
+	outer := s.current
+	s.current = copySet(outer)
+	ast.Inspect(block, s.visit)
+	s.current = outer
+}
+
+func copySet(set map[string]bool) map[string]bool {
+	c := make(map[string]bool, len(set))
+	for name := range set {
+		c[name] = true
+	}
+	return c
+}
@@ -0,0 +1,157 @@
+// compiler/symbol_table.go
+
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope SymbolScope = "GLOBAL"
+	LocalScope  SymbolScope = "LOCAL"
+	FreeScope   SymbolScope = "FREE"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+
+	// Cell is set for locals that closures refer to, which are kept in cells.
+	Cell bool
+}
+
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	// FreeSymbols are the symbols of the functions around this one that it
+	// refers to, in the order the closure captures them.
+	FreeSymbols []Symbol
+
+	// outerBound holds the names that are certainly bound in the table
+	// around this one when the closure is made.
+	outerBound map[string]bool
+
+	store map[string]Symbol
+	names []string
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{store: make(map[string]Symbol)}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Define returns the symbol for name in this table, adding it if it is not
+// there already. Binding a name twice in a scope reuses its slot.
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok && symbol.Scope != FreeScope {
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Index: len(s.names)}
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+	}
+
+	s.store[name] = symbol
+	s.names = append(s.names, name)
+	return symbol
+}
+
+// defineCell defines name as a local kept in a cell.
+func (s *SymbolTable) defineCell(name string) Symbol {
+	symbol := s.Define(name)
+	symbol.Cell = true
+	s.store[name] = symbol
+	return symbol
+}
+
+// defineFree returns the free symbol for a symbol of the table around this
+// one, adding it if the closure does not capture it already.
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	for i, free := range s.FreeSymbols {
+		if free == original {
+			return Symbol{Name: original.Name, Index: i, Scope: FreeScope}
+		}
+	}
+
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1, Scope: FreeScope}
+	if _, ok := s.store[original.Name]; !ok {
+		s.store[original.Name] = symbol
+	}
+	return symbol
+}
+
+// Resolve looks name up here and in the tables around this one. Locals of
+// the functions around this one become free symbols of this one.
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, ok := s.store[name]
+	if ok || s.Outer == nil {
+		return symbol, ok
+	}
+
+	symbol, ok = s.Outer.Resolve(name)
+	if !ok || symbol.Scope == GlobalScope {
+		return symbol, ok
+	}
+
+	return s.defineFree(symbol), true
+}
+
+// lookup returns the symbols name may be found in, innermost first, as the
+// evaluator would look for it. A name bound with la is only found in its
+// own function once the la has run, so lookup goes on outwards until it
+// reaches a symbol that is certainly set. b tells whether the name is bound
+// in this table, and certain whether the last symbol returned is set.
+// Globals may have been bound by an earlier program in the REPL, so they
+// are never skipped.
+func (s *SymbolTable) lookup(name string, b binding) (symbols []Symbol, certain bool) {
+	if symbol, ok := s.store[name]; ok && symbol.Scope != FreeScope && (b != unbound || s.Outer == nil) {
+		symbols = append(symbols, symbol)
+		if b == bound {
+			return symbols, true
+		}
+	}
+	if s.Outer == nil {
+		return symbols, false
+	}
+
+	outer := maybeBound
+	if s.outerBound[name] {
+		outer = bound
+	}
+	outerSymbols, certain := s.Outer.lookup(name, outer)
+	for _, symbol := range outerSymbols {
+		if symbol.Scope != GlobalScope {
+			symbol = s.defineFree(symbol)
+		}
+		symbols = append(symbols, symbol)
+	}
+	return symbols, certain
+}
+
+// Names returns the names defined in this table, by index.
+func (s *SymbolTable) Names() []string {
+	names := make([]string, len(s.names))
+	copy(names, s.names)
+	return names
+}
+
+// NumDefinitions returns the number of names defined in this table.
+func (s *SymbolTable) NumDefinitions() int {
+	return len(s.names)
+}
+
+func (s *SymbolTable) global() *SymbolTable {
+	for s.Outer != nil {
+		s = s.Outer
+	}
+	return s
+}
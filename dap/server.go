@@ -0,0 +1,397 @@
+// dap/server.go
+
+// Package dap lets editors drive the debugger through the Debug Adapter
+// Protocol over a pair of streams.
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/solbero/pytonskript/ast"
+	"github.com/solbero/pytonskript/debugger"
+	"github.com/solbero/pytonskript/diagnostic"
+	"github.com/solbero/pytonskript/evaluator"
+	"github.com/solbero/pytonskript/lexer"
+	"github.com/solbero/pytonskript/object"
+	"github.com/solbero/pytonskript/parser"
+	"github.com/solbero/pytonskript/rpc"
+	"github.com/solbero/pytonskript/token"
+)
+
+type Server struct {
+	in      *bufio.Reader
+	dialect *token.Dialect
+
+	writeMu sync.Mutex // guards out and seq, since events come from the program
+	out     io.Writer
+	seq     int
+
+	debugger    *debugger.Debugger
+	path        string
+	program     *ast.Program
+	env         *object.Environment
+	stopOnEntry bool
+	done        chan struct{} // closed when the program has ended
+	quit        chan struct{} // closed to end the program
+
+	// after is run once the response to the current request is written, so
+	// that events it causes come after the response.
+	after func()
+
+	// While the program is stopped, stop describes where and resume takes
+	// the next action. refs are the environments handed out as variable
+	// references, which are only valid until the program goes on.
+	stopMu sync.Mutex
+	stop   *debugger.Stop
+	refs   []*object.Environment
+	resume chan debugger.Action
+}
+
+// NewServer returns a server reading messages from in and writing to out.
+// Programs without a dialect directive are read in dialect.
+func NewServer(in io.Reader, out io.Writer, dialect *token.Dialect) *Server {
+	s := &Server{
+		in:      bufio.NewReader(in),
+		out:     out,
+		dialect: dialect,
+		resume:  make(chan debugger.Action),
+		quit:    make(chan struct{}),
+	}
+	s.debugger = debugger.New(s.onStop)
+	return s
+}
+
+type handler func(s *Server, args json.RawMessage) (interface{}, error)
+
+var handlers = map[string]handler{
+	"initialize":        (*Server).initialize,
+	"launch":            (*Server).launch,
+	"setBreakpoints":    (*Server).setBreakpoints,
+	"configurationDone": (*Server).configurationDone,
+	"threads":           (*Server).threads,
+	"stackTrace":        (*Server).stackTrace,
+	"scopes":            (*Server).scopes,
+	"variables":         (*Server).variables,
+	"continue":          actionHandler(debugger.Continue),
+	"next":              actionHandler(debugger.StepOver),
+	"stepIn":            actionHandler(debugger.StepInto),
+	"stepOut":           actionHandler(debugger.StepOut),
+}
+
+// Serve handles requests until the client disconnects or closes the input.
+// A program still running is ended.
+func (s *Server) Serve() error {
+	defer s.end()
+
+	for {
+		body, err := rpc.Read(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			return fmt.Errorf("dap: %s", err)
+		}
+		if req.Type != "request" {
+			continue
+		}
+
+		if req.Command == "disconnect" {
+			s.end()
+			return s.respond(&req, nil, nil)
+		}
+
+		h, ok := handlers[req.Command]
+		if !ok {
+			if err := s.respond(&req, nil, fmt.Errorf("ukjent kommando: %s", req.Command)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		result, err := h(s, req.Arguments)
+		if err := s.respond(&req, result, err); err != nil {
+			return err
+		}
+
+		if s.after != nil {
+			s.after()
+			s.after = nil
+		}
+	}
+}
+
+func (s *Server) respond(req *request, body interface{}, err error) error {
+	resp := &response{Type: "response", RequestSeq: req.Seq, Success: err == nil, Command: req.Command, Body: body}
+	if err != nil {
+		resp.Message = err.Error()
+		resp.Body = nil
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.seq++
+	resp.Seq = s.seq
+	return s.write(resp)
+}
+
+func (s *Server) event(name string, body interface{}) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.seq++
+	return s.write(&event{Seq: s.seq, Type: "event", Event: name, Body: body})
+}
+
+func (s *Server) write(msg interface{}) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return rpc.Write(s.out, body)
+}
+
+func (s *Server) initialize(args json.RawMessage) (interface{}, error) {
+	s.after = func() { s.event("initialized", nil) }
+	return Capabilities{SupportsConfigurationDoneRequest: true}, nil
+}
+
+func (s *Server) launch(args json.RawMessage) (interface{}, error) {
+	var a LaunchArguments
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+	if s.program != nil {
+		return nil, fmt.Errorf("et program er allerede startet")
+	}
+
+	input, err := os.ReadFile(a.Program)
+	if err != nil {
+		return nil, err
+	}
+
+	l := lexer.NewWithDialect(string(input), s.dialect)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		messages := []string{diagnostic.Sprintf(diagnostic.LabelSyntaxError)}
+		for _, err := range p.Errors() {
+			messages = append(messages, err.Error())
+		}
+		return nil, fmt.Errorf("%s", strings.Join(messages, "\n"))
+	}
+
+	program, errObj := evaluator.ExpandProgram(program, l.Dialect())
+	if errObj != nil {
+		return nil, errObj
+	}
+
+	s.path = a.Program
+	s.program = program
+	s.stopOnEntry = a.StopOnEntry
+	s.env = object.NewEnvironment()
+	s.env.SetDialect(l.Dialect())
+
+	return nil, nil
+}
+
+func (s *Server) setBreakpoints(args json.RawMessage) (interface{}, error) {
+	var a SetBreakpointsArguments
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+
+	body := SetBreakpointsResponseBody{Breakpoints: []Breakpoint{}}
+	lines := []int{}
+	for _, bp := range a.Breakpoints {
+		lines = append(lines, bp.Line)
+		body.Breakpoints = append(body.Breakpoints, Breakpoint{Verified: true, Line: bp.Line})
+	}
+	s.debugger.SetBreakpoints(lines)
+
+	return body, nil
+}
+
+// configurationDone starts the program, once the client has set the
+// breakpoints.
+func (s *Server) configurationDone(args json.RawMessage) (interface{}, error) {
+	if s.program == nil {
+		return nil, fmt.Errorf("ingen program er startet")
+	}
+	if s.done != nil {
+		return nil, nil
+	}
+
+	s.done = make(chan struct{})
+	s.after = func() { go s.run(s.done) }
+
+	return nil, nil
+}
+
+func (s *Server) run(done chan struct{}) {
+	defer close(done)
+
+	result, finished := s.debugger.Run(s.program, s.env, s.stopOnEntry)
+
+	exitCode := 0
+	if err, ok := result.(*object.Error); ok && finished {
+		s.event("output", OutputEventBody{Category: "stderr", Output: err.Inspect() + "\n"})
+		exitCode = 1
+	}
+
+	s.event("exited", ExitedEventBody{ExitCode: exitCode})
+	s.event("terminated", nil)
+}
+
+// onStop is called on the goroutine running the program, which waits here
+// until the client asks it to go on.
+func (s *Server) onStop(stop *debugger.Stop) debugger.Action {
+	s.stopMu.Lock()
+	s.stop = stop
+	s.refs = nil
+	s.stopMu.Unlock()
+
+	s.event("stopped", StoppedEventBody{Reason: string(stop.Reason), ThreadID: threadID, AllThreadsStopped: true})
+
+	action := debugger.Quit
+	select {
+	case action = <-s.resume:
+	case <-s.quit:
+	}
+
+	s.stopMu.Lock()
+	s.stop = nil
+	s.refs = nil
+	s.stopMu.Unlock()
+
+	return action
+}
+
+// end stops a running program and waits for it.
+func (s *Server) end() {
+	if s.done == nil {
+		return
+	}
+
+	s.debugger.Quit()
+	close(s.quit)
+
+	<-s.done
+	s.done = nil
+}
+
+func actionHandler(action debugger.Action) handler {
+	return func(s *Server, args json.RawMessage) (interface{}, error) {
+		s.stopMu.Lock()
+		stopped := s.stop != nil
+		s.stopMu.Unlock()
+		if !stopped {
+			return nil, fmt.Errorf("programmet er ikke stoppet")
+		}
+
+		s.after = func() { s.resume <- action }
+
+		if action == debugger.Continue {
+			return ContinueResponseBody{AllThreadsContinued: true}, nil
+		}
+		return nil, nil
+	}
+}
+
+func (s *Server) threads(args json.RawMessage) (interface{}, error) {
+	return ThreadsResponseBody{Threads: []Thread{{ID: threadID, Name: debugger.MainFrame}}}, nil
+}
+
+func (s *Server) stackTrace(args json.RawMessage) (interface{}, error) {
+	s.stopMu.Lock()
+	defer s.stopMu.Unlock()
+
+	body := StackTraceResponseBody{StackFrames: []StackFrame{}}
+	if s.stop == nil {
+		return body, nil
+	}
+
+	source := Source{Name: filepath.Base(s.path), Path: s.path}
+	for i, frame := range s.stop.Frames {
+		body.StackFrames = append(body.StackFrames, StackFrame{
+			ID:     i,
+			Name:   frame.Name,
+			Source: source,
+			Line:   frame.Line,
+			Column: 1,
+		})
+	}
+	body.TotalFrames = len(body.StackFrames)
+
+	return body, nil
+}
+
+// scopes returns one scope for each environment around the frame: its own,
+// those of the functions it was defined in and the global one.
+func (s *Server) scopes(args json.RawMessage) (interface{}, error) {
+	var a ScopesArguments
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+
+	s.stopMu.Lock()
+	defer s.stopMu.Unlock()
+
+	if s.stop == nil || a.FrameID < 0 || a.FrameID >= len(s.stop.Frames) {
+		return nil, fmt.Errorf("ukjent ramme: %d", a.FrameID)
+	}
+
+	body := ScopesResponseBody{Scopes: []Scope{}}
+	env := s.stop.Frames[a.FrameID].Env
+	for e := env; e != nil; e = e.Outer() {
+		name := "Ytre"
+		switch {
+		case e.Outer() == nil:
+			name = "Globale"
+		case e == env:
+			name = "Lokale"
+		}
+
+		s.refs = append(s.refs, e)
+		body.Scopes = append(body.Scopes, Scope{Name: name, VariablesReference: len(s.refs)})
+	}
+
+	return body, nil
+}
+
+func (s *Server) variables(args json.RawMessage) (interface{}, error) {
+	var a VariablesArguments
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, err
+	}
+
+	s.stopMu.Lock()
+	defer s.stopMu.Unlock()
+
+	if a.VariablesReference < 1 || a.VariablesReference > len(s.refs) {
+		return nil, fmt.Errorf("ukjent referanse: %d", a.VariablesReference)
+	}
+
+	env := s.refs[a.VariablesReference-1]
+	body := VariablesResponseBody{Variables: []Variable{}}
+	for _, name := range env.Names() {
+		value, _ := env.Get(name)
+		body.Variables = append(body.Variables, Variable{
+			Name:  name,
+			Value: debugger.Describe(value, env.Dialect()),
+			Type:  diagnostic.TypeName(string(value.Type())),
+		})
+	}
+
+	return body, nil
+}
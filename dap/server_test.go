@@ -0,0 +1,323 @@
+// dap/server_test.go
+
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/solbero/pytonskript/rpc"
+	"github.com/solbero/pytonskript/token"
+)
+
+// client drives a server over pipes, the way an editor would.
+type client struct {
+	t       *testing.T
+	in      *io.PipeWriter
+	out     *bufio.Reader
+	seq     int
+	done    chan error
+	pending []incoming // messages read while waiting for something else
+}
+
+type incoming struct {
+	Type       string          `json:"type"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Command    string          `json:"command"`
+	Message    string          `json:"message"`
+	Event      string          `json:"event"`
+	Body       json.RawMessage `json:"body"`
+}
+
+func newClient(t *testing.T) *client {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+
+	c := &client{t: t, in: inWriter, out: bufio.NewReader(outReader), done: make(chan error, 1)}
+
+	go func() {
+		err := NewServer(inReader, outWriter, token.Bokmal).Serve()
+		outWriter.Close()
+		c.done <- err
+	}()
+
+	return c
+}
+
+// next returns the first message, read or pending, that match accepts.
+func (c *client) next(match func(incoming) bool) incoming {
+	c.t.Helper()
+
+	for i, msg := range c.pending {
+		if match(msg) {
+			c.pending = append(c.pending[:i], c.pending[i+1:]...)
+			return msg
+		}
+	}
+
+	for {
+		body, err := rpc.Read(c.out)
+		if err != nil {
+			c.t.Fatalf("could not read message: %s", err)
+		}
+		var msg incoming
+		if err := json.Unmarshal(body, &msg); err != nil {
+			c.t.Fatalf("could not decode message %s: %s", body, err)
+		}
+		if match(msg) {
+			return msg
+		}
+		c.pending = append(c.pending, msg)
+	}
+}
+
+// request sends a request, waits for its response and decodes the body into
+// body. It fails the test if the request fails.
+func (c *client) request(command string, args interface{}, body interface{}) {
+	c.t.Helper()
+	resp := c.send(command, args)
+	if !resp.Success {
+		c.t.Fatalf("%s failed: %s", command, resp.Message)
+	}
+	if body != nil {
+		if err := json.Unmarshal(resp.Body, body); err != nil {
+			c.t.Fatalf("could not decode body of %s: %s", command, err)
+		}
+	}
+}
+
+func (c *client) send(command string, args interface{}) incoming {
+	c.t.Helper()
+	c.seq++
+	seq := c.seq
+
+	msg, err := json.Marshal(map[string]interface{}{"seq": seq, "type": "request", "command": command, "arguments": args})
+	if err != nil {
+		c.t.Fatalf("could not encode request: %s", err)
+	}
+	if err := rpc.Write(c.in, msg); err != nil {
+		c.t.Fatalf("could not send request: %s", err)
+	}
+
+	return c.next(func(msg incoming) bool { return msg.Type == "response" && msg.RequestSeq == seq })
+}
+
+func (c *client) event(name string, body interface{}) {
+	c.t.Helper()
+	msg := c.next(func(msg incoming) bool { return msg.Type == "event" && msg.Event == name })
+	if body != nil {
+		if err := json.Unmarshal(msg.Body, body); err != nil {
+			c.t.Fatalf("could not decode %s event: %s", name, err)
+		}
+	}
+}
+
+// start writes source to a file and launches it with breakpoints on the
+// given lines. It returns the path of the file.
+func (c *client) start(source string, breakpoints []int, stopOnEntry bool) string {
+	c.t.Helper()
+
+	path := filepath.Join(c.t.TempDir(), "program.pytonskript")
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		c.t.Fatal(err)
+	}
+
+	var capabilities Capabilities
+	c.request("initialize", map[string]interface{}{"adapterID": "pytonskript"}, &capabilities)
+	if !capabilities.SupportsConfigurationDoneRequest {
+		c.t.Errorf("configurationDone should be supported")
+	}
+	c.event("initialized", nil)
+
+	c.request("launch", LaunchArguments{Program: path, StopOnEntry: stopOnEntry}, nil)
+
+	args := SetBreakpointsArguments{Source: Source{Path: path}}
+	for _, line := range breakpoints {
+		args.Breakpoints = append(args.Breakpoints, SourceBreakpoint{Line: line})
+	}
+	var set SetBreakpointsResponseBody
+	c.request("setBreakpoints", args, &set)
+	if len(set.Breakpoints) != len(breakpoints) {
+		c.t.Errorf("wrong number of breakpoints, expected %d, got %d", len(breakpoints), len(set.Breakpoints))
+	}
+
+	c.request("configurationDone", nil, nil)
+	return path
+}
+
+func (c *client) stopped(reason string) {
+	c.t.Helper()
+	var body StoppedEventBody
+	c.event("stopped", &body)
+	if body.Reason != reason || body.ThreadID != threadID {
+		c.t.Errorf("wrong stop, expected reason %q, got %+v", reason, body)
+	}
+}
+
+func (c *client) stack() []StackFrame {
+	c.t.Helper()
+	var body StackTraceResponseBody
+	c.request("stackTrace", map[string]interface{}{"threadId": threadID}, &body)
+	return body.StackFrames
+}
+
+const countdown = `la ned = funksjon(n) {
+  hvis (n > 0) {
+    ned(n - 1);
+  } ellers {
+    0
+  }
+};
+
+la a = ned(1);
+la b = a + 1;`
+
+func TestBreakpointsAndStepping(t *testing.T) {
+	c := newClient(t)
+	path := c.start(countdown, []int{5}, false)
+
+	c.stopped("breakpoint")
+
+	var threads ThreadsResponseBody
+	c.request("threads", nil, &threads)
+	if len(threads.Threads) != 1 || threads.Threads[0].ID != threadID {
+		t.Errorf("wrong threads: %+v", threads)
+	}
+
+	frames := c.stack()
+	expected := []StackFrame{
+		{ID: 0, Name: "ned", Line: 5},
+		{ID: 1, Name: "ned", Line: 3},
+		{ID: 2, Name: "hovedprogram", Line: 9},
+	}
+	if len(frames) != len(expected) {
+		t.Fatalf("wrong number of frames, expected %d, got %+v", len(expected), frames)
+	}
+	for i, frame := range frames {
+		if frame.ID != expected[i].ID || frame.Name != expected[i].Name || frame.Line != expected[i].Line ||
+			frame.Source.Path != path || frame.Column != 1 {
+			t.Errorf("frames[%d] wrong, expected %+v, got %+v", i, expected[i], frame)
+		}
+	}
+
+	var scopes ScopesResponseBody
+	c.request("scopes", ScopesArguments{FrameID: 0}, &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "Lokale" || scopes.Scopes[1].Name != "Globale" {
+		t.Fatalf("wrong scopes: %+v", scopes.Scopes)
+	}
+
+	var locals VariablesResponseBody
+	c.request("variables", VariablesArguments{VariablesReference: scopes.Scopes[0].VariablesReference}, &locals)
+	if len(locals.Variables) != 1 || locals.Variables[0] != (Variable{Name: "n", Value: "0", Type: "heltall"}) {
+		t.Errorf("wrong local variables: %+v", locals.Variables)
+	}
+
+	var globals VariablesResponseBody
+	c.request("variables", VariablesArguments{VariablesReference: scopes.Scopes[1].VariablesReference}, &globals)
+	if len(globals.Variables) != 1 || globals.Variables[0] != (Variable{Name: "ned", Value: "funksjon(n) { … }", Type: "funksjon"}) {
+		t.Errorf("wrong global variables: %+v", globals.Variables)
+	}
+
+	c.request("stepOut", map[string]interface{}{"threadId": threadID}, nil)
+	c.stopped("step")
+	if frames := c.stack(); len(frames) != 1 || frames[0].Line != 10 {
+		t.Errorf("wrong frames after stepping out: %+v", frames)
+	}
+
+	// References from an earlier stop are no longer valid.
+	if resp := c.send("variables", VariablesArguments{VariablesReference: 1}); resp.Success {
+		t.Errorf("variables should fail for an old reference")
+	}
+
+	var cont ContinueResponseBody
+	c.request("continue", map[string]interface{}{"threadId": threadID}, &cont)
+	if !cont.AllThreadsContinued {
+		t.Errorf("continue should continue all threads")
+	}
+
+	var exited ExitedEventBody
+	c.event("exited", &exited)
+	if exited.ExitCode != 0 {
+		t.Errorf("wrong exit code, expected 0, got %d", exited.ExitCode)
+	}
+	c.event("terminated", nil)
+
+	if resp := c.send("next", map[string]interface{}{"threadId": threadID}); resp.Success {
+		t.Errorf("next should fail when the program is not stopped")
+	}
+
+	c.request("disconnect", nil, nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("Serve failed: %s", err)
+	}
+}
+
+func TestStopOnEntryAndDisconnect(t *testing.T) {
+	c := newClient(t)
+	c.start(countdown, nil, true)
+
+	c.stopped("entry")
+	c.request("next", map[string]interface{}{"threadId": threadID}, nil)
+	c.stopped("step")
+
+	if frames := c.stack(); len(frames) != 1 || frames[0].Line != 9 {
+		t.Errorf("wrong frames after next: %+v", frames)
+	}
+
+	c.request("disconnect", nil, nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("Serve failed: %s", err)
+	}
+}
+
+func TestRuntimeError(t *testing.T) {
+	c := newClient(t)
+	c.start("la x = 1 + sant;", nil, false)
+
+	var output OutputEventBody
+	c.event("output", &output)
+	if output.Category != "stderr" || output.Output != "FEIL: typene passer ikke sammen: heltall + sannhetsverdi [K002]\n" {
+		t.Errorf("wrong output: %+v", output)
+	}
+
+	var exited ExitedEventBody
+	c.event("exited", &exited)
+	if exited.ExitCode != 1 {
+		t.Errorf("wrong exit code, expected 1, got %d", exited.ExitCode)
+	}
+
+	c.request("disconnect", nil, nil)
+	<-c.done
+}
+
+func TestLaunchErrors(t *testing.T) {
+	c := newClient(t)
+
+	path := filepath.Join(t.TempDir(), "feil.pytonskript")
+	if err := os.WriteFile(path, []byte("la = 1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := c.send("launch", LaunchArguments{Program: path})
+	if resp.Success || resp.Message != "syntaksfeil:\n1:4: forventet et navn, men fant '=' [S001]\n1:4: uventet '=' i starten av et uttrykk [S002]" {
+		t.Errorf("launch should fail with the syntax errors, got %+v", resp)
+	}
+
+	if resp := c.send("configurationDone", nil); resp.Success {
+		t.Errorf("configurationDone should fail without a program")
+	}
+
+	if resp := c.send("evaluate", nil); resp.Success || resp.Message != "ukjent kommando: evaluate" {
+		t.Errorf("unknown commands should fail, got %+v", resp)
+	}
+
+	c.in.Close()
+	if err := <-c.done; err != nil {
+		t.Errorf("Serve failed: %s", err)
+	}
+}
@@ -0,0 +1,77 @@
+// debug.go
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/solbero/pytonskript/dap"
+	"github.com/solbero/pytonskript/debugger"
+	"github.com/solbero/pytonskript/diagnostic"
+	"github.com/solbero/pytonskript/evaluator"
+	"github.com/solbero/pytonskript/lexer"
+	"github.com/solbero/pytonskript/object"
+	"github.com/solbero/pytonskript/parser"
+	"github.com/solbero/pytonskript/token"
+)
+
+// debugCommand implements `pytonskript feilsøk fil`, which runs a program one
+// step at a time with commands from the terminal, and `pytonskript feilsøk
+// --dap`, which lets an editor drive the debugger over stdin and stdout.
+func debugCommand(args []string, dialect *token.Dialect) int {
+	flags := flag.NewFlagSet("feilsøk", flag.ContinueOnError)
+	useDAP := flags.Bool("dap", false, "snakk Debug Adapter Protocol over stdin og stdout")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *useDAP {
+		if flags.NArg() != 0 {
+			fmt.Fprintf(os.Stderr, "bruk: pytonskript feilsøk --dap\n")
+			return 2
+		}
+		if err := dap.NewServer(os.Stdin, os.Stdout, dialect).Serve(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	if flags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "bruk: pytonskript feilsøk [--dap | fil]\n")
+		return 2
+	}
+
+	path := flags.Arg(0)
+	input, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	l := lexer.NewWithDialect(string(input), dialect)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		fmt.Fprintln(os.Stderr, diagnostic.Sprintf(diagnostic.LabelSyntaxError))
+		for _, err := range p.Errors() {
+			fmt.Fprintf(os.Stderr, "\t%s:%s\n", path, err)
+		}
+		return 1
+	}
+
+	program, errObj := evaluator.ExpandProgram(program, l.Dialect())
+	if errObj != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", path, errObj.Inspect())
+		return 1
+	}
+
+	env := object.NewEnvironment()
+	env.SetDialect(l.Dialect())
+
+	fmt.Println("skriv h for hjelp")
+	debugger.NewConsole(os.Stdin, os.Stdout, string(input)).Run(program, env)
+	return 0
+}
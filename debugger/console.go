@@ -0,0 +1,175 @@
+// debugger/console.go
+
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/solbero/pytonskript/ast"
+	"github.com/solbero/pytonskript/object"
+	"github.com/solbero/pytonskript/token"
+)
+
+const consoleHelp = `kommandoer:
+  b, brytepunkt N  stopp på linje N
+  slett N          fjern brytepunktet på linje N
+  f, fortsett      kjør til neste brytepunkt
+  n, neste         gå til neste linje
+  i, inn           gå til neste linje, også inn i funksjoner
+  u, ut            gå ut av funksjonen
+  v, variabler     vis navnene som er definert
+  s, stakk         vis funksjonskallene
+  a, avslutt       avslutt programmet
+  h, hjelp         vis denne hjelpen
+En tom linje gjentar forrige steg.
+`
+
+// Console lets a user drive the debugger by typing commands.
+type Console struct {
+	in       *bufio.Scanner
+	out      io.Writer
+	lines    []string
+	debugger *Debugger
+	last     Action // the action an empty line repeats
+}
+
+// NewConsole returns a console reading commands from in and writing to out.
+// source is the program, for showing the line it stopped at.
+func NewConsole(in io.Reader, out io.Writer, source string) *Console {
+	c := &Console{
+		in:    bufio.NewScanner(in),
+		out:   out,
+		lines: strings.Split(source, "\n"),
+		last:  StepOver,
+	}
+	c.debugger = New(c.stop)
+	return c
+}
+
+// Run runs program in env, stopping before the first statement.
+func (c *Console) Run(program *ast.Program, env *object.Environment) {
+	result, finished := c.debugger.Run(program, env, true)
+	if !finished {
+		fmt.Fprintln(c.out, "programmet ble avsluttet")
+		return
+	}
+
+	if err, ok := result.(*object.Error); ok {
+		fmt.Fprintln(c.out, err.Inspect())
+	}
+	fmt.Fprintln(c.out, "programmet er ferdig")
+}
+
+func (c *Console) stop(stop *Stop) Action {
+	switch stop.Reason {
+	case ReasonBreakpoint:
+		fmt.Fprintf(c.out, "brytepunkt på linje %d\n", stop.Line)
+	}
+	c.showLine(stop.Line)
+
+	for {
+		fmt.Fprint(c.out, "(feilsøk) ")
+		if !c.in.Scan() {
+			fmt.Fprintln(c.out)
+			return Quit
+		}
+
+		fields := strings.Fields(c.in.Text())
+		if len(fields) == 0 {
+			return c.last
+		}
+
+		switch fields[0] {
+		case "f", "fortsett":
+			return Continue
+		case "n", "neste":
+			c.last = StepOver
+			return StepOver
+		case "i", "inn":
+			c.last = StepInto
+			return StepInto
+		case "u", "ut":
+			c.last = StepOut
+			return StepOut
+		case "a", "avslutt":
+			return Quit
+		case "b", "brytepunkt":
+			if line, ok := c.lineArgument(fields); ok {
+				c.debugger.SetBreakpoint(line)
+				fmt.Fprintf(c.out, "brytepunkt på linje %d\n", line)
+			}
+		case "slett":
+			if line, ok := c.lineArgument(fields); ok {
+				c.debugger.ClearBreakpoint(line)
+			}
+		case "v", "variabler":
+			c.showVariables(stop.Frames[0].Env)
+		case "s", "stakk":
+			for i, frame := range stop.Frames {
+				fmt.Fprintf(c.out, "#%d %s, linje %d\n", i, frame.Name, frame.Line)
+			}
+		case "h", "hjelp":
+			fmt.Fprint(c.out, consoleHelp)
+		default:
+			fmt.Fprintf(c.out, "ukjent kommando: %s, skriv h for hjelp\n", fields[0])
+		}
+	}
+}
+
+func (c *Console) lineArgument(fields []string) (int, bool) {
+	if len(fields) == 2 {
+		if line, err := strconv.Atoi(fields[1]); err == nil && line > 0 {
+			return line, true
+		}
+	}
+	fmt.Fprintf(c.out, "bruk: %s linjenummer\n", fields[0])
+	return 0, false
+}
+
+func (c *Console) showLine(line int) {
+	source := ""
+	if line > 0 && line <= len(c.lines) {
+		source = strings.TrimSpace(c.lines[line-1])
+	}
+	fmt.Fprintf(c.out, "%4d  %s\n", line, source)
+}
+
+// showVariables prints the names in env and the environments around it,
+// innermost first.
+func (c *Console) showVariables(env *object.Environment) {
+	for e := env; e != nil; e = e.Outer() {
+		switch {
+		case e.Outer() == nil:
+			fmt.Fprintln(c.out, "globale:")
+		case e == env:
+			fmt.Fprintln(c.out, "lokale:")
+		default:
+			fmt.Fprintln(c.out, "ytre:")
+		}
+
+		for _, name := range e.Names() {
+			value, _ := e.Get(name)
+			fmt.Fprintf(c.out, "  %s = %s\n", name, Describe(value, env.Dialect()))
+		}
+	}
+}
+
+// Describe returns a short description of obj, where functions are shown by
+// their parameters instead of their whole body.
+func Describe(obj object.Object, dialect *token.Dialect) string {
+	fn, ok := obj.(*object.Function)
+	if !ok {
+		return obj.Inspect()
+	}
+
+	params := []string{}
+	for _, p := range fn.Parameters {
+		params = append(params, p.Value)
+	}
+	keyword, _ := dialect.Keyword(token.FUNCTION)
+	return keyword + "(" + strings.Join(params, ", ") + ") { … }"
+}
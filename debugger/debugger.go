@@ -0,0 +1,225 @@
+// debugger/debugger.go
+
+// Package debugger runs programs one statement at a time, stopping at
+// breakpoints and after steps so the environment and call stack can be
+// inspected.
+package debugger
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/solbero/pytonskript/ast"
+	"github.com/solbero/pytonskript/evaluator"
+	"github.com/solbero/pytonskript/object"
+)
+
+// Action tells a stopped program what to do next.
+type Action int
+
+const (
+	Continue Action = iota // run to the next breakpoint
+	StepInto               // stop at the next line, also inside functions
+	StepOver               // stop at the next line in the same function
+	StepOut                // stop after the current function returns
+	Quit                   // end the program
+)
+
+// Reason tells why a program stopped.
+type Reason string
+
+const (
+	ReasonEntry      Reason = "entry"
+	ReasonBreakpoint Reason = "breakpoint"
+	ReasonStep       Reason = "step"
+)
+
+// MainFrame is the name of the frame for the program itself.
+const MainFrame = "hovedprogram"
+
+// Frame is a function call in progress.
+type Frame struct {
+	Name string
+	Line int // the line of the statement being run
+	Env  *object.Environment
+}
+
+// Stop describes where a program stopped. Frames starts with the innermost
+// call and ends with the program itself.
+type Stop struct {
+	Reason Reason
+	Line   int
+	Frames []Frame
+}
+
+type Debugger struct {
+	onStop func(*Stop) Action
+
+	mu          sync.Mutex
+	breakpoints map[int]bool
+	quit        bool
+
+	frames   []*Frame
+	action   Action
+	entry    bool // the next stop is the first
+	stepLine int  // where the last step started
+	stepSize int  // the number of frames when the last step started
+}
+
+// New returns a debugger that calls onStop every time the program stops.
+// onStop runs on the goroutine running the program, which waits until it
+// returns.
+func New(onStop func(*Stop) Action) *Debugger {
+	return &Debugger{onStop: onStop, breakpoints: make(map[int]bool)}
+}
+
+// SetBreakpoints replaces the breakpoints with the given lines. It may be
+// called while the program runs.
+func (d *Debugger) SetBreakpoints(lines []int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.breakpoints = make(map[int]bool)
+	for _, line := range lines {
+		d.breakpoints[line] = true
+	}
+}
+
+func (d *Debugger) SetBreakpoint(line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints[line] = true
+}
+
+func (d *Debugger) ClearBreakpoint(line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.breakpoints, line)
+}
+
+// Breakpoints returns the lines with breakpoints, sorted.
+func (d *Debugger) Breakpoints() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	lines := []int{}
+	for line := range d.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// Quit ends the program before its next statement. It may be called while
+// the program runs.
+func (d *Debugger) Quit() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.quit = true
+}
+
+// quitSignal unwinds the evaluator when the program is ended early.
+type quitSignal struct{}
+
+// Run evaluates program in env under the debugger, stopping before the first
+// statement if stopOnEntry is true. It reports false if the program was ended
+// with Quit before it finished.
+func (d *Debugger) Run(program *ast.Program, env *object.Environment, stopOnEntry bool) (result object.Object, finished bool) {
+	d.frames = []*Frame{{Name: MainFrame, Env: env}}
+	d.action = Continue
+	d.entry = stopOnEntry
+	if stopOnEntry {
+		d.action = StepInto
+	}
+
+	env.SetHook(d)
+	defer env.SetHook(nil)
+
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(quitSignal); !ok {
+				panic(r)
+			}
+			result, finished = nil, false
+		}
+	}()
+
+	return evaluator.Eval(program, env), true
+}
+
+func (d *Debugger) Statement(stmt ast.Statement, env *object.Environment) {
+	line := statementLine(stmt)
+
+	d.mu.Lock()
+	quit := d.quit
+	breakpoint := d.breakpoints[line]
+	d.mu.Unlock()
+
+	if quit {
+		panic(quitSignal{})
+	}
+
+	top := d.frames[len(d.frames)-1]
+	newLine := top.Line != line
+	top.Line = line
+	top.Env = env
+
+	size := len(d.frames)
+
+	var reason Reason
+	switch {
+	case d.action == StepInto && (size != d.stepSize || line != d.stepLine):
+		reason = ReasonStep
+	case d.action == StepOver && (size < d.stepSize || (size == d.stepSize && line != d.stepLine)):
+		reason = ReasonStep
+	case d.action == StepOut && size < d.stepSize:
+		reason = ReasonStep
+	case breakpoint && newLine:
+		reason = ReasonBreakpoint
+	default:
+		return
+	}
+
+	if d.entry {
+		reason = ReasonEntry
+		d.entry = false
+	}
+
+	stop := &Stop{Reason: reason, Line: line}
+	for i := len(d.frames) - 1; i >= 0; i-- {
+		stop.Frames = append(stop.Frames, *d.frames[i])
+	}
+
+	d.action = d.onStop(stop)
+	if d.action == Quit {
+		d.Quit()
+		panic(quitSignal{})
+	}
+	d.stepLine = line
+	d.stepSize = size
+}
+
+func (d *Debugger) Call(call *ast.CallExpression, fn *object.Function) {
+	name := "<anonym>"
+	if ident, ok := call.Function.(*ast.Identifier); ok {
+		name = ident.Value
+	}
+	d.frames = append(d.frames, &Frame{Name: name, Env: fn.Env})
+}
+
+func (d *Debugger) Return(call *ast.CallExpression, result object.Object) {
+	d.frames = d.frames[:len(d.frames)-1]
+}
+
+func statementLine(stmt ast.Statement) int {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token.Pos.Line
+	case *ast.ReturnStatement:
+		return stmt.Token.Pos.Line
+	case *ast.ExpressionStatement:
+		return stmt.Token.Pos.Line
+	default:
+		return 0
+	}
+}
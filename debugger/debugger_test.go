@@ -0,0 +1,263 @@
+// debugger/debugger_test.go
+
+package debugger
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/solbero/pytonskript/ast"
+	"github.com/solbero/pytonskript/lexer"
+	"github.com/solbero/pytonskript/object"
+	"github.com/solbero/pytonskript/parser"
+)
+
+const countdown = `la ned = funksjon(n) {
+  hvis (n > 0) {
+    ned(n - 1);
+  } ellers {
+    0
+  }
+};
+
+la a = ned(2);
+la b = a + 1;
+b`
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+// describeStop returns the reason, line and call stack of a stop, such as
+// "step 3 ned:3 ned:3 hovedprogram:9".
+func describeStop(stop *Stop) string {
+	parts := []string{fmt.Sprintf("%s %d", stop.Reason, stop.Line)}
+	for _, frame := range stop.Frames {
+		parts = append(parts, fmt.Sprintf("%s:%d", frame.Name, frame.Line))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestStepping(t *testing.T) {
+	tests := []struct {
+		name        string
+		breakpoints []int
+		stopOnEntry bool
+		actions     []Action
+		expected    []string
+	}{
+		{
+			name:        "step into",
+			stopOnEntry: true,
+			actions:     []Action{StepInto, StepInto, StepInto, StepInto, StepInto, StepInto, Continue},
+			expected: []string{
+				"entry 1 hovedprogram:1",
+				"step 9 hovedprogram:9",
+				"step 2 ned:2 hovedprogram:9",
+				"step 3 ned:3 hovedprogram:9",
+				"step 2 ned:2 ned:3 hovedprogram:9",
+				"step 3 ned:3 ned:3 hovedprogram:9",
+				"step 2 ned:2 ned:3 ned:3 hovedprogram:9",
+			},
+		},
+		{
+			name:        "step over",
+			stopOnEntry: true,
+			actions:     []Action{StepOver, StepOver, StepOver, StepOver},
+			expected: []string{
+				"entry 1 hovedprogram:1",
+				"step 9 hovedprogram:9",
+				"step 10 hovedprogram:10",
+				"step 11 hovedprogram:11",
+			},
+		},
+		{
+			name:        "breakpoint and step out",
+			breakpoints: []int{5},
+			actions:     []Action{StepOut, StepOver, StepOut},
+			expected: []string{
+				"breakpoint 5 ned:5 ned:3 ned:3 hovedprogram:9",
+				"step 10 hovedprogram:10",
+				"step 11 hovedprogram:11",
+			},
+		},
+		{
+			name:        "breakpoint in recursion",
+			breakpoints: []int{3},
+			actions:     []Action{Continue, Continue},
+			expected: []string{
+				"breakpoint 3 ned:3 hovedprogram:9",
+				"breakpoint 3 ned:3 ned:3 hovedprogram:9",
+			},
+		},
+		{
+			name:        "step over from the end of a function",
+			breakpoints: []int{5},
+			actions:     []Action{StepOver, Continue},
+			expected: []string{
+				"breakpoint 5 ned:5 ned:3 ned:3 hovedprogram:9",
+				"step 10 hovedprogram:10",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		stops := []string{}
+		d := New(func(stop *Stop) Action {
+			stops = append(stops, describeStop(stop))
+			if len(stops) > len(tt.actions) {
+				return Continue
+			}
+			return tt.actions[len(stops)-1]
+		})
+		d.SetBreakpoints(tt.breakpoints)
+
+		result, finished := d.Run(parse(t, countdown), object.NewEnvironment(), tt.stopOnEntry)
+
+		if !finished {
+			t.Errorf("%s: program did not finish", tt.name)
+		} else if integer, ok := result.(*object.Integer); !ok || integer.Value != 1 {
+			t.Errorf("%s: wrong result %v", tt.name, result)
+		}
+
+		if strings.Join(stops, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%s: wrong stops, expected\n%s\ngot\n%s", tt.name,
+				strings.Join(tt.expected, "\n"), strings.Join(stops, "\n"))
+		}
+	}
+}
+
+func TestBuiltinCallbacks(t *testing.T) {
+	input := `la dobbel = funksjon(x) {
+  x * 2
+};
+tilordne([1], dobbel);
+filtrer([1], funksjon(x) {
+  sant
+});`
+
+	stops := []string{}
+	d := New(func(stop *Stop) Action {
+		stops = append(stops, describeStop(stop))
+		return Continue
+	})
+	d.SetBreakpoints([]int{2, 6})
+
+	d.Run(parse(t, input), object.NewEnvironment(), false)
+
+	expected := []string{
+		"breakpoint 2 dobbel:2 hovedprogram:4",
+		"breakpoint 6 <anonym>:6 hovedprogram:5",
+	}
+	if strings.Join(stops, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong stops, expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(stops, "\n"))
+	}
+}
+
+func TestStopEnvironment(t *testing.T) {
+	var env *object.Environment
+	var globals []string
+	d := New(func(stop *Stop) Action {
+		env = stop.Frames[0].Env
+		globals = stop.Frames[len(stop.Frames)-1].Env.Names()
+		return Continue
+	})
+	d.SetBreakpoint(5)
+
+	d.Run(parse(t, countdown), object.NewEnvironment(), false)
+
+	if env == nil {
+		t.Fatalf("did not stop at the breakpoint")
+	}
+
+	n, ok := env.Get("n")
+	if !ok || n.Inspect() != "0" {
+		t.Errorf("wrong value of n, got %v", n)
+	}
+
+	depth := 0
+	for e := env; e != nil; e = e.Outer() {
+		depth++
+	}
+	if depth != 2 {
+		t.Errorf("function environment should be enclosed in the global one, got depth %d", depth)
+	}
+
+	if strings.Join(globals, ",") != "ned" {
+		t.Errorf("wrong global names when stopped in the function, got %v", globals)
+	}
+}
+
+func TestQuit(t *testing.T) {
+	d := New(func(stop *Stop) Action { return Quit })
+
+	env := object.NewEnvironment()
+	_, finished := d.Run(parse(t, countdown), env, true)
+
+	if finished {
+		t.Errorf("program should not finish after Quit")
+	}
+	if env.Hook() != nil {
+		t.Errorf("hook should be removed after Run")
+	}
+	if _, ok := env.Get("ned"); ok {
+		t.Errorf("no statements should run after quitting on entry")
+	}
+}
+
+func TestConsole(t *testing.T) {
+	input := strings.Join([]string{
+		"b 5",
+		"f",
+		"s",
+		"v",
+		"u",
+		"n",
+		"hjelp",
+		"x",
+		"",
+	}, "\n") + "\n"
+
+	var out strings.Builder
+	c := NewConsole(strings.NewReader(input), &out, countdown)
+	c.Run(parse(t, countdown), object.NewEnvironment())
+
+	expected := `   1  la ned = funksjon(n) {
+(feilsøk) brytepunkt på linje 5
+(feilsøk) brytepunkt på linje 5
+   5  0
+(feilsøk) #0 ned, linje 5
+#1 ned, linje 3
+#2 ned, linje 3
+#3 hovedprogram, linje 9
+(feilsøk) lokale:
+  n = 0
+globale:
+  ned = funksjon(n) { … }
+(feilsøk)   10  la b = a + 1;
+(feilsøk)   11  b
+(feilsøk) ` + consoleHelp + `(feilsøk) ukjent kommando: x, skriv h for hjelp
+(feilsøk) programmet er ferdig
+`
+
+	if out.String() != expected {
+		t.Errorf("wrong output, expected\n%s\ngot\n%s", expected, out.String())
+	}
+}
+
+func TestConsoleEndOfInput(t *testing.T) {
+	var out strings.Builder
+	c := NewConsole(strings.NewReader(""), &out, countdown)
+	c.Run(parse(t, countdown), object.NewEnvironment())
+
+	if !strings.HasSuffix(out.String(), "programmet ble avsluttet\n") {
+		t.Errorf("program should end when the input ends, got\n%s", out.String())
+	}
+}
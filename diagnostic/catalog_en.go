@@ -0,0 +1,94 @@
+// diagnostic/catalog_en.go
+
+package diagnostic
+
+import "github.com/solbero/pytonskript/token"
+
+var english = map[Code]string{
+	ExpectedToken:   "expected next token to be %s, got %s instead",
+	UnexpectedToken: "no prefix parse function for %s found",
+	InvalidInteger:  "could not parse %q as integer",
+	UnknownDialect:  "unknown dialect: %s",
+
+	UnknownEscape:         "unknown escape sequence in string: %s",
+	InvalidUnicodeEscape:  "invalid Unicode escape in string: %s, write for example \\u{e5}",
+	UnterminatedString:    "string is never closed, missing %s",
+	UnclosedInterpolation: "the expression in the string is never closed with '}', write \\{ for a brace in the text",
+
+	IdentifierNotFound:    "identifier not found: %s",
+	TypeMismatch:          "type mismatch: %s %s %s",
+	UnknownPrefixOperator: "unknown operator: %s%s",
+	UnknownInfixOperator:  "unknown operator: %s %s %s",
+	UnusableAsHashKey:     "unusable as hash key: %s",
+	IndexNotSupported:     "index operator not supported: %s",
+	NotAFunction:          "not a function: %s",
+	WrongArgumentCount:    "wrong number of arguments, got %d, want %d",
+	TooFewArguments:       "wrong number of arguments, got %d, want at least %d",
+	TooManyArguments:      "wrong number of arguments, got %d, want at most %d",
+	ArgumentNotSupported:  "argument to '%s' not supported, got %s",
+	ArgumentMustBe:        "argument to '%s' must be %s, got %s",
+	NthArgumentMustBe:     "argument %d to '%s' must be %s, got %s",
+	InvalidSliceIndices:   "invalid slice indices: start=%d, stop=%d",
+	MacroMustReturnQuote:  "a macro must return a quote, got %s",
+	UnquoteNotSupported:   "cannot unquote %s",
+	NegativeCount:         "count to '%s' must not be negative, got %d",
+	NotOneCharacter:       "argument %d to '%s' must be a single character, got %q",
+	PlaceholderCount:      "the template has %d placeholders, but got %d values",
+	UnknownPlaceholder:    "unknown placeholder in the template: %q",
+	OptionMustBe:          "option %q to '%s' must be %s, got %s",
+	UnknownOption:         "unknown option to '%s': %s",
+	PlaceholderTooWide:    "the placeholder %q is too wide, the width and precision can be at most %d",
+	CannotConvert:         "cannot convert %q to %s",
+	DivisionByZero:        "division by zero",
+	StringTooLong:         "the string from '%s' would be too long, the limit is %d bytes",
+
+	ReservedName: "'%s' is reserved in %s and cannot be used as a name",
+
+	TooManyCallArguments: "too many arguments in call: %d, at most %d fit in bytecode",
+	TooManyLocals:        "too many local names in function: %d, at most %d fit in bytecode",
+	UnknownOperator:      "cannot compile the unknown operator %s",
+	CannotCompile:        "cannot compile %s",
+	OperandTooLarge:      "the program is too large for bytecode: %s needs %d, but at most %d fit",
+	QuoteArgumentCount:   "'%s' takes one argument, got %d",
+	UnquoteNotCompiled:   "'%s' in a quote outside a macro cannot be compiled to bytecode",
+
+	NotBytecode:          "bytecode: the file is not bytecode",
+	CorruptBytecode:      "bytecode: the file is damaged",
+	WrongBytecodeVersion: "bytecode: the file has version %d, but this version of pytonskript only runs version %d",
+	UnwritableConstant:   "bytecode: cannot write constants of type %s",
+	HeaderTooShort:       "the header is too short",
+	ChecksumMismatch:     "the checksum does not match",
+	TrailingBytes:        "%d bytes too many at the end",
+	UnexpectedEnd:        "the file ends too early",
+	InvalidNumber:        "invalid number",
+	ListTooLong:          "a list is longer than the file",
+	UnknownConstantType:  "unknown type of constant: %d",
+	TooManyGlobals:       "too many global names: %d",
+	InvalidLocalCount:    "constant %d has %d locals and %d parameters",
+	LocalNamesMismatch:   "constant %d has %d locals, but %d are named",
+	TooManyFree:          "constant %d has %d free names",
+	UnknownInstruction:   "%s: unknown instruction %d at position %d",
+	TruncatedInstruction: "%s: %s at position %d is cut off",
+	InvalidInstruction:   "%s: invalid %s at position %d",
+	MissingReturn:        "%s does not end with a return",
+	InvalidJump:          "%s: jump to %d is not the start of an instruction",
+	InvalidPosition:      "%s: invalid source position %d",
+	StackUnderflow:       "%s: %s at position %d takes more values than the stack holds",
+	StackMismatch:        "%s: the stack has different heights in the jumps to position %d",
+	MainProgram:          "the main program",
+	FunctionInConstant:   "the function in constant %d",
+
+	UnusedName:              "'%s' declared and not used",
+	ShadowedName:            "'%s' shadows the declaration on line %d",
+	ShadowedBuiltin:         "'%s' shadows the builtin function of the same name",
+	UnreachableCode:         "unreachable code after '%s'",
+	RedundantBoolComparison: "redundant comparison with %s, use the condition directly",
+
+	LabelError:       "ERROR: %s",
+	LabelWarning:     "warning: %s",
+	LabelSyntaxError: "parser errors:",
+}
+
+var tokenNamesEnglish = map[token.TokenType]string{}
+
+var typeNamesEnglish = map[string]string{}
@@ -0,0 +1,156 @@
+// diagnostic/catalog_nb.go
+
+package diagnostic
+
+import "github.com/solbero/pytonskript/token"
+
+var bokmal = map[Code]string{
+	ExpectedToken:   "forventet %s, men fant %s",
+	UnexpectedToken: "uventet %s i starten av et uttrykk",
+	InvalidInteger:  "kunne ikke tolke %q som et heltall",
+	UnknownDialect:  "ukjent dialekt: %s",
+
+	UnknownEscape:         "ukjent escape-sekvens i strengen: %s",
+	InvalidUnicodeEscape:  "ugyldig Unicode-escape i strengen: %s, skriv for eksempel \\u{e5}",
+	UnterminatedString:    "strengen blir aldri avsluttet, mangler %s",
+	UnclosedInterpolation: "uttrykket i strengen blir aldri avsluttet med '}', skriv \\{ for en krøllparentes i teksten",
+
+	IdentifierNotFound:    "navnet er ikke definert: %s",
+	TypeMismatch:          "typene passer ikke sammen: %s %s %s",
+	UnknownPrefixOperator: "ukjent operator: %s%s",
+	UnknownInfixOperator:  "ukjent operator: %s %s %s",
+	UnusableAsHashKey:     "kan ikke brukes som nøkkel i en tabell: %s",
+	IndexNotSupported:     "kan ikke indeksere %s",
+	NotAFunction:          "ikke en funksjon: %s",
+	WrongArgumentCount:    "feil antall argumenter, fikk %d, forventet %d",
+	TooFewArguments:       "feil antall argumenter, fikk %d, forventet minst %d",
+	TooManyArguments:      "feil antall argumenter, fikk %d, forventet høyst %d",
+	ArgumentNotSupported:  "argumentet til '%s' støttes ikke, fikk %s",
+	ArgumentMustBe:        "argumentet til '%s' må være %s, fikk %s",
+	NthArgumentMustBe:     "argument %d til '%s' må være %s, fikk %s",
+	InvalidSliceIndices:   "ugyldige indekser for kutt: start=%d, stopp=%d",
+	MacroMustReturnQuote:  "en makro må returnere et sitat, fikk %s",
+	UnquoteNotSupported:   "kan ikke avsitere %s",
+	NegativeCount:         "antallet til '%s' kan ikke være negativt, fikk %d",
+	NotOneCharacter:       "argument %d til '%s' må være ett tegn, fikk %q",
+	PlaceholderCount:      "malen har %d plassholdere, men fikk %d verdier",
+	UnknownPlaceholder:    "ukjent plassholder i malen: %q",
+	OptionMustBe:          "valget %q til '%s' må være %s, fikk %s",
+	UnknownOption:         "ukjent valg til '%s': %s",
+	PlaceholderTooWide:    "plassholderen %q er for bred, bredden og antall desimaler kan være høyst %d",
+	CannotConvert:         "kan ikke gjøre %q om til %s",
+	DivisionByZero:        "kan ikke dele på null",
+	StringTooLong:         "strengen fra '%s' blir for lang, grensen er %d byte",
+
+	ReservedName: "'%s' er reservert i %s og kan ikke brukes som navn",
+
+	TooManyCallArguments: "for mange argumenter i kallet: %d, høyst %d får plass i bytekode",
+	TooManyLocals:        "for mange lokale navn i funksjonen: %d, høyst %d får plass i bytekode",
+	UnknownOperator:      "kan ikke kompilere den ukjente operatoren %s",
+	CannotCompile:        "kan ikke kompilere %s",
+	OperandTooLarge:      "programmet er for stort for bytekode: %s trenger %d, men høyst %d får plass",
+	QuoteArgumentCount:   "'%s' tar ett argument, fikk %d",
+	UnquoteNotCompiled:   "'%s' i et sitat utenfor en makro kan ikke kompileres til bytekode",
+
+	NotBytecode:          "bytekode: filen er ikke bytekode",
+	CorruptBytecode:      "bytekode: filen er skadet",
+	WrongBytecodeVersion: "bytekode: filen har versjon %d, men denne versjonen av pytonskript kjører bare versjon %d",
+	UnwritableConstant:   "bytekode: kan ikke skrive konstanter av typen %s",
+	HeaderTooShort:       "headeren er for kort",
+	ChecksumMismatch:     "sjekksummen stemmer ikke",
+	TrailingBytes:        "%d byte for mye på slutten",
+	UnexpectedEnd:        "filen slutter for tidlig",
+	InvalidNumber:        "ugyldig tall",
+	ListTooLong:          "listen er lengre enn filen",
+	UnknownConstantType:  "ukjent type konstant: %d",
+	TooManyGlobals:       "for mange globale navn: %d",
+	InvalidLocalCount:    "konstant %d har %d lokale navn og %d parametere",
+	LocalNamesMismatch:   "konstant %d har %d lokale navn, men %d er navngitt",
+	TooManyFree:          "konstant %d har %d frie navn",
+	UnknownInstruction:   "%s: ukjent instruksjon %d på posisjon %d",
+	TruncatedInstruction: "%s: %s på posisjon %d er kuttet av",
+	InvalidInstruction:   "%s: ugyldig %s på posisjon %d",
+	MissingReturn:        "%s slutter ikke med en retur",
+	InvalidJump:          "%s: hopp til %d er ikke starten på en instruksjon",
+	InvalidPosition:      "%s: ugyldig kildeposisjon %d",
+	StackUnderflow:       "%s: %s på posisjon %d tar flere verdier enn stakken har",
+	StackMismatch:        "%s: stakken har ulik høyde i hoppene til posisjon %d",
+	MainProgram:          "hovedprogrammet",
+	FunctionInConstant:   "funksjonen i konstant %d",
+
+	UnusedName:              "'%s' er definert, men blir aldri brukt",
+	ShadowedName:            "'%s' skygger for navnet som er definert på linje %d",
+	ShadowedBuiltin:         "'%s' skygger for den innebygde funksjonen med samme navn",
+	UnreachableCode:         "koden etter '%s' blir aldri kjørt",
+	RedundantBoolComparison: "unødvendig sammenligning med %s, bruk betingelsen direkte",
+
+	LabelError:       "FEIL: %s",
+	LabelWarning:     "advarsel: %s",
+	LabelSyntaxError: "syntaksfeil:",
+}
+
+var tokenNamesBokmal = map[token.TokenType]string{
+	token.ILLEGAL: "ugyldig tegn",
+	token.EOF:     "slutten av programmet",
+	token.IDENT:   "et navn",
+	token.INT:     "et heltall",
+	token.STRING:  "en streng",
+
+	token.STRING_START:  "en streng",
+	token.RAW_STRING:    "en streng",
+	token.STRING_MIDDLE: "'}'",
+	token.STRING_END:    "'}'",
+
+	token.ASSIGN:   "'='",
+	token.PLUS:     "'+'",
+	token.MINUS:    "'-'",
+	token.BANG:     "'!'",
+	token.ASTERISK: "'*'",
+	token.SLASH:    "'/'",
+	token.LT:       "'<'",
+	token.GT:       "'>'",
+	token.EQ:       "'=='",
+	token.NOT_EQ:   "'!='",
+	token.NULLISH:  "'??'",
+
+	token.COLON:     "':'",
+	token.SEMICOLON: "';'",
+	token.COMMA:     "','",
+	token.LPAREN:    "'('",
+	token.RPAREN:    "')'",
+	token.LBRACE:    "'{'",
+	token.RBRACE:    "'}'",
+	token.LBRACKET:  "'['",
+	token.RBRACKET:  "']'",
+
+	token.OPTIONAL_LBRACKET: "'?['",
+
+	token.FUNCTION: "'funksjon'",
+	token.LET:      "'la'",
+	token.TRUE:     "'sant'",
+	token.FALSE:    "'falskt'",
+	token.IF:       "'hvis'",
+	token.ELSE:     "'ellers'",
+	token.RETURN:   "'returner'",
+	token.MACRO:    "'makro'",
+	token.IN:       "'i'",
+	token.NULL:     "'ingenting'",
+}
+
+var typeNamesBokmal = map[string]string{
+	"INTEGER":      "heltall",
+	"FLOAT":        "desimaltall",
+	"BOOLEAN":      "sannhetsverdi",
+	"NULL":         "ingenting",
+	"RETURN_VALUE": "returverdi",
+	"ERROR":        "feil",
+	"FUNCTION":     "funksjon",
+	"STRING":       "streng",
+	"BUILTIN":      "innebygd funksjon",
+	"ARRAY":        "liste",
+	"HASH":         "tabell",
+	"QUOTE":        "sitat",
+	"MACRO":        "makro",
+
+	"PRINT_OPTIONS": "skrivevalg",
+}
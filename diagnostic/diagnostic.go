@@ -0,0 +1,233 @@
+// diagnostic/diagnostic.go
+
+package diagnostic
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/solbero/pytonskript/token"
+)
+
+// Language selects which message catalog diagnostics are printed from.
+type Language string
+
+const (
+	Bokmal  Language = "nb"
+	English Language = "en"
+)
+
+// EnvLanguage is the environment variable used to select the language of
+// diagnostics when no flag is given.
+const EnvLanguage = "PYTONSKRIPT_SPRAK"
+
+var (
+	catalogs = map[Language]map[Code]string{
+		Bokmal:  bokmal,
+		English: english,
+	}
+	tokenNames = map[Language]map[token.TokenType]string{
+		Bokmal:  tokenNamesBokmal,
+		English: tokenNamesEnglish,
+	}
+	typeNames = map[Language]map[string]string{
+		Bokmal:  typeNamesBokmal,
+		English: typeNamesEnglish,
+	}
+)
+
+var current = Bokmal
+
+// SetLanguage selects the catalog used by all later diagnostics.
+func SetLanguage(lang Language) {
+	current = lang
+}
+
+// CurrentLanguage returns the language diagnostics are printed in.
+func CurrentLanguage() Language {
+	return current
+}
+
+// ParseLanguage accepts a language code or name, e.g. "nb", "bokmål" or "en".
+func ParseLanguage(s string) (Language, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "nb", "no", "bokmål", "bokmal", "norsk":
+		return Bokmal, true
+	case "en", "engelsk", "english":
+		return English, true
+	default:
+		return "", false
+	}
+}
+
+// LanguageFromEnv returns the language set in EnvLanguage, if any.
+func LanguageFromEnv() (Language, bool) {
+	value, ok := os.LookupEnv(EnvLanguage)
+	if !ok {
+		return "", false
+	}
+	return ParseLanguage(value)
+}
+
+// Code is a stable identifier for a message in the catalog. Codes never change
+// meaning, so they can be searched for regardless of the language in use.
+//
+// Codes starting with S are syntax errors found by the lexer and parser, K
+// are errors raised while running a program, O are problems translating
+// between dialects, C are programs the compiler cannot turn into bytecode, B
+// are problems reading and writing bytecode files and A are likely mistakes
+// found by static analysis. Codes without a number are
+// labels used when printing diagnostics.
+type Code string
+
+const (
+	ExpectedToken         Code = "S001"
+	UnexpectedToken       Code = "S002"
+	InvalidInteger        Code = "S003"
+	UnknownDialect        Code = "S004"
+	UnknownEscape         Code = "S005"
+	InvalidUnicodeEscape  Code = "S006"
+	UnterminatedString    Code = "S007"
+	UnclosedInterpolation Code = "S008"
+
+	IdentifierNotFound    Code = "K001"
+	TypeMismatch          Code = "K002"
+	UnknownPrefixOperator Code = "K003"
+	UnknownInfixOperator  Code = "K004"
+	UnusableAsHashKey     Code = "K005"
+	IndexNotSupported     Code = "K006"
+	NotAFunction          Code = "K007"
+	WrongArgumentCount    Code = "K008"
+	TooFewArguments       Code = "K009"
+	TooManyArguments      Code = "K010"
+	ArgumentNotSupported  Code = "K011"
+	ArgumentMustBe        Code = "K012"
+	NthArgumentMustBe     Code = "K013"
+	InvalidSliceIndices   Code = "K014"
+	MacroMustReturnQuote  Code = "K015"
+	UnquoteNotSupported   Code = "K016"
+	NegativeCount         Code = "K017"
+	NotOneCharacter       Code = "K018"
+	PlaceholderCount      Code = "K019"
+	UnknownPlaceholder    Code = "K020"
+	OptionMustBe          Code = "K021"
+	UnknownOption         Code = "K022"
+	PlaceholderTooWide    Code = "K023"
+	CannotConvert         Code = "K024"
+	DivisionByZero        Code = "K025"
+	StringTooLong         Code = "K026"
+
+	ReservedName Code = "O001"
+
+	TooManyCallArguments Code = "C001"
+	TooManyLocals        Code = "C002"
+	UnknownOperator      Code = "C003"
+	CannotCompile        Code = "C004"
+	OperandTooLarge      Code = "C005"
+	QuoteArgumentCount   Code = "C006"
+	UnquoteNotCompiled   Code = "C007"
+
+	NotBytecode          Code = "B001"
+	CorruptBytecode      Code = "B002"
+	WrongBytecodeVersion Code = "B003"
+	UnwritableConstant   Code = "B004"
+	HeaderTooShort       Code = "B005"
+	ChecksumMismatch     Code = "B006"
+	TrailingBytes        Code = "B007"
+	UnexpectedEnd        Code = "B008"
+	InvalidNumber        Code = "B009"
+	ListTooLong          Code = "B010"
+	UnknownConstantType  Code = "B011"
+	TooManyGlobals       Code = "B012"
+	InvalidLocalCount    Code = "B013"
+	LocalNamesMismatch   Code = "B014"
+	TooManyFree          Code = "B015"
+	UnknownInstruction   Code = "B016"
+	TruncatedInstruction Code = "B017"
+	InvalidInstruction   Code = "B018"
+	MissingReturn        Code = "B019"
+	InvalidJump          Code = "B020"
+	InvalidPosition      Code = "B021"
+	StackUnderflow       Code = "B022"
+	StackMismatch        Code = "B023"
+	MainProgram          Code = "B024"
+	FunctionInConstant   Code = "B025"
+
+	UnusedName              Code = "A001"
+	ShadowedName            Code = "A002"
+	ShadowedBuiltin         Code = "A003"
+	UnreachableCode         Code = "A004"
+	RedundantBoolComparison Code = "A005"
+
+	LabelError       Code = "error"
+	LabelWarning     Code = "warning"
+	LabelSyntaxError Code = "syntax-errors"
+)
+
+// Sprintf formats the message for code in the current language. Messages
+// missing from the current catalog fall back to English.
+func Sprintf(code Code, a ...interface{}) string {
+	format, ok := catalogs[current][code]
+	if !ok {
+		format, ok = english[code]
+	}
+	if !ok {
+		return string(code)
+	}
+	return fmt.Sprintf(format, a...)
+}
+
+// TypeName returns the name of an object type as it should appear in messages.
+func TypeName(t string) string {
+	if name, ok := typeNames[current][t]; ok {
+		return name
+	}
+	return t
+}
+
+// TokenName returns a description of a token type as it should appear in
+// messages.
+func TokenName(t token.TokenType) string {
+	if name, ok := tokenNames[current][t]; ok {
+		return name
+	}
+	return string(t)
+}
+
+// Severity tells whether a diagnostic stops a program from working or only
+// points out something that is probably a mistake.
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+// Diagnostic is a message tied to a position in the source.
+type Diagnostic struct {
+	Code     Code
+	Pos      token.Position
+	Severity Severity
+	Message  string
+}
+
+func New(code Code, pos token.Position, a ...interface{}) *Diagnostic {
+	return &Diagnostic{Code: code, Pos: pos, Message: Sprintf(code, a...)}
+}
+
+func NewWarning(code Code, pos token.Position, a ...interface{}) *Diagnostic {
+	d := New(code, pos, a...)
+	d.Severity = SeverityWarning
+	return d
+}
+
+func (d *Diagnostic) Error() string {
+	message := d.Message
+	if d.Severity == SeverityWarning {
+		message = Sprintf(LabelWarning, message)
+	}
+	return fmt.Sprintf("%d:%d: %s [%s]", d.Pos.Line, d.Pos.Column, message, d.Code)
+}
+
+func (d *Diagnostic) String() string { return d.Error() }
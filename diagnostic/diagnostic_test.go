@@ -0,0 +1,113 @@
+// diagnostic/diagnostic_test.go
+
+package diagnostic
+
+import (
+	"testing"
+
+	"github.com/solbero/pytonskript/token"
+)
+
+func TestSprintf(t *testing.T) {
+	tests := []struct {
+		language Language
+		code     Code
+		args     []interface{}
+		expected string
+	}{
+		{Bokmal, IdentifierNotFound, []interface{}{"x"}, "navnet er ikke definert: x"},
+		{English, IdentifierNotFound, []interface{}{"x"}, "identifier not found: x"},
+		{Bokmal, WrongArgumentCount, []interface{}{2, 1}, "feil antall argumenter, fikk 2, forventet 1"},
+		{English, WrongArgumentCount, []interface{}{2, 1}, "wrong number of arguments, got 2, want 1"},
+		{Bokmal, Code("X999"), nil, "X999"},
+	}
+
+	defer SetLanguage(CurrentLanguage())
+
+	for _, tt := range tests {
+		SetLanguage(tt.language)
+		actual := Sprintf(tt.code, tt.args...)
+		if actual != tt.expected {
+			t.Errorf("Sprintf(%s) in %s wrong, expected %q, got %q", tt.code, tt.language, tt.expected, actual)
+		}
+	}
+}
+
+func TestEnglishFallback(t *testing.T) {
+	defer SetLanguage(CurrentLanguage())
+
+	format := bokmal[IdentifierNotFound]
+	delete(bokmal, IdentifierNotFound)
+	defer func() { bokmal[IdentifierNotFound] = format }()
+
+	SetLanguage(Bokmal)
+	actual := Sprintf(IdentifierNotFound, "x")
+	if actual != "identifier not found: x" {
+		t.Errorf("missing message did not fall back to English, got %q", actual)
+	}
+}
+
+func TestCatalogsComplete(t *testing.T) {
+	for code := range english {
+		if _, ok := bokmal[code]; !ok {
+			t.Errorf("code %s has no bokmål message", code)
+		}
+	}
+	for code := range bokmal {
+		if _, ok := english[code]; !ok {
+			t.Errorf("code %s has no English message", code)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	defer SetLanguage(CurrentLanguage())
+
+	SetLanguage(Bokmal)
+	if name := TypeName("INTEGER"); name != "heltall" {
+		t.Errorf("TypeName wrong, expected %q, got %q", "heltall", name)
+	}
+	if name := TokenName(token.RPAREN); name != "')'" {
+		t.Errorf("TokenName wrong, expected %q, got %q", "')'", name)
+	}
+
+	SetLanguage(English)
+	if name := TypeName("INTEGER"); name != "INTEGER" {
+		t.Errorf("TypeName wrong, expected %q, got %q", "INTEGER", name)
+	}
+	if name := TokenName(token.RPAREN); name != ")" {
+		t.Errorf("TokenName wrong, expected %q, got %q", ")", name)
+	}
+}
+
+func TestParseLanguage(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Language
+		ok       bool
+	}{
+		{"nb", Bokmal, true},
+		{"Bokmål", Bokmal, true},
+		{"en", English, true},
+		{"engelsk", English, true},
+		{"klingon", "", false},
+	}
+
+	for _, tt := range tests {
+		lang, ok := ParseLanguage(tt.input)
+		if lang != tt.expected || ok != tt.ok {
+			t.Errorf("ParseLanguage(%q) wrong, expected (%q, %t), got (%q, %t)", tt.input, tt.expected, tt.ok, lang, ok)
+		}
+	}
+}
+
+func TestDiagnosticError(t *testing.T) {
+	defer SetLanguage(CurrentLanguage())
+	SetLanguage(Bokmal)
+
+	d := New(ExpectedToken, token.Position{Line: 3, Column: 7}, "')'", "';'")
+	expected := "3:7: forventet ')', men fant ';' [S001]"
+	if d.Error() != expected {
+		t.Errorf("Error() wrong, expected %q, got %q", expected, d.Error())
+	}
+}
@@ -3,34 +3,104 @@
 package evaluator
 
 import (
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/solbero/pytonskript/diagnostic"
 	"github.com/solbero/pytonskript/object"
 )
 
+// LookupBuiltin returns the builtin function with the given bokmål name.
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
+// BuiltinNames lists the bokmål names of the builtin functions in a fixed
+// order, so that compiled programs can refer to them by index. New builtins
+// are added at the end.
+var BuiltinNames = []string{
+	"lengde",
+	"første",
+	"siste",
+	"resten",
+	"tilføy",
+	"skriv",
+	"kutt",
+	"streng",
+	"del",
+	"sett_sammen",
+	"fjern_mellomrom",
+	"store_bokstaver",
+	"små_bokstaver",
+	"finn",
+	"erstatt",
+	"starter_med",
+	"slutter_med",
+	"inneholder",
+	"gjenta",
+	"fyll_venstre",
+	"fyll_høyre",
+	"tilordne",
+	"filtrer",
+	"reduser",
+	"sorter",
+	"reverser",
+	"alle",
+	"noen",
+	"nøkler",
+	"verdier",
+	"par",
+	"har_nøkkel",
+	"fjern",
+	"slå_sammen",
+	"formater",
+	"skrivevalg",
+	"er_ingenting",
+	"type",
+	"heltall",
+	"desimal",
+	"sannhetsverdi",
+	"liste",
+	"er_tall",
+	"er_streng",
+	"er_liste",
+	"er_tabell",
+	"er_funksjon",
+}
+
 var builtins = map[string]*object.Builtin{
 	"lengde": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		MinArgs: 1,
+		MaxArgs: 1,
+		Doc:     "Gir antall elementer i en liste eller antall tegn i en streng.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments, got %d, want 1", len(args))
+				return newError(diagnostic.WrongArgumentCount, len(args), 1)
 			}
 
 			switch arg := args[0].(type) {
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 			default:
-				return newError("argument to 'lengde' not supported, got %s", args[0].Type())
+				return newError(diagnostic.ArgumentNotSupported, "lengde", typeName(args[0]))
 			}
 		},
 	},
 	"første": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		MinArgs: 1,
+		MaxArgs: 1,
+		Doc:     "Gir det første elementet i en liste, eller ingenting hvis listen er tom.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments, got %d, want 1", len(args))
+				return newError(diagnostic.WrongArgumentCount, len(args), 1)
 			}
 
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to 'første' must be ARRAY, got %s", args[0].Type())
+				return newError(diagnostic.ArgumentMustBe, "første", diagnostic.TypeName(object.ARRAY_OBJ), typeName(args[0]))
 			}
 
 			arr := args[0].(*object.Array)
@@ -42,13 +112,16 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"siste": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		MinArgs: 1,
+		MaxArgs: 1,
+		Doc:     "Gir det siste elementet i en liste, eller ingenting hvis listen er tom.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments, got %d, want 1", len(args))
+				return newError(diagnostic.WrongArgumentCount, len(args), 1)
 			}
 
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to 'siste' must be ARRAY, got %s", args[0].Type())
+				return newError(diagnostic.ArgumentMustBe, "siste", diagnostic.TypeName(object.ARRAY_OBJ), typeName(args[0]))
 			}
 
 			arr := args[0].(*object.Array)
@@ -61,13 +134,16 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"resten": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		MinArgs: 1,
+		MaxArgs: 1,
+		Doc:     "Gir en ny liste med alle elementene unntatt det første, eller ingenting hvis listen er tom.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments, got %d, want 1", len(args))
+				return newError(diagnostic.WrongArgumentCount, len(args), 1)
 			}
 
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to 'resten' must be ARRAY, got %s", args[0].Type())
+				return newError(diagnostic.ArgumentMustBe, "resten", diagnostic.TypeName(object.ARRAY_OBJ), typeName(args[0]))
 			}
 
 			arr := args[0].(*object.Array)
@@ -82,13 +158,16 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"tilføy": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		MinArgs: 2,
+		MaxArgs: 2,
+		Doc:     "Gir en ny liste med verdien lagt til på slutten av listen.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) != 2 {
-				return newError("wrong number of arguments, got %d, want 2", len(args))
+				return newError(diagnostic.WrongArgumentCount, len(args), 2)
 			}
 
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to 'tilføy' must be ARRAY, got %s", args[0].Type())
+				return newError(diagnostic.ArgumentMustBe, "tilføy", diagnostic.TypeName(object.ARRAY_OBJ), typeName(args[0]))
 			}
 
 			arr := args[0].(*object.Array)
@@ -102,53 +181,92 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"skriv": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
-			for _, arg := range args {
-				println(arg.Inspect())
+		MinArgs: 0,
+		MaxArgs: -1,
+		Doc:     "Skriver ut hvert argument på en egen linje. Med valg fra skrivevalg til slutt skrives teksten de gir mellom argumentene og etter det siste i stedet.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			separator, end := "\n", "\n"
+			if n := len(args); n > 0 {
+				if options, ok := args[n-1].(*object.PrintOptions); ok {
+					separator, end = options.Separator, options.End
+					args = args[:n-1]
+				}
+			}
+			if len(args) == 0 {
+				return NULL
 			}
 
+			texts := make([]string, len(args))
+			for i, arg := range args {
+				texts[i] = arg.Inspect()
+			}
+			io.WriteString(output, strings.Join(texts, separator)+end)
+
 			return NULL
 		},
 	},
 	"kutt": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		MinArgs: 2,
+		MaxArgs: 3,
+		Doc:     "Gir elementene i en liste eller tegnene i en streng fra og med start og til stopp. Uten stopp går kuttet til slutten. Negative indekser teller fra slutten.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) < 2 {
-				return newError("wrong number of arguments, got %d, want at least 2", len(args))
+				return newError(diagnostic.TooFewArguments, len(args), 2)
 			} else if len(args) > 3 {
-				return newError("wrong number of arguments, got %d, want less than 3", len(args))
-			}
-
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("first argument to 'kutt' must be ARRAY, got %s", args[0].Type())
+				return newError(diagnostic.TooManyArguments, len(args), 3)
 			}
 
 			if args[1].Type() != object.INTEGER_OBJ {
-				return newError("second argument to 'kutt' must be INTEGER, got %s", args[1].Type())
+				return newError(diagnostic.NthArgumentMustBe, 2, "kutt", diagnostic.TypeName(object.INTEGER_OBJ), typeName(args[1]))
 			}
 
 			if len(args) == 3 && args[2].Type() != object.INTEGER_OBJ {
-				return newError("third argument to 'kutt' must be INTEGER, got %s", args[2].Type())
+				return newError(diagnostic.NthArgumentMustBe, 3, "kutt", diagnostic.TypeName(object.INTEGER_OBJ), typeName(args[2]))
+			}
+
+			var length int64
+			switch arg := args[0].(type) {
+			case *object.Array:
+				length = int64(len(arg.Elements))
+			case *object.String:
+				length = int64(utf8.RuneCountInString(arg.Value))
+			default:
+				return newError(diagnostic.ArgumentNotSupported, "kutt", typeName(args[0]))
 			}
 
-			arr := args[0].(*object.Array)
 			start := args[1].(*object.Integer).Value
-			stop := int64(len(arr.Elements))
+			stop := length
 			if len(args) == 3 {
 				stop = args[2].(*object.Integer).Value
 			}
 
-			if start < 0 || stop > int64(len(arr.Elements)) || start > stop {
-				return newError("invalid slice indices: start=%d, stop=%d", start, stop)
+			from, to := start, stop
+			if from < 0 {
+				from += length
+			}
+			if to < 0 {
+				to += length
+			}
+
+			if from < 0 || to > length || from > to {
+				return newError(diagnostic.InvalidSliceIndices, start, stop)
+			}
+
+			if str, ok := args[0].(*object.String); ok {
+				return &object.String{Value: string([]rune(str.Value)[from:to])}
 			}
 
-			newElements := arr.Elements[start:stop]
+			newElements := args[0].(*object.Array).Elements[from:to]
 			return &object.Array{Elements: newElements}
 		},
 	},
 	"streng": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		MinArgs: 1,
+		MaxArgs: 1,
+		Doc:     "Gir verdien skrevet som en streng.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments, got %d, want 1", len(args))
+				return newError(diagnostic.WrongArgumentCount, len(args), 1)
 			}
 
 			return &object.String{Value: args[0].Inspect()}
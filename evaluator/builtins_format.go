@@ -0,0 +1,197 @@
+// evaluator/builtins_format.go
+
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/solbero/pytonskript/diagnostic"
+	"github.com/solbero/pytonskript/object"
+)
+
+func init() {
+	for name, builtin := range formatBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+// output is where skriv writes. Tests replace it to see what was written.
+var output io.Writer = os.Stderr
+
+var formatBuiltins = map[string]*object.Builtin{
+	"formater": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: -1,
+		Doc:     "Setter verdiene inn i malen der den har plassholdere som %d for heltall, %s for hva som helst og %f for tall med desimaler. Bredde og antall desimaler skrives som i %5d, %-10s og %.2f.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newError(diagnostic.TooFewArguments, len(args), 1)
+			}
+			if err := checkArgTypes("formater", args[:1], object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			return format(args[0].(*object.String).Value, args[1:])
+		},
+	},
+	"skrivevalg": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Doc:     "Lager valg for skriv fra en tabell der \"skille\" er teksten mellom verdiene og \"slutt\" teksten etter den siste.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			if err := checkArgCount(args, 1, 1); err != nil {
+				return err
+			}
+			if err := checkArgTypes("skrivevalg", args, object.HASH_OBJ); err != nil {
+				return err
+			}
+
+			return printOptions(args[0].(*object.Hash))
+		},
+	},
+}
+
+// placeholder is a "%..." in a template given to formater. spec is the
+// placeholder as written, and the same as the Go verb it is formatted with.
+type placeholder struct {
+	start, end int
+	spec       string
+	verb       byte
+}
+
+// format implements formater. The number of placeholders is checked before
+// any value, so a template with the wrong number of values always gives the
+// same error.
+func format(template string, values []object.Object) object.Object {
+	placeholders, err := parsePlaceholders(template)
+	if err != nil {
+		return err
+	}
+	if len(placeholders) != len(values) {
+		return newError(diagnostic.PlaceholderCount, len(placeholders), len(values))
+	}
+
+	var out strings.Builder
+	last := 0
+	for i, p := range placeholders {
+		out.WriteString(strings.ReplaceAll(template[last:p.start], "%%", "%"))
+		last = p.end
+
+		value := values[i]
+		switch p.verb {
+		case 's':
+			fmt.Fprintf(&out, p.spec, value.Inspect())
+		case 'd':
+			integer, ok := value.(*object.Integer)
+			if !ok {
+				return newError(diagnostic.NthArgumentMustBe, i+2, "formater", diagnostic.TypeName(string(object.INTEGER_OBJ)), typeName(value))
+			}
+			fmt.Fprintf(&out, p.spec, integer.Value)
+		case 'f':
+			number, ok := object.AsFloat(value)
+			if !ok {
+				return newError(diagnostic.NthArgumentMustBe, i+2, "formater", diagnostic.TypeName(string(object.FLOAT_OBJ)), typeName(value))
+			}
+			fmt.Fprintf(&out, p.spec, number)
+		}
+	}
+	out.WriteString(strings.ReplaceAll(template[last:], "%%", "%"))
+
+	return &object.String{Value: out.String()}
+}
+
+// maxPlaceholderWidth is the largest width and precision of a placeholder,
+// so that a template cannot make a string of any size.
+const maxPlaceholderWidth = 1000
+
+// parsePlaceholders finds the placeholders in template. A placeholder is a
+// '%' followed by any of the flags '-', '+' and '0', a width, a '.' and a
+// precision, and one of the verbs 'd', 's' and 'f'. "%%" is a '%' and not a
+// placeholder.
+func parsePlaceholders(template string) ([]placeholder, *object.Error) {
+	var placeholders []placeholder
+
+	for i := 0; i < len(template); i++ {
+		if template[i] != '%' {
+			continue
+		}
+		if i+1 < len(template) && template[i+1] == '%' {
+			i++
+			continue
+		}
+
+		j := i + 1
+		for j < len(template) && strings.IndexByte("-+0", template[j]) >= 0 {
+			j++
+		}
+		j, width := readNumber(template, j)
+		precision := 0
+		if j < len(template) && template[j] == '.' {
+			j, precision = readNumber(template, j+1)
+		}
+
+		if j == len(template) || strings.IndexByte("dsf", template[j]) < 0 {
+			end := j
+			if end < len(template) {
+				end++
+			}
+			return nil, newError(diagnostic.UnknownPlaceholder, strings.ToValidUTF8(template[i:end], ""))
+		}
+
+		if width > maxPlaceholderWidth || precision > maxPlaceholderWidth {
+			return nil, newError(diagnostic.PlaceholderTooWide, strings.ToValidUTF8(template[i:j+1], ""), maxPlaceholderWidth)
+		}
+
+		placeholders = append(placeholders, placeholder{start: i, end: j + 1, spec: template[i : j+1], verb: template[j]})
+		i = j
+	}
+
+	return placeholders, nil
+}
+
+// readNumber reads the digits in s from i, and returns the index after them
+// and their value. The value stops growing past maxPlaceholderWidth, so that
+// it cannot overflow.
+func readNumber(s string, i int) (int, int) {
+	n := 0
+	for ; i < len(s) && isASCIIDigit(s[i]); i++ {
+		if n <= maxPlaceholderWidth {
+			n = n*10 + int(s[i]-'0')
+		}
+	}
+	return i, n
+}
+
+func isASCIIDigit(ch byte) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+// printOptions makes the options for skrivevalg from a hash with the text
+// written between the values, "skille", and after the last one, "slutt".
+// Options that are not given are a new line, as without options.
+func printOptions(hash *object.Hash) object.Object {
+	options := &object.PrintOptions{Separator: "\n", End: "\n"}
+
+	for _, pair := range hash.Pairs() {
+		key, ok := pair.Key.(*object.String)
+		if !ok || (key.Value != "skille" && key.Value != "slutt") {
+			return newError(diagnostic.UnknownOption, "skrivevalg", pair.Key.Inspect())
+		}
+
+		value, ok := pair.Value.(*object.String)
+		if !ok {
+			return newError(diagnostic.OptionMustBe, key.Value, "skrivevalg", diagnostic.TypeName(string(object.STRING_OBJ)), typeName(pair.Value))
+		}
+
+		if key.Value == "skille" {
+			options.Separator = value.Value
+		} else {
+			options.End = value.Value
+		}
+	}
+
+	return options
+}
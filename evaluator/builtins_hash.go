@@ -0,0 +1,129 @@
+// evaluator/builtins_hash.go
+
+package evaluator
+
+import (
+	"github.com/solbero/pytonskript/diagnostic"
+	"github.com/solbero/pytonskript/object"
+)
+
+func init() {
+	for name, builtin := range hashBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+// hashBuiltins work on hashes. They list pairs in the order the hash keeps
+// them in, and give a new hash instead of changing the one they were given.
+var hashBuiltins = map[string]*object.Builtin{
+	"nøkler": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Doc:     "Gir en liste med nøklene i en tabell.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			return listPairs("nøkler", args, func(pair object.HashPair) object.Object { return pair.Key })
+		},
+	},
+	"verdier": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Doc:     "Gir en liste med verdiene i en tabell.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			return listPairs("verdier", args, func(pair object.HashPair) object.Object { return pair.Value })
+		},
+	},
+	"par": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Doc:     "Gir en liste med parene i en tabell, hvert som en liste med nøkkelen og verdien.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			return listPairs("par", args, func(pair object.HashPair) object.Object {
+				return &object.Array{Elements: []object.Object{pair.Key, pair.Value}}
+			})
+		},
+	},
+	"har_nøkkel": &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Doc:     "Gir sant hvis nøkkelen finnes i tabellen, også når verdien er ingenting.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			if err := checkArgCount(args, 2, 2); err != nil {
+				return err
+			}
+			if err := checkArgTypes("har_nøkkel", args, object.HASH_OBJ, ""); err != nil {
+				return err
+			}
+
+			key, ok := object.HashableKey(args[1])
+			if !ok {
+				return newError(diagnostic.UnusableAsHashKey, typeName(args[1]))
+			}
+			_, ok = args[0].(*object.Hash).Get(key)
+			return nativeBoolToBooleanObject(ok)
+		},
+	},
+	"fjern": &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Doc:     "Gir tabellen uten nøkkelen og verdien dens.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			if err := checkArgCount(args, 2, 2); err != nil {
+				return err
+			}
+			if err := checkArgTypes("fjern", args, object.HASH_OBJ, ""); err != nil {
+				return err
+			}
+
+			if _, ok := object.HashableKey(args[1]); !ok {
+				return newError(diagnostic.UnusableAsHashKey, typeName(args[1]))
+			}
+
+			result := &object.Hash{}
+			for _, pair := range args[0].(*object.Hash).Pairs() {
+				if !object.Equal(pair.Key, args[1]) {
+					result.Set(pair.Key.(object.Hashable), pair.Value)
+				}
+			}
+			return result
+		},
+	},
+	"slå_sammen": &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Doc:     "Gir en tabell med parene i begge tabellene. Der begge har samme nøkkel, brukes verdien fra den andre.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			if err := checkArgCount(args, 2, 2); err != nil {
+				return err
+			}
+			if err := checkArgTypes("slå_sammen", args, object.HASH_OBJ, object.HASH_OBJ); err != nil {
+				return err
+			}
+
+			result := &object.Hash{}
+			for _, arg := range args {
+				for _, pair := range arg.(*object.Hash).Pairs() {
+					result.Set(pair.Key.(object.Hashable), pair.Value)
+				}
+			}
+			return result
+		},
+	},
+}
+
+// listPairs implements the builtins that give a list with an element made
+// by element from each pair in a hash.
+func listPairs(name string, args []object.Object, element func(object.HashPair) object.Object) object.Object {
+	if err := checkArgCount(args, 1, 1); err != nil {
+		return err
+	}
+	if err := checkArgTypes(name, args, object.HASH_OBJ); err != nil {
+		return err
+	}
+
+	pairs := args[0].(*object.Hash).Pairs()
+	elements := make([]object.Object, len(pairs))
+	for i, pair := range pairs {
+		elements[i] = element(pair)
+	}
+	return &object.Array{Elements: elements}
+}
@@ -0,0 +1,301 @@
+// evaluator/builtins_higher_order.go
+
+package evaluator
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/solbero/pytonskript/diagnostic"
+	"github.com/solbero/pytonskript/object"
+)
+
+func init() {
+	for name, builtin := range higherOrderBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+// higherOrderBuiltins go through lists, most of them calling a function
+// for each element. A list they give back is a new list; the one they were
+// given is left as it was.
+var higherOrderBuiltins = map[string]*object.Builtin{
+	"tilordne": &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Doc:     "Gir en liste med verdien funksjonen gir for hvert element i listen.",
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			if err := checkArgCount(args, 2, 2); err != nil {
+				return err
+			}
+			if err := checkArgTypes("tilordne", args, object.ARRAY_OBJ, object.FUNCTION_OBJ); err != nil {
+				return err
+			}
+
+			elements := args[0].(*object.Array).Elements
+			result := make([]object.Object, len(elements))
+			for i, element := range elements {
+				value := caller.Call(args[1], element)
+				if isError(value) {
+					return value
+				}
+				result[i] = value
+			}
+			return &object.Array{Elements: result}
+		},
+	},
+	"filtrer": &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Doc:     "Gir en liste med elementene i listen som funksjonen gir en sann verdi for.",
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			if err := checkArgCount(args, 2, 2); err != nil {
+				return err
+			}
+			if err := checkArgTypes("filtrer", args, object.ARRAY_OBJ, object.FUNCTION_OBJ); err != nil {
+				return err
+			}
+
+			result := []object.Object{}
+			for _, element := range args[0].(*object.Array).Elements {
+				keep := caller.Call(args[1], element)
+				if isError(keep) {
+					return keep
+				}
+				if isTruthy(keep) {
+					result = append(result, element)
+				}
+			}
+			return &object.Array{Elements: result}
+		},
+	},
+	"reduser": &object.Builtin{
+		MinArgs: 3,
+		MaxArgs: 3,
+		Doc:     "Slår sammen elementene i listen til én verdi. Funksjonen får verdien så langt og neste element, og starter med startverdien.",
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			if err := checkArgCount(args, 3, 3); err != nil {
+				return err
+			}
+			if err := checkArgTypes("reduser", args, object.ARRAY_OBJ, "", object.FUNCTION_OBJ); err != nil {
+				return err
+			}
+
+			result := args[1]
+			for _, element := range args[0].(*object.Array).Elements {
+				result = caller.Call(args[2], result, element)
+				if isError(result) {
+					return result
+				}
+			}
+			return result
+		},
+	},
+	"sorter": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 2,
+		Doc:     "Gir listen sortert. Tall sorteres etter størrelse og strenger alfabetisk, med æ, ø og å etter z. Med en funksjon sorteres listen etter den; den får to elementer og gir sant hvis det første skal stå foran det andre.",
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			if err := checkArgCount(args, 1, 2); err != nil {
+				return err
+			}
+			if err := checkArgTypes("sorter", args, object.ARRAY_OBJ, object.FUNCTION_OBJ); err != nil {
+				return err
+			}
+
+			elements := args[0].(*object.Array).Elements
+			sorted := make([]object.Object, len(elements))
+			copy(sorted, elements)
+
+			less := lessThan
+			if len(args) == 2 {
+				less = func(a, b object.Object) (bool, object.Object) {
+					result := caller.Call(args[1], a, b)
+					if isError(result) {
+						return false, result
+					}
+					return isTruthy(result), nil
+				}
+			}
+
+			var err object.Object
+			sort.SliceStable(sorted, func(i, j int) bool {
+				if err != nil {
+					return false
+				}
+				var isLess bool
+				isLess, err = less(sorted[i], sorted[j])
+				return isLess
+			})
+			if err != nil {
+				return err
+			}
+			return &object.Array{Elements: sorted}
+		},
+	},
+	"reverser": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Doc:     "Gir elementene i en liste eller tegnene i en streng i motsatt rekkefølge.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			if err := checkArgCount(args, 1, 1); err != nil {
+				return err
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Array:
+				reversed := make([]object.Object, len(arg.Elements))
+				for i, element := range arg.Elements {
+					reversed[len(reversed)-1-i] = element
+				}
+				return &object.Array{Elements: reversed}
+			case *object.String:
+				runes := []rune(arg.Value)
+				for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+					runes[i], runes[j] = runes[j], runes[i]
+				}
+				return &object.String{Value: string(runes)}
+			default:
+				return newError(diagnostic.ArgumentNotSupported, "reverser", typeName(args[0]))
+			}
+		},
+	},
+	"finn": &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Doc:     "Gir posisjonen til den første forekomsten av en delstreng i en streng, eller -1 hvis den ikke finnes. Med en liste og en funksjon gis det første elementet funksjonen gir en sann verdi for, eller ingenting.",
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			if err := checkArgCount(args, 2, 2); err != nil {
+				return err
+			}
+
+			switch args[0].(type) {
+			case *object.String:
+				if err := checkArgTypes("finn", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+					return err
+				}
+
+				str := args[0].(*object.String).Value
+				i := strings.Index(str, args[1].(*object.String).Value)
+				if i < 0 {
+					return &object.Integer{Value: -1}
+				}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(str[:i]))}
+			case *object.Array:
+				if err := checkArgTypes("finn", args, object.ARRAY_OBJ, object.FUNCTION_OBJ); err != nil {
+					return err
+				}
+
+				for _, element := range args[0].(*object.Array).Elements {
+					found := caller.Call(args[1], element)
+					if isError(found) {
+						return found
+					}
+					if isTruthy(found) {
+						return element
+					}
+				}
+				return NULL
+			default:
+				return newError(diagnostic.ArgumentNotSupported, "finn", typeName(args[0]))
+			}
+		},
+	},
+	"alle": &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Doc:     "Gir sant hvis funksjonen gir en sann verdi for alle elementene i listen.",
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			return anyElement("alle", caller, args, false)
+		},
+	},
+	"noen": &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Doc:     "Gir sant hvis funksjonen gir en sann verdi for minst ett av elementene i listen.",
+		Fn: func(caller object.Caller, args ...object.Object) object.Object {
+			return anyElement("noen", caller, args, true)
+		},
+	},
+}
+
+// anyElement implements noen, which looks for an element the function
+// gives a true value for, and alle, which looks for one it gives a false
+// value for and says the opposite of what it found. It stops at the first
+// element it looks for.
+func anyElement(name string, caller object.Caller, args []object.Object, truthy bool) object.Object {
+	if err := checkArgCount(args, 2, 2); err != nil {
+		return err
+	}
+	if err := checkArgTypes(name, args, object.ARRAY_OBJ, object.FUNCTION_OBJ); err != nil {
+		return err
+	}
+
+	for _, element := range args[0].(*object.Array).Elements {
+		result := caller.Call(args[1], element)
+		if isError(result) {
+			return result
+		}
+		if isTruthy(result) == truthy {
+			return nativeBoolToBooleanObject(truthy)
+		}
+	}
+	return nativeBoolToBooleanObject(!truthy)
+}
+
+// lessThan is the order sorter uses without a function: integers by size
+// and strings alphabetically. Other values cannot be sorted.
+func lessThan(a, b object.Object) (bool, object.Object) {
+	if x, ok := object.AsFloat(a); ok {
+		if y, ok := object.AsFloat(b); ok {
+			return x < y, nil
+		}
+	}
+	if a.Type() != b.Type() {
+		return false, newError(diagnostic.TypeMismatch, typeName(a), "<", typeName(b))
+	}
+
+	switch a := a.(type) {
+	case *object.Integer:
+		return a.Value < b.(*object.Integer).Value, nil
+	case *object.String:
+		return collate(a.Value, b.(*object.String).Value), nil
+	default:
+		return false, newError(diagnostic.UnknownInfixOperator, typeName(a), "<", typeName(b))
+	}
+}
+
+// collate reports whether a comes before b in the Norwegian alphabet, where
+// æ, ø and å come after z, in that order. Other characters are compared by
+// their code points.
+func collate(a, b string) bool {
+	ra, rb := []rune(a), []rune(b)
+	for i := 0; i < len(ra) && i < len(rb); i++ {
+		if ka, kb := collationKey(ra[i]), collationKey(rb[i]); ka != kb {
+			return ka < kb
+		}
+	}
+	return len(ra) < len(rb)
+}
+
+// collationKey places æ, ø and å right after z, and Æ, Ø and Å right after
+// Z, leaving room between all other characters for them.
+func collationKey(r rune) rune {
+	switch r {
+	case 'æ':
+		return 'z'*4 + 1
+	case 'ø':
+		return 'z'*4 + 2
+	case 'å':
+		return 'z'*4 + 3
+	case 'Æ':
+		return 'Z'*4 + 1
+	case 'Ø':
+		return 'Z'*4 + 2
+	case 'Å':
+		return 'Z'*4 + 3
+	default:
+		return r * 4
+	}
+}
@@ -0,0 +1,279 @@
+// evaluator/builtins_string.go
+
+package evaluator
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/solbero/pytonskript/diagnostic"
+	"github.com/solbero/pytonskript/object"
+)
+
+func init() {
+	for name, builtin := range stringBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+// maxRepeatSize is the most bytes gjenta, fyll_venstre and fyll_høyre make,
+// so that a large count gives an error instead of using up the memory.
+const maxRepeatSize = 1 << 26
+
+// stringBuiltins work on strings. Positions and lengths are counted in
+// characters, not bytes, like lengde and kutt.
+var stringBuiltins = map[string]*object.Builtin{
+	"del": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 2,
+		Doc:     "Deler en streng ved hver forekomst av skilletegnet og gir delene i en liste. Uten skilletegn deles strengen ved mellomrom.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			if err := checkArgCount(args, 1, 2); err != nil {
+				return err
+			}
+			if err := checkArgTypes("del", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			str := args[0].(*object.String).Value
+			var parts []string
+			if len(args) == 2 {
+				parts = strings.Split(str, args[1].(*object.String).Value)
+			} else {
+				parts = strings.Fields(str)
+			}
+
+			elements := make([]object.Object, len(parts))
+			for i, part := range parts {
+				elements[i] = &object.String{Value: part}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"sett_sammen": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 2,
+		Doc:     "Setter sammen elementene i en liste til en streng, med skilletegnet mellom hvert element.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			if err := checkArgCount(args, 1, 2); err != nil {
+				return err
+			}
+			if err := checkArgTypes("sett_sammen", args, object.ARRAY_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			separator := ""
+			if len(args) == 2 {
+				separator = args[1].(*object.String).Value
+			}
+
+			elements := args[0].(*object.Array).Elements
+			parts := make([]string, len(elements))
+			for i, element := range elements {
+				parts[i] = element.Inspect()
+			}
+			return &object.String{Value: strings.Join(parts, separator)}
+		},
+	},
+	"fjern_mellomrom": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Doc:     "Gir strengen uten mellomrom og linjeskift i starten og på slutten.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			return mapString("fjern_mellomrom", args, strings.TrimSpace)
+		},
+	},
+	"store_bokstaver": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Doc:     "Gir strengen med store bokstaver, også æ, ø og å.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			return mapString("store_bokstaver", args, strings.ToUpper)
+		},
+	},
+	"små_bokstaver": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Doc:     "Gir strengen med små bokstaver, også Æ, Ø og Å.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			return mapString("små_bokstaver", args, strings.ToLower)
+		},
+	},
+	"erstatt": &object.Builtin{
+		MinArgs: 3,
+		MaxArgs: 3,
+		Doc:     "Gir strengen med hver forekomst av den gamle delstrengen byttet ut med den nye.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			if err := checkArgCount(args, 3, 3); err != nil {
+				return err
+			}
+			if err := checkArgTypes("erstatt", args, object.STRING_OBJ, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+
+			str := args[0].(*object.String).Value
+			old := args[1].(*object.String).Value
+			replacement := args[2].(*object.String).Value
+			return &object.String{Value: strings.ReplaceAll(str, old, replacement)}
+		},
+	},
+	"starter_med": &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Doc:     "Gir sant hvis strengen starter med delstrengen.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			return compareStrings("starter_med", args, strings.HasPrefix)
+		},
+	},
+	"slutter_med": &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Doc:     "Gir sant hvis strengen slutter med delstrengen.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			return compareStrings("slutter_med", args, strings.HasSuffix)
+		},
+	},
+	"inneholder": &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Doc:     "Gir sant hvis delstrengen finnes i strengen.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			return compareStrings("inneholder", args, strings.Contains)
+		},
+	},
+	"gjenta": &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 2,
+		Doc:     "Gir strengen gjentatt så mange ganger som antallet sier.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			if err := checkArgCount(args, 2, 2); err != nil {
+				return err
+			}
+			if err := checkArgTypes("gjenta", args, object.STRING_OBJ, object.INTEGER_OBJ); err != nil {
+				return err
+			}
+
+			count := args[1].(*object.Integer).Value
+			if count < 0 {
+				return newError(diagnostic.NegativeCount, "gjenta", count)
+			}
+			str, err := repeat("gjenta", args[0].(*object.String).Value, count)
+			if err != nil {
+				return err
+			}
+			return &object.String{Value: str}
+		},
+	},
+	"fyll_venstre": &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 3,
+		Doc:     "Fyller på strengen fra venstre med mellomrom, eller med tegnet som er gitt, til den er så lang som bredden.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			return pad("fyll_venstre", args, func(str, padding string) string { return padding + str })
+		},
+	},
+	"fyll_høyre": &object.Builtin{
+		MinArgs: 2,
+		MaxArgs: 3,
+		Doc:     "Fyller på strengen fra høyre med mellomrom, eller med tegnet som er gitt, til den er så lang som bredden.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			return pad("fyll_høyre", args, func(str, padding string) string { return str + padding })
+		},
+	},
+}
+
+// checkArgCount checks that there are from min to max arguments.
+func checkArgCount(args []object.Object, min, max int) *object.Error {
+	switch {
+	case min == max && len(args) != min:
+		return newError(diagnostic.WrongArgumentCount, len(args), min)
+	case len(args) < min:
+		return newError(diagnostic.TooFewArguments, len(args), min)
+	case len(args) > max:
+		return newError(diagnostic.TooManyArguments, len(args), max)
+	default:
+		return nil
+	}
+}
+
+// checkArgTypes checks that each argument to the builtin name has the type
+// at the same position in types. Arguments that were left out are not
+// checked, and neither are those whose type is "". A builtin function is
+// taken to be a FUNCTION_OBJ.
+func checkArgTypes(name string, args []object.Object, types ...object.ObjectType) *object.Error {
+	for i, arg := range args {
+		if types[i] == "" || arg.Type() == types[i] || types[i] == object.FUNCTION_OBJ && arg.Type() == object.BUILTIN_OBJ {
+			continue
+		}
+		if len(types) == 1 {
+			return newError(diagnostic.ArgumentMustBe, name, diagnostic.TypeName(string(types[i])), typeName(arg))
+		}
+		return newError(diagnostic.NthArgumentMustBe, i+1, name, diagnostic.TypeName(string(types[i])), typeName(arg))
+	}
+	return nil
+}
+
+// mapString implements the builtins that give a new string made from a
+// single string argument.
+func mapString(name string, args []object.Object, f func(string) string) object.Object {
+	if err := checkArgCount(args, 1, 1); err != nil {
+		return err
+	}
+	if err := checkArgTypes(name, args, object.STRING_OBJ); err != nil {
+		return err
+	}
+
+	return &object.String{Value: f(args[0].(*object.String).Value)}
+}
+
+// compareStrings implements the builtins that ask a question about two
+// strings.
+func compareStrings(name string, args []object.Object, f func(string, string) bool) object.Object {
+	if err := checkArgCount(args, 2, 2); err != nil {
+		return err
+	}
+	if err := checkArgTypes(name, args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+		return err
+	}
+
+	return nativeBoolToBooleanObject(f(args[0].(*object.String).Value, args[1].(*object.String).Value))
+}
+
+// pad implements fyll_venstre and fyll_høyre. join puts the padding on the
+// side of the string the builtin fills.
+func pad(name string, args []object.Object, join func(str, padding string) string) object.Object {
+	if err := checkArgCount(args, 2, 3); err != nil {
+		return err
+	}
+	if err := checkArgTypes(name, args, object.STRING_OBJ, object.INTEGER_OBJ, object.STRING_OBJ); err != nil {
+		return err
+	}
+
+	fill := " "
+	if len(args) == 3 {
+		fill = args[2].(*object.String).Value
+		if utf8.RuneCountInString(fill) != 1 {
+			return newError(diagnostic.NotOneCharacter, 3, name, fill)
+		}
+	}
+
+	str := args[0].(*object.String).Value
+	missing := args[1].(*object.Integer).Value - int64(utf8.RuneCountInString(str))
+	if missing <= 0 {
+		return args[0]
+	}
+	padding, err := repeat(name, fill, missing)
+	if err != nil {
+		return err
+	}
+	return &object.String{Value: join(str, padding)}
+}
+
+// repeat gives s repeated count times, or an error for name if the result
+// would be longer than maxRepeatSize. count must not be negative.
+func repeat(name, s string, count int64) (string, *object.Error) {
+	if len(s) > 0 && count > maxRepeatSize/int64(len(s)) {
+		return "", newError(diagnostic.StringTooLong, name, maxRepeatSize)
+	}
+	return strings.Repeat(s, int(count)), nil
+}
@@ -0,0 +1,218 @@
+// evaluator/builtins_type.go
+
+package evaluator
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/solbero/pytonskript/diagnostic"
+	"github.com/solbero/pytonskript/object"
+)
+
+func init() {
+	for name, builtin := range typeBuiltins {
+		builtins[name] = builtin
+	}
+}
+
+// typeBuiltins ask what kind of value they are given, or turn it into a
+// value of another kind. Types are named as object.ObjectType.Name names
+// them, in bokmål whatever dialect the program is written in.
+var typeBuiltins = map[string]*object.Builtin{
+	"er_ingenting": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Doc:     "Gir sant hvis verdien er ingenting.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			return hasType(args, object.NULL_OBJ)
+		},
+	},
+	"type": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Doc:     "Gir navnet på typen til verdien, for eksempel \"heltall\", \"streng\" eller \"liste\".",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			if err := checkArgCount(args, 1, 1); err != nil {
+				return err
+			}
+			return &object.String{Value: args[0].Type().Name()}
+		},
+	},
+	"heltall": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Doc:     "Gjør en streng, et desimaltall eller en sannhetsverdi om til et heltall. Desimaler blir kuttet bort.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			if err := checkArgCount(args, 1, 1); err != nil {
+				return err
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer:
+				return arg
+			case *object.Float:
+				if math.IsNaN(arg.Value) || math.Abs(arg.Value) >= 1<<63 {
+					return newError(diagnostic.CannotConvert, arg.Inspect(), diagnostic.TypeName(object.INTEGER_OBJ))
+				}
+				return &object.Integer{Value: int64(arg.Value)}
+			case *object.String:
+				value, err := strconv.ParseInt(strings.TrimSpace(arg.Value), 10, 64)
+				if err != nil {
+					return newError(diagnostic.CannotConvert, arg.Value, diagnostic.TypeName(object.INTEGER_OBJ))
+				}
+				return &object.Integer{Value: value}
+			case *object.Boolean:
+				if arg.Value {
+					return &object.Integer{Value: 1}
+				}
+				return &object.Integer{Value: 0}
+			default:
+				return newError(diagnostic.ArgumentNotSupported, "heltall", typeName(arg))
+			}
+		},
+	},
+	"desimal": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Doc:     "Gjør en streng, et heltall eller en sannhetsverdi om til et desimaltall. Strenger kan ha punktum eller komma foran desimalene.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			if err := checkArgCount(args, 1, 1); err != nil {
+				return err
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Float:
+				return arg
+			case *object.Integer:
+				return &object.Float{Value: float64(arg.Value)}
+			case *object.String:
+				value, err := parseDecimal(arg.Value)
+				if err != nil {
+					return newError(diagnostic.CannotConvert, arg.Value, diagnostic.TypeName(object.FLOAT_OBJ))
+				}
+				return &object.Float{Value: value}
+			case *object.Boolean:
+				if arg.Value {
+					return &object.Float{Value: 1}
+				}
+				return &object.Float{Value: 0}
+			default:
+				return newError(diagnostic.ArgumentNotSupported, "desimal", typeName(arg))
+			}
+		},
+	},
+	"sannhetsverdi": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Doc:     "Gir sant eller falskt, slik hvis ville tolket verdien. Bare falskt og ingenting er usanne.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			if err := checkArgCount(args, 1, 1); err != nil {
+				return err
+			}
+			return nativeBoolToBooleanObject(isTruthy(args[0]))
+		},
+	},
+	"liste": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Doc:     "Gir tegnene i en streng eller nøklene i en tabell som en liste. En liste blir kopiert.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			if err := checkArgCount(args, 1, 1); err != nil {
+				return err
+			}
+
+			var elements []object.Object
+			switch arg := args[0].(type) {
+			case *object.Array:
+				elements = make([]object.Object, len(arg.Elements))
+				copy(elements, arg.Elements)
+			case *object.String:
+				for _, ch := range arg.Value {
+					elements = append(elements, &object.String{Value: string(ch)})
+				}
+			case *object.Hash:
+				for _, pair := range arg.Pairs() {
+					elements = append(elements, pair.Key)
+				}
+			default:
+				return newError(diagnostic.ArgumentNotSupported, "liste", typeName(arg))
+			}
+			if elements == nil {
+				elements = []object.Object{}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	"er_tall": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Doc:     "Gir sant hvis verdien er et heltall eller et desimaltall.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			return hasType(args, object.INTEGER_OBJ, object.FLOAT_OBJ)
+		},
+	},
+	"er_streng": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Doc:     "Gir sant hvis verdien er en streng.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			return hasType(args, object.STRING_OBJ)
+		},
+	},
+	"er_liste": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Doc:     "Gir sant hvis verdien er en liste.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			return hasType(args, object.ARRAY_OBJ)
+		},
+	},
+	"er_tabell": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Doc:     "Gir sant hvis verdien er en tabell.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			return hasType(args, object.HASH_OBJ)
+		},
+	},
+	"er_funksjon": &object.Builtin{
+		MinArgs: 1,
+		MaxArgs: 1,
+		Doc:     "Gir sant hvis verdien kan kalles, enten den er en funksjon eller en innebygd funksjon.",
+		Fn: func(_ object.Caller, args ...object.Object) object.Object {
+			return hasType(args, object.FUNCTION_OBJ, object.BUILTIN_OBJ)
+		},
+	},
+}
+
+// hasType implements the builtins that ask whether their argument has one
+// of types.
+func hasType(args []object.Object, types ...object.ObjectType) object.Object {
+	if err := checkArgCount(args, 1, 1); err != nil {
+		return err
+	}
+
+	for _, t := range types {
+		if args[0].Type() == t {
+			return TRUE
+		}
+	}
+	return FALSE
+}
+
+// parseDecimal parses a decimal number written with either a point or a
+// comma before the decimals, as in "3.5" and "3,5". Only digits, signs, the
+// point and an exponent are allowed, so that "NaN", "Inf" and hexadecimal
+// numbers such as "0x1p3", which strconv.ParseFloat also reads, are errors.
+func parseDecimal(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, ".") {
+		s = strings.Replace(s, ",", ".", 1)
+	}
+	if strings.TrimLeft(s, "0123456789+-.eE") != "" {
+		return 0, strconv.ErrSyntax
+	}
+	return strconv.ParseFloat(s, 64)
+}
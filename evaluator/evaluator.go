@@ -3,10 +3,12 @@
 package evaluator
 
 import (
-	"fmt"
+	"strings"
 
 	"github.com/solbero/pytonskript/ast"
+	"github.com/solbero/pytonskript/diagnostic"
 	"github.com/solbero/pytonskript/object"
+	"github.com/solbero/pytonskript/token"
 )
 
 var (
@@ -45,6 +47,8 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	// Literals
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 	case *ast.ArrayLiteral:
@@ -57,6 +61,8 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalHashLiteral(node, env)
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
+	case *ast.NullLiteral:
+		return NULL
 
 	// Expressions
 	case *ast.PrefixExpression:
@@ -70,10 +76,19 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(left) {
 			return left
 		}
+		if node.Token.Type == token.NULLISH {
+			if left != NULL {
+				return left
+			}
+			return Eval(node.Right, env)
+		}
 		right := Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
+		if node.Token.Type == token.IN {
+			return evalInExpression(left, right)
+		}
 		return evalInfixExpression(node.Operator, left, right)
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
@@ -82,6 +97,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(left) {
 			return left
 		}
+		if left == NULL && node.Token.Type == token.OPTIONAL_LBRACKET {
+			return NULL
+		}
 		index := Eval(node.Index, env)
 		if isError(index) {
 			return index
@@ -93,7 +111,12 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		params := node.Parameters
 		body := node.Body
 		return &object.Function{Parameters: params, Body: body, Env: env}
+	case *ast.MacroLiteral:
+		return &object.Macro{Parameters: node.Parameters, Body: node.Body, Env: env}
 	case *ast.CallExpression:
+		if isSpecialCall(node, quoteName, env) {
+			return quote(node, env)
+		}
 		function := Eval(node.Function, env)
 		if isError(function) {
 			return function
@@ -102,20 +125,29 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		return callFunction(node, function, args, env)
 	}
 
 	return nil
 }
 
-func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+func newError(code diagnostic.Code, a ...interface{}) *object.Error {
+	return &object.Error{Code: code, Message: diagnostic.Sprintf(code, a...)}
+}
+
+func typeName(obj object.Object) string {
+	return diagnostic.TypeName(string(obj.Type()))
 }
 
 func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
+	hook := env.Hook()
+
 	for _, stmt := range stmts {
+		if hook != nil {
+			hook.Statement(stmt, env)
+		}
 		result = Eval(stmt, env)
 
 		switch result := result.(type) {
@@ -132,7 +164,12 @@ func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
+	hook := env.Hook()
+
 	for _, stmt := range block.Statements {
+		if hook != nil {
+			hook.Statement(stmt, env)
+		}
 		result = Eval(stmt, env)
 
 		if result != nil {
@@ -151,37 +188,38 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
-		return builtin
+	if name, ok := env.Dialect().Builtin(node.Value); ok {
+		if builtin, ok := builtins[name]; ok {
+			return builtin
+		}
 	}
 
-	return newError("identifier not found: %s", node.Value)
+	return newError(diagnostic.IdentifierNotFound, node.Value)
 }
 
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := &object.Hash{}
 
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
 		if isError(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
+		hashKey, ok := object.HashableKey(key)
 		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+			return newError(diagnostic.UnusableAsHashKey, typeName(key))
 		}
 
-		value := Eval(valueNode, env)
+		value := Eval(pair.Value, env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, value)
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
@@ -205,7 +243,7 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
 	default:
-		return newError("unknown operator: %s%s", operator, right.Type())
+		return newError(diagnostic.UnknownPrefixOperator, operator, typeName(right))
 	}
 }
 
@@ -223,29 +261,61 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newError(diagnostic.UnknownPrefixOperator, "-", typeName(right))
 	}
-
-	value := right.(*object.Integer).Value
-
-	return &object.Integer{Value: -value}
 }
 
 func evalInfixExpression(operator string, left, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(object.Equal(left, right))
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!object.Equal(left, right))
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return newError(diagnostic.TypeMismatch, typeName(left), operator, typeName(right))
+	default:
+		return newError(diagnostic.UnknownInfixOperator, typeName(left), operator, typeName(right))
+	}
+}
+
+// evalInExpression evaluates element i collection: whether element is a key
+// in a hash, an element of a list or a part of a string.
+func evalInExpression(element, collection object.Object) object.Object {
+	switch collection := collection.(type) {
+	case *object.Hash:
+		key, ok := object.HashableKey(element)
+		if !ok {
+			return newError(diagnostic.UnusableAsHashKey, typeName(element))
+		}
+		_, ok = collection.Get(key)
+		return nativeBoolToBooleanObject(ok)
+	case *object.Array:
+		for _, e := range collection.Elements {
+			if object.Equal(e, element) {
+				return TRUE
+			}
+		}
+		return FALSE
+	case *object.String:
+		str, ok := element.(*object.String)
+		if !ok {
+			return newError(diagnostic.TypeMismatch, typeName(element), "i", typeName(collection))
+		}
+		return nativeBoolToBooleanObject(strings.Contains(collection.Value, str.Value))
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(diagnostic.UnknownInfixOperator, typeName(element), "i", typeName(collection))
 	}
 }
 
@@ -261,6 +331,9 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError(diagnostic.DivisionByZero)
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
@@ -271,10 +344,46 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	case "!=":
 		return nativeBoolToBooleanObject(leftVal != rightVal)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(diagnostic.UnknownInfixOperator, typeName(left), operator, typeName(right))
+	}
+}
+
+// evalFloatInfixExpression evaluates operators on two numbers where at least
+// one is a float. The other is turned into a float first.
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal, _ := object.AsFloat(left)
+	rightVal, _ := object.AsFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		if rightVal == 0 {
+			return newError(diagnostic.DivisionByZero)
+		}
+		return &object.Float{Value: leftVal / rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError(diagnostic.UnknownInfixOperator, typeName(left), operator, typeName(right))
 	}
 }
 
+func isNumber(obj object.Object) bool {
+	_, ok := object.AsFloat(obj)
+	return ok
+}
+
 func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
@@ -287,7 +396,7 @@ func evalStringInfixExpression(operator string, left, right object.Object) objec
 	case "!=":
 		return nativeBoolToBooleanObject(leftVal != rightVal)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(diagnostic.UnknownInfixOperator, typeName(left), operator, typeName(right))
 	}
 }
 
@@ -298,6 +407,20 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	return FALSE
 }
 
+func evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out strings.Builder
+
+	for _, part := range node.Parts {
+		value := Eval(part, env)
+		if isError(value) {
+			return value
+		}
+		out.WriteString(value.Inspect())
+	}
+
+	return &object.String{Value: out.String()}
+}
+
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env)
 
@@ -318,53 +441,118 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	default:
-		return newError("index operator not supported: %s", left.Type())
+		return newError(diagnostic.IndexNotSupported, typeName(left))
 	}
 }
 
+// evalArrayIndexExpression gives the element at index. A negative index
+// counts from the end, so -1 is the last element.
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
 	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
+	if idx < 0 {
+		idx += int64(len(arrayObject.Elements))
+	}
 
-	if idx < 0 || idx > max {
+	if idx < 0 || idx >= int64(len(arrayObject.Elements)) {
 		return NULL
 	}
 
 	return arrayObject.Elements[idx]
 }
 
+// evalStringIndexExpression gives the character at index as a string. A
+// negative index counts from the end, so -1 is the last character.
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	chars := []rune(str.(*object.String).Value)
+	idx := index.(*object.Integer).Value
+	if idx < 0 {
+		idx += int64(len(chars))
+	}
+
+	if idx < 0 || idx >= int64(len(chars)) {
+		return NULL
+	}
+
+	return &object.String{Value: string(chars[idx])}
+}
+
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 
-	key, ok := index.(object.Hashable)
+	key, ok := object.HashableKey(index)
 
 	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+		return newError(diagnostic.UnusableAsHashKey, typeName(index))
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	value, ok := hashObject.Get(key)
 
 	if !ok {
 		return NULL
 	}
 
-	return pair.Value
+	return value
+}
+
+// caller calls functions for a builtin. The hook is told about the calls as
+// calls from call, the call to the builtin.
+type caller struct {
+	env  *object.Environment
+	call *ast.CallExpression
+	args []object.Object
+}
+
+// Call calls fn with args. If fn is one of the arguments to the builtin, the
+// call is made by the expression that argument was given as, so a debugger
+// can show the name of the function.
+func (c *caller) Call(fn object.Object, args ...object.Object) object.Object {
+	node := &ast.CallExpression{Token: c.call.Token}
+	for i, arg := range c.args {
+		if arg == fn && i < len(c.call.Arguments) {
+			node.Function = c.call.Arguments[i]
+			break
+		}
+	}
+	if f, ok := fn.(*object.Function); ok && node.Function == nil {
+		node.Function = &ast.FunctionLiteral{Token: c.call.Token, Parameters: f.Parameters, Body: f.Body}
+	}
+
+	return callFunction(node, fn, args, c.env)
+}
+
+// callFunction calls function from node, telling the hook about calls to
+// functions in the program.
+func callFunction(node *ast.CallExpression, function object.Object, args []object.Object, env *object.Environment) object.Object {
+	if fn, ok := function.(*object.Function); ok {
+		if hook := env.Hook(); hook != nil {
+			hook.Call(node, fn)
+			result := applyFunction(node, function, args, env)
+			hook.Return(node, result)
+			return result
+		}
+	}
+	return applyFunction(node, function, args, env)
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+func applyFunction(node *ast.CallExpression, fn object.Object, args []object.Object, env *object.Environment) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
+		if len(args) != len(fn.Parameters) {
+			return newError(diagnostic.WrongArgumentCount, len(args), len(fn.Parameters))
+		}
 		extendedEnv := extendFunctionEnv(fn, args)
 		evaluated := Eval(fn.Body, extendedEnv)
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
-		return fn.Fn(args...)
+		return fn.Fn(&caller{env: env, call: node, args: args}, args...)
 	default:
-		return newError("not a function: %s", fn.Type())
+		return newError(diagnostic.NotAFunction, typeName(fn))
 	}
 }
 
@@ -3,11 +3,18 @@
 package evaluator
 
 import (
+	"bytes"
+	"os"
+	"strings"
 	"testing"
 
+	"github.com/solbero/pytonskript/ast"
+	"github.com/solbero/pytonskript/diagnostic"
 	"github.com/solbero/pytonskript/lexer"
 	"github.com/solbero/pytonskript/object"
+	"github.com/solbero/pytonskript/optimizer"
 	"github.com/solbero/pytonskript/parser"
+	"github.com/solbero/pytonskript/token"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -33,7 +40,7 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		checkIntegerObject(t, evaluated, tt.expected)
 	}
 }
@@ -53,7 +60,7 @@ func TestIfElseExpression(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		integer, ok := tt.expected.(int)
 		if ok {
 			checkIntegerObject(t, evaluated, int64(integer))
@@ -94,7 +101,7 @@ func TestEvalBooleanExpression(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		checkBooleanObject(t, evaluated, tt.expected)
 	}
 }
@@ -113,7 +120,7 @@ func TestBangOperator(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		checkBooleanObject(t, evaluated, tt.expected)
 	}
 }
@@ -133,7 +140,7 @@ func TestReturnStatements(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		checkIntegerObject(t, evaluated, tt.expected)
 	}
 }
@@ -150,13 +157,13 @@ func TestLetStatements(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		checkIntegerObject(t, testEval(tt.input), tt.expected)
+		checkIntegerObject(t, testEval(t, tt.input), tt.expected)
 	}
 }
 
 func TestFunctionObject(t *testing.T) {
 	input := "funksjon(x) { x + 2; };"
-	evaluated := testEval(input)
+	evaluated := testEval(t, input)
 
 	fn, ok := evaluated.(*object.Function)
 	if !ok {
@@ -191,7 +198,7 @@ func TestFunctionApplication(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		checkIntegerObject(t, testEval(tt.input), tt.expected)
+		checkIntegerObject(t, testEval(t, tt.input), tt.expected)
 	}
 }
 
@@ -204,7 +211,7 @@ func TestClosures(t *testing.T) {
 	la addTwo = newAdder(2);
 	addTwo(2);
 	`
-	checkIntegerObject(t, testEval(input), 4)
+	checkIntegerObject(t, testEval(t, input), 4)
 }
 
 func TestStringLiteral(t *testing.T) {
@@ -217,12 +224,12 @@ func TestStringLiteral(t *testing.T) {
 		{input: `"hello\nworld"`, expected: "hello\nworld"},
 		{input: `"hello\t\t\tworld"`, expected: "hello\t\t\tworld"},
 		{input: `"hello\\world"`, expected: "hello\\world"},
-		{input: `"hello\bworld"`, expected: "helloworld"},
+		{input: `"bl\u{e5}b\u{E6}r"`, expected: "blåbær"},
 		{input: `"Hello" + " " + "World!"`, expected: "Hello World!"},
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 
 		str, ok := evaluated.(*object.String)
 		if !ok {
@@ -243,42 +250,51 @@ func TestBuiltinFunctions(t *testing.T) {
 		{input: `lengde("")`, expected: 0},
 		{input: `lengde("four")`, expected: 4},
 		{input: `lengde("hello world")`, expected: 11},
-		{input: `lengde(1)`, expected: "argument to 'lengde' not supported, got INTEGER"},
-		{input: `lengde("one", "two")`, expected: "wrong number of arguments, got 2, want 1"},
+		{input: `lengde("blåbær")`, expected: 6},
+		{input: `lengde("æøå")`, expected: 3},
+		{input: `lengde(1)`, expected: "argumentet til 'lengde' støttes ikke, fikk heltall"},
+		{input: `lengde("one", "two")`, expected: "feil antall argumenter, fikk 2, forventet 1"},
 		{input: `første([1, 2, 3])`, expected: 1},
 		{input: `første([])`, expected: nil},
-		{input: `første(1)`, expected: "argument to 'første' must be ARRAY, got INTEGER"},
-		{input: `første([1, 2], [3, 4])`, expected: "wrong number of arguments, got 2, want 1"},
+		{input: `første(1)`, expected: "argumentet til 'første' må være liste, fikk heltall"},
+		{input: `første([1, 2], [3, 4])`, expected: "feil antall argumenter, fikk 2, forventet 1"},
 		{input: `siste([1, 2, 3])`, expected: 3},
 		{input: `siste([])`, expected: nil},
-		{input: `siste(1)`, expected: "argument to 'siste' must be ARRAY, got INTEGER"},
-		{input: `siste([1, 2], [3, 4])`, expected: "wrong number of arguments, got 2, want 1"},
+		{input: `siste(1)`, expected: "argumentet til 'siste' må være liste, fikk heltall"},
+		{input: `siste([1, 2], [3, 4])`, expected: "feil antall argumenter, fikk 2, forventet 1"},
 		{input: `resten([1, 2, 3])`, expected: []int64{2, 3}},
 		{input: `resten([])`, expected: nil},
-		{input: `resten(1)`, expected: "argument to 'resten' must be ARRAY, got INTEGER"},
-		{input: `resten([1, 2], [3, 4])`, expected: "wrong number of arguments, got 2, want 1"},
+		{input: `resten(1)`, expected: "argumentet til 'resten' må være liste, fikk heltall"},
+		{input: `resten([1, 2], [3, 4])`, expected: "feil antall argumenter, fikk 2, forventet 1"},
 		{input: `tilføy([], 1)`, expected: []int64{1}},
-		{input: `tilføy(1, 1)`, expected: "argument to 'tilføy' must be ARRAY, got INTEGER"},
-		{input: `tilføy([1, 2], 1, 2)`, expected: "wrong number of arguments, got 3, want 2"},
+		{input: `tilføy(1, 1)`, expected: "argumentet til 'tilføy' må være liste, fikk heltall"},
+		{input: `tilføy([1, 2], 1, 2)`, expected: "feil antall argumenter, fikk 3, forventet 2"},
 		{input: `tilføy([1], 2)`, expected: []int64{1, 2}},
 		{input: `kutt([1], 0)`, expected: []int64{1}},
 		{input: `kutt([1, 2], 1)`, expected: []int64{2}},
 		{input: `kutt([1, 2, 3], 1, 2)`, expected: []int64{2}},
 		{input: `kutt([1], 1)`, expected: []int64{}},
-		{input: `kutt([1], 2)`, expected: "invalid slice indices: start=2, stop=1"},
-		{input: `kutt([1], -1)`, expected: "invalid slice indices: start=-1, stop=1"},
-		{input: `kutt([1], 0, 2)`, expected: "invalid slice indices: start=0, stop=2"},
+		{input: `kutt([1], 2)`, expected: "ugyldige indekser for kutt: start=2, stopp=1"},
+		{input: `kutt([1], -1)`, expected: []int64{1}},
+		{input: `kutt([1, 2, 3], 0, -1)`, expected: []int64{1, 2}},
+		{input: `kutt([1], -2)`, expected: "ugyldige indekser for kutt: start=-2, stopp=1"},
+		{input: `kutt([1], 0, 2)`, expected: "ugyldige indekser for kutt: start=0, stopp=2"},
+		{input: `kutt("blåbær", 3)`, expected: "bær"},
+		{input: `kutt("blåbærsyltetøy", 3, 6)`, expected: "bær"},
+		{input: `kutt("blåbær", -2)`, expected: "ær"},
+		{input: `kutt("blåbær", 0, 7)`, expected: "ugyldige indekser for kutt: start=0, stopp=7"},
+		{input: `kutt(1, 0)`, expected: "argumentet til 'kutt' støttes ikke, fikk heltall"},
 		{input: `streng("hello")`, expected: "hello"},
 		{input: `streng(2 + 2)`, expected: "4"},
 		{input: `streng([1, 2, 3])`, expected: "[1, 2, 3]"},
 		{input: `streng(sant)`, expected: "sant"},
 		{input: `streng(funksjon(x) { x * 2 }(4))`, expected: "8"},
-		{input: `streng()`, expected: "wrong number of arguments, got 0, want 1"},
-		{input: `streng(2, 2)`, expected: "wrong number of arguments, got 2, want 1"},
+		{input: `streng()`, expected: "feil antall argumenter, fikk 0, forventet 1"},
+		{input: `streng(2, 2)`, expected: "feil antall argumenter, fikk 2, forventet 1"},
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 
 		switch expected := tt.expected.(type) {
 		case int:
@@ -318,10 +334,484 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`del("a,b,,c", ",")`, "[a, b, , c]"},
+		{`del("  blå  bær ")`, "[blå, bær]"},
+		{`del("æøå", "")`, "[æ, ø, å]"},
+		{`del(1, ",")`, "FEIL: argument 1 til 'del' må være streng, fikk heltall [K013]"},
+		{`del("a", 1)`, "FEIL: argument 2 til 'del' må være streng, fikk heltall [K013]"},
+		{`del()`, "FEIL: feil antall argumenter, fikk 0, forventet minst 1 [K009]"},
+		{`sett_sammen(["a", "b", "c"], ", ")`, "a, b, c"},
+		{`sett_sammen(["a", 1, sant])`, "a1sant"},
+		{`sett_sammen([], "-")`, ""},
+		{`sett_sammen("abc", "-")`, "FEIL: argument 1 til 'sett_sammen' må være liste, fikk streng [K013]"},
+		{`sett_sammen(del("a b c"), "+")`, "a+b+c"},
+		{"fjern_mellomrom(\"  hei \")", "hei"},
+		{`fjern_mellomrom(1)`, "FEIL: argumentet til 'fjern_mellomrom' må være streng, fikk heltall [K012]"},
+		{`store_bokstaver("blåbærsyltetøy")`, "BLÅBÆRSYLTETØY"},
+		{`små_bokstaver("ÆRLIG ØL PÅ Å")`, "ærlig øl på å"},
+		{`store_bokstaver("a", "b")`, "FEIL: feil antall argumenter, fikk 2, forventet 1 [K008]"},
+		{`finn("blåbær", "bær")`, "3"},
+		{`finn("blåbær", "b")`, "0"},
+		{`finn("blåbær", "x")`, "-1"},
+		{`finn("blåbær", 1)`, "FEIL: argument 2 til 'finn' må være streng, fikk heltall [K013]"},
+		{`erstatt("blå bær og blå himmel", "blå", "rød")`, "rød bær og rød himmel"},
+		{`erstatt("abc", "b")`, "FEIL: feil antall argumenter, fikk 2, forventet 3 [K008]"},
+		{`starter_med("blåbær", "blå")`, "sant"},
+		{`starter_med("blåbær", "bær")`, "falskt"},
+		{`slutter_med("blåbær", "bær")`, "sant"},
+		{`slutter_med("blåbær", "blå")`, "falskt"},
+		{`inneholder("blåbær", "åb")`, "sant"},
+		{`inneholder("blåbær", "x")`, "falskt"},
+		{`inneholder(["a"], "a")`, "FEIL: argument 1 til 'inneholder' må være streng, fikk liste [K013]"},
+		{`gjenta("ha", 3)`, "hahaha"},
+		{`gjenta("ha", 0)`, ""},
+		{`gjenta("ha", -1)`, "FEIL: antallet til 'gjenta' kan ikke være negativt, fikk -1 [K017]"},
+		{`gjenta("ha", 9223372036854775807)`, "FEIL: strengen fra 'gjenta' blir for lang, grensen er 67108864 byte [K026]"},
+		{`lengde(gjenta("", 9223372036854775807))`, "0"},
+		{`fyll_venstre("a", 9223372036854775807)`, "FEIL: strengen fra 'fyll_venstre' blir for lang, grensen er 67108864 byte [K026]"},
+		{`gjenta("ha", "3")`, "FEIL: argument 2 til 'gjenta' må være heltall, fikk streng [K013]"},
+		{`fyll_venstre("øl", 5)`, "   øl"},
+		{`fyll_venstre("7", 3, "0")`, "007"},
+		{`fyll_høyre("øl", 4, "å")`, "ølåå"},
+		{`fyll_høyre("blåbær", 3)`, "blåbær"},
+		{`fyll_høyre("a", 3, "ab")`, "FEIL: argument 3 til 'fyll_høyre' må være ett tegn, fikk \"ab\" [K018]"},
+		{`fyll_venstre("a", 3, "")`, "FEIL: argument 3 til 'fyll_venstre' må være ett tegn, fikk \"\" [K018]"},
+		{`fyll_venstre("a")`, "FEIL: feil antall argumenter, fikk 1, forventet minst 2 [K009]"},
+		{`fyll_venstre("a", 1, " ", " ")`, "FEIL: feil antall argumenter, fikk 4, forventet høyst 3 [K010]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s gave %q, expected %q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`la navn = "Kari"; la alder = 41; "Hei {navn}, du er {alder + 1} år"`, "Hei Kari, du er 42 år"},
+		{`"{1}{2}"`, "12"},
+		{`"{[1, "a"]} {{"b": sant}} {første([])}"`, "[1, a] {b: sant} ingenting"},
+		{`la f = funksjon(x) { "<{x}>" }; "{f(f(1))}"`, "<<1>>"},
+		{`"\{a\}"`, "{a}"},
+		{`lengde("{"æøå"}")`, "3"},
+		{`"a{1 + sant}"`, "FEIL: typene passer ikke sammen: heltall + sannhetsverdi [K002]"},
+		{`"a{x}"`, "FEIL: navnet er ikke definert: x [K001]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s gave %q, expected %q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestHigherOrderBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"tilordne([1, 2, 3], funksjon(x) { x * 2 })", "[2, 4, 6]"},
+		{"tilordne([], funksjon(x) { x })", "[]"},
+		{"tilordne([1, 2], streng)", "[1, 2]"},
+		{"tilordne([[1], [2, 3]], lengde)", "[1, 2]"},
+		{"tilordne([1], 2)", "FEIL: argument 2 til 'tilordne' må være funksjon, fikk heltall [K013]"},
+		{"tilordne([1], funksjon(x) { x + sant })", "FEIL: typene passer ikke sammen: heltall + sannhetsverdi [K002]"},
+		{"tilordne([1], funksjon() { 1 })", "FEIL: feil antall argumenter, fikk 1, forventet 0 [K008]"},
+		{"la a = [1, 2]; tilordne(a, funksjon(x) { 0 }); a", "[1, 2]"},
+		{"filtrer([1, 2, 3, 4], funksjon(x) { x > 2 })", "[3, 4]"},
+		{"filtrer([1, 2], funksjon(x) { 0 })", "[1, 2]"},
+		{"filtrer([1, 2], funksjon(x) { hvis (x > 1) { sant } })", "[2]"},
+		{"filtrer(1, funksjon(x) { x })", "FEIL: argument 1 til 'filtrer' må være liste, fikk heltall [K013]"},
+		{"reduser([1, 2, 3], 0, funksjon(sum, x) { sum + x })", "6"},
+		{"reduser([], 10, funksjon(sum, x) { sum + x })", "10"},
+		{`reduser(["a", "b"], "", funksjon(s, x) { x + s })`, "ba"},
+		{"reduser([1], 0)", "FEIL: feil antall argumenter, fikk 2, forventet 3 [K008]"},
+		{"reduser([1], 0, 0)", "FEIL: argument 3 til 'reduser' må være funksjon, fikk heltall [K013]"},
+		{"sorter([3, 1, 2])", "[1, 2, 3]"},
+		{"sorter([])", "[]"},
+		{`sorter(["ål", "øl", "ærfugl", "zebra", "and"])`, "[and, zebra, ærfugl, øl, ål]"},
+		{`sorter(["b", "Å", "a", "Z"])`, "[Z, Å, a, b]"},
+		{"sorter([3, 1, 2], funksjon(a, b) { a > b })", "[3, 2, 1]"},
+		{"sorter([[2, 1], [1, 2], [1, 1]], funksjon(a, b) { første(a) < første(b) })", "[[1, 2], [1, 1], [2, 1]]"},
+		{`sorter([1, "a"])`, "FEIL: typene passer ikke sammen: streng < heltall [K002]"},
+		{"sorter([sant, falskt])", "FEIL: ukjent operator: sannhetsverdi < sannhetsverdi [K004]"},
+		{"sorter([1, 2], funksjon(a, b) { a + sant })", "FEIL: typene passer ikke sammen: heltall + sannhetsverdi [K002]"},
+		{"la a = [2, 1]; sorter(a); a", "[2, 1]"},
+		{"reverser([1, 2, 3])", "[3, 2, 1]"},
+		{`reverser("blåbær")`, "ræbålb"},
+		{"reverser(1)", "FEIL: argumentet til 'reverser' støttes ikke, fikk heltall [K011]"},
+		{"finn([1, 5, 7], funksjon(x) { x > 4 })", "5"},
+		{"finn([1, 2], funksjon(x) { x > 4 })", "ingenting"},
+		{`finn([1], "a")`, "FEIL: argument 2 til 'finn' må være funksjon, fikk streng [K013]"},
+		{"finn(1, 2)", "FEIL: argumentet til 'finn' støttes ikke, fikk heltall [K011]"},
+		{"alle([1, 2], funksjon(x) { x > 0 })", "sant"},
+		{"alle([1, -2], funksjon(x) { x > 0 })", "falskt"},
+		{"alle([], funksjon(x) { falskt })", "sant"},
+		{"alle([0, 1], funksjon(x) { hvis (x > 0) { x + sant } ellers { falskt } })", "falskt"},
+		{"noen([1, -2], funksjon(x) { x < 0 })", "sant"},
+		{"noen([1, 2], funksjon(x) { x < 0 })", "falskt"},
+		{"noen([], funksjon(x) { sant })", "falskt"},
+		{"reduser([funksjon(x) { x * 3 }], [1, 2], tilordne)", "[3, 6]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s gave %q, expected %q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestHashBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`nøkler({"b": 1, "a": 2, [1]: 3})`, "[b, a, [1]]"},
+		{`nøkler({})`, "[]"},
+		{`verdier({"b": 1, "a": 2})`, "[1, 2]"},
+		{`par({"b": 1, sant: [2]})`, "[[b, 1], [sant, [2]]]"},
+		{`nøkler([1])`, "FEIL: argumentet til 'nøkler' må være tabell, fikk liste [K012]"},
+		{`verdier()`, "FEIL: feil antall argumenter, fikk 0, forventet 1 [K008]"},
+		{`har_nøkkel({"a": første([])}, "a")`, "sant"},
+		{`har_nøkkel({"a": 1}, "b")`, "falskt"},
+		{`har_nøkkel({[1, 2]: 1}, [1, 2])`, "sant"},
+		{`har_nøkkel({}, funksjon(x) { x })`, "FEIL: kan ikke brukes som nøkkel i en tabell: funksjon [K005]"},
+		{`har_nøkkel([], 1)`, "FEIL: argument 1 til 'har_nøkkel' må være tabell, fikk liste [K013]"},
+		{`fjern({"a": 1, "b": 2, "c": 3}, "b")`, "{a: 1, c: 3}"},
+		{`fjern({"a": 1}, "x")`, "{a: 1}"},
+		{`la t = {"a": 1}; fjern(t, "a"); t`, "{a: 1}"},
+		{`fjern({}, [funksjon(x) { x }])`, "FEIL: kan ikke brukes som nøkkel i en tabell: liste [K005]"},
+		{`slå_sammen({"a": 1, "b": 2}, {"b": 3, "c": 4})`, "{a: 1, b: 3, c: 4}"},
+		{`slå_sammen({}, {})`, "{}"},
+		{`slå_sammen({}, [])`, "FEIL: argument 2 til 'slå_sammen' må være tabell, fikk liste [K013]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s gave %q, expected %q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestFormatBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`formater("%d epler", 3)`, "3 epler"},
+		{`formater("[%5d]", 42)`, "[   42]"},
+		{`formater("[%-5d]", 42)`, "[42   ]"},
+		{`formater("[%05d]", -42)`, "[-0042]"},
+		{`formater("[%-6s|%6s]", "blå", [1])`, "[blå   |   [1]]"},
+		{`formater("%.2f", 3)`, "3.00"},
+		{`formater("%8.1f", 10)`, "    10.0"},
+		{`formater("100%%")`, "100%"},
+		{`formater("%d%% av %s", 50, "alle")`, "50% av alle"},
+		{`formater("")`, ""},
+		{`formater("%d og %d", 1)`, "FEIL: malen har 2 plassholdere, men fikk 1 verdier [K019]"},
+		{`formater("%s", 1, 2)`, "FEIL: malen har 1 plassholdere, men fikk 2 verdier [K019]"},
+		{`formater("%d", "tre")`, "FEIL: argument 2 til 'formater' må være heltall, fikk streng [K013]"},
+		{`formater("%5x", 1)`, `FEIL: ukjent plassholder i malen: "%5x" [K020]`},
+		{`formater("ferdig %")`, `FEIL: ukjent plassholder i malen: "%" [K020]`},
+		{`formater("[%1000d]", 1)`, "[" + strings.Repeat(" ", 999) + "1]"},
+		{`formater("%1001d", 1)`, `FEIL: plassholderen "%1001d" er for bred, bredden og antall desimaler kan være høyst 1000 [K023]`},
+		{`formater("%.99999999999999999999f", 1)`, `FEIL: plassholderen "%.99999999999999999999f" er for bred, bredden og antall desimaler kan være høyst 1000 [K023]`},
+		{`formater(1)`, "FEIL: argumentet til 'formater' må være streng, fikk heltall [K012]"},
+		{`formater()`, "FEIL: feil antall argumenter, fikk 0, forventet minst 1 [K009]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s gave %q, expected %q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestPrintOptions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`skriv(1, "a")`, "1\na\n"},
+		{`skriv()`, ""},
+		{`skriv(1, 2, 3, skrivevalg({"skille": ", "}))`, "1, 2, 3\n"},
+		{`skriv("a", "b", skrivevalg({"skille": "", "slutt": "!"}))`, "ab!"},
+		{`skriv("a", skrivevalg({"slutt": ""}))`, "a"},
+		{`skriv(skrivevalg({"slutt": "!"}))`, ""},
+		{`skriv({"a": 1})`, "{a: 1}\n"},
+		{`skriv({})`, "{}\n"},
+		{`skriv(1, {"skille": "-", "slutt": "!"})`, "1\n{skille: -, slutt: !}\n"},
+		{`skriv(skrivevalg({}), 1)`, "skrivevalg({\"skille\": \"\\n\", \"slutt\": \"\\n\"})\n1\n"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		output = &out
+		Eval(parser.New(lexer.New(tt.input)).ParseProgram(), object.NewEnvironment())
+		output = os.Stderr
+
+		if out.String() != tt.expected {
+			t.Errorf("%s wrote %q, expected %q", tt.input, out.String(), tt.expected)
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`skrivevalg({"slutt": 2})`, "FEIL: valget \"slutt\" til 'skrivevalg' må være streng, fikk heltall [K021]"},
+		{`skrivevalg({"farge": "rød"})`, "FEIL: ukjent valg til 'skrivevalg': farge [K022]"},
+		{`skrivevalg({1: "a"})`, "FEIL: ukjent valg til 'skrivevalg': 1 [K022]"},
+		{`skrivevalg(1)`, "FEIL: argumentet til 'skrivevalg' må være tabell, fikk heltall [K012]"},
+	}
+
+	for _, tt := range errorTests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s gave %q, expected %q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestNullExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"ingenting", "ingenting"},
+		{"!ingenting", "sant"},
+		{"hvis (ingenting) { 1 } ellers { 2 }", "2"},
+		{"er_ingenting(ingenting)", "sant"},
+		{"er_ingenting([])", "falskt"},
+		{"er_ingenting()", "FEIL: feil antall argumenter, fikk 0, forventet 1 [K008]"},
+		{"ingenting ?? 1", "1"},
+		{"0 ?? 1", "0"},
+		{`"" ?? 1`, ""},
+		{"første([]) ?? første([]) ?? 3", "3"},
+		{"la x = 1; ingenting ?? hvis (sant) { x + 1 }", "2"},
+		{"2 ?? 1 + sant", "2"},
+		{"ingenting ?? 1 + sant", "FEIL: typene passer ikke sammen: heltall + sannhetsverdi [K002]"},
+		{"ingenting ?? 1 == 1", "sant"},
+		{`la t = {"a": [1, 2]}; t["a"]?[1]`, "2"},
+		{`la t = {"a": [1, 2]}; t["b"]?[1]`, "ingenting"},
+		{`la t = {"a": [1, 2]}; t["b"]?[1] ?? "mangler"`, "mangler"},
+		{`ingenting?[1 + sant]`, "ingenting"},
+		{`[1]?[5]`, "ingenting"},
+		{`1?[0]`, "FEIL: kan ikke indeksere heltall [K006]"},
+		{`ingenting[0]`, "FEIL: kan ikke indeksere ingenting [K006]"},
+		{"la f = funksjon(x) { x ?? 0 }; f(ingenting) + f(2)", "2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s gave %q, expected %q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestTypeBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"type(1)", "heltall"},
+		{"type(desimal(1))", "desimaltall"},
+		{`type("")`, "streng"},
+		{"type(sant)", "sannhetsverdi"},
+		{"type(ingenting)", "ingenting"},
+		{"type([])", "liste"},
+		{"type({})", "tabell"},
+		{"type(funksjon() {})", "funksjon"},
+		{"type(lengde)", "innebygd funksjon"},
+		{`heltall("42")`, "42"},
+		{`heltall(" -7 ")`, "-7"},
+		{`heltall("x")`, `FEIL: kan ikke gjøre "x" om til heltall [K024]`},
+		{`heltall("4,5")`, `FEIL: kan ikke gjøre "4,5" om til heltall [K024]`},
+		{`heltall(desimal("-2,9"))`, "-2"},
+		{"heltall(sant)", "1"},
+		{"heltall([])", "FEIL: argumentet til 'heltall' støttes ikke, fikk liste [K011]"},
+		{`desimal("3,5")`, "3.5"},
+		{`desimal("3.5")`, "3.5"},
+		{"desimal(2)", "2.0"},
+		{`desimal("tre")`, `FEIL: kan ikke gjøre "tre" om til desimaltall [K024]`},
+		{`desimal("NaN")`, `FEIL: kan ikke gjøre "NaN" om til desimaltall [K024]`},
+		{`desimal("-Inf")`, `FEIL: kan ikke gjøre "-Inf" om til desimaltall [K024]`},
+		{`desimal("infinity")`, `FEIL: kan ikke gjøre "infinity" om til desimaltall [K024]`},
+		{`desimal("0x1p3")`, `FEIL: kan ikke gjøre "0x1p3" om til desimaltall [K024]`},
+		{`desimal("1e400")`, `FEIL: kan ikke gjøre "1e400" om til desimaltall [K024]`},
+		{`desimal("1,5e3")`, "1500.0"},
+		{`desimal("1,5") + 1`, "2.5"},
+		{`desimal("1,5") * 2 - desimal("0,5")`, "2.5"},
+		{"1 / desimal(4)", "0.25"},
+		{"-desimal(2)", "-2.0"},
+		{"desimal(1) < 2", "sant"},
+		{"1 == desimal(1)", "sant"},
+		{`{1: "a"}[desimal(1)]`, "a"},
+		{`formater("%.1f", desimal("2,25"))`, "2.2"},
+		{`sorter([2, desimal("1,5"), 1])`, "[1, 1.5, 2]"},
+		{"sannhetsverdi(0)", "sant"},
+		{"sannhetsverdi(ingenting)", "falskt"},
+		{`liste("hei")`, "[h, e, i]"},
+		{`liste({"a": 1, "b": 2})`, "[a, b]"},
+		{`liste("")`, "[]"},
+		{"liste(1)", "FEIL: argumentet til 'liste' støttes ikke, fikk heltall [K011]"},
+		{`[er_tall(1), er_tall(desimal(1)), er_tall("1")]`, "[sant, sant, falskt]"},
+		{`[er_streng(""), er_streng([])]`, "[sant, falskt]"},
+		{"[er_liste([]), er_liste({})]", "[sant, falskt]"},
+		{"[er_tabell({}), er_tabell([])]", "[sant, falskt]"},
+		{"[er_funksjon(funksjon() {}), er_funksjon(lengde), er_funksjon(1)]", "[sant, sant, falskt]"},
+		{"type()", "FEIL: feil antall argumenter, fikk 0, forventet 1 [K008]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s gave %q, expected %q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestInExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"a" i {"a": 1}`, "sant"},
+		{`"b" i {"a": 1}`, "falskt"},
+		{`"a" i {"a": første([])}`, "sant"},
+		{`[1] i {[1]: 2}`, "sant"},
+		{"3 i [1, 2, 3]", "sant"},
+		{"4 i [1, 2, 3]", "falskt"},
+		{"[1, 2] i [[1, 2]]", "sant"},
+		{`"1" i [1]`, "falskt"},
+		{`"ø" i "blåbærsyltetøy"`, "sant"},
+		{`"x" i "blåbær"`, "falskt"},
+		{`"" i ""`, "sant"},
+		{"1 + 2 i [3]", "sant"},
+		{"1 i [1] == sant", "sant"},
+		{`1 i "1"`, "FEIL: typene passer ikke sammen: heltall i streng [K002]"},
+		{"1 i 1", "FEIL: ukjent operator: heltall i heltall [K004]"},
+		{"funksjon(x) { x } i {}", "FEIL: kan ikke brukes som nøkkel i en tabell: funksjon [K005]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s gave %q, expected %q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestDialectBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		dialect  *token.Dialect
+		expected int64
+	}{
+		{"lengd([1, 2, 3])", token.Nynorsk, 3},
+		{"fyrste(legg_til([], 4))", token.Nynorsk, 4},
+		{"len(push([1], 2))", token.English, 2},
+		{`len(keys({"a": 1, "b": 2}))`, token.English, 2},
+		{"if (2 in [1, 2]) { 1 } else { 0 }", token.English, 1},
+		{"let len = fn(x) { 7 }; len([])", token.English, 7},
+		{"# dialekt: nynorsk\nlat l = lengd; l([1])", token.Bokmal, 1},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewWithDialect(tt.input, tt.dialect)
+		p := parser.New(l)
+		env := object.NewEnvironment()
+		env.SetDialect(l.Dialect())
+		program := p.ParseProgram()
+
+		checkIntegerObject(t, Eval(program, env), tt.expected)
+	}
+
+	evaluated := testEval(t, "lengd([1])")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Code != diagnostic.IdentifierNotFound {
+		t.Errorf("nynorsk builtin found in bokmål, got %T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestBuiltinsInEveryDialect(t *testing.T) {
+	for name := range builtins {
+		for _, dialect := range token.Dialects {
+			if _, ok := dialect.BuiltinName(name); !ok {
+				t.Errorf("builtin %q has no name in %s", name, dialect.Name)
+			}
+		}
+	}
+}
+
+func TestBuiltinNames(t *testing.T) {
+	if len(BuiltinNames) != len(builtins) {
+		t.Errorf("BuiltinNames has %d names, but there are %d builtins", len(BuiltinNames), len(builtins))
+	}
+
+	for _, name := range BuiltinNames {
+		if _, ok := LookupBuiltin(name); !ok {
+			t.Errorf("%s is in BuiltinNames, but is not a builtin", name)
+		}
+	}
+}
+
+func TestBuiltinArity(t *testing.T) {
+	isArityError := func(obj object.Object) bool {
+		err, ok := obj.(*object.Error)
+		if !ok {
+			return false
+		}
+		switch err.Code {
+		case diagnostic.WrongArgumentCount, diagnostic.TooFewArguments, diagnostic.TooManyArguments:
+			return true
+		default:
+			return false
+		}
+	}
+
+	for name, builtin := range builtins {
+		if builtin.MinArgs > 0 {
+			args := make([]object.Object, builtin.MinArgs-1)
+			for i := range args {
+				args[i] = NULL
+			}
+			if !isArityError(builtin.Fn(nil, args...)) {
+				t.Errorf("%s accepts %d arguments, but MinArgs is %d", name, len(args), builtin.MinArgs)
+			}
+		}
+
+		if builtin.MaxArgs >= 0 {
+			args := make([]object.Object, builtin.MaxArgs+1)
+			for i := range args {
+				args[i] = NULL
+			}
+			if !isArityError(builtin.Fn(nil, args...)) {
+				t.Errorf("%s accepts %d arguments, but MaxArgs is %d", name, len(args), builtin.MaxArgs)
+			}
+		}
+	}
+}
+
 func TestArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
-	evaluated := testEval(input)
+	evaluated := testEval(t, input)
 	result, ok := evaluated.(*object.Array)
 	if !ok {
 		t.Fatalf("object is not Array, got %T (%+v)", evaluated, evaluated)
@@ -350,11 +840,13 @@ func TestArrayIndexExpressions(t *testing.T) {
 		{input: "la myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", expected: 6},
 		{input: "la myArray = [1, 2, 3]; la i = myArray[0]; myArray[i]", expected: 2},
 		{input: "[1, 2, 3][3]", expected: nil},
-		{input: "[1, 2, 3][-1]", expected: nil},
+		{input: "[1, 2, 3][-1]", expected: 3},
+		{input: "[1, 2, 3][-3]", expected: 1},
+		{input: "[1, 2, 3][-4]", expected: nil},
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		integer, ok := tt.expected.(int)
 		if ok {
 			checkIntegerObject(t, evaluated, int64(integer))
@@ -365,6 +857,42 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestStringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"blåbær"[0]`, "b"},
+		{`"blåbær"[2]`, "å"},
+		{`"blåbær"[5]`, "r"},
+		{`"blåbær"[-1]`, "r"},
+		{`"blåbær"[-4]`, "å"},
+		{`la i = 1; "æøå"[i + 1]`, "å"},
+		{`"blåbær"[6]`, nil},
+		{`"blåbær"[-7]`, nil},
+		{`""[0]`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		expected, ok := tt.expected.(string)
+		if !ok {
+			checkNullObject(t, evaluated)
+			continue
+		}
+
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String, got %T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if str.Value != expected {
+			t.Errorf("wrong string value for %s, expected %q, got %q", tt.input, expected, str.Value)
+		}
+	}
+}
+
 func TestHashLiterals(t *testing.T) {
 	input := `la two = "two";
 	{
@@ -376,32 +904,40 @@ func TestHashLiterals(t *testing.T) {
 		falskt: 6
 	}`
 
-	evaluated := testEval(input)
+	evaluated := testEval(t, input)
 	result, ok := evaluated.(*object.Hash)
 	if !ok {
 		t.Fatalf("Eval didn't return Hash, got %T (%+v)", evaluated, evaluated)
 	}
 
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		TRUE.HashKey():                             5,
-		FALSE.HashKey():                            6,
+	expected := []struct {
+		key   object.Hashable
+		value int64
+	}{
+		{&object.String{Value: "one"}, 1},
+		{&object.String{Value: "two"}, 2},
+		{&object.String{Value: "three"}, 3},
+		{&object.Integer{Value: 4}, 4},
+		{TRUE, 5},
+		{FALSE, 6},
 	}
 
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs, got %d", len(result.Pairs))
+	pairs := result.Pairs()
+	if len(pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs, got %d", len(pairs))
 	}
 
-	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+	for i, tt := range expected {
+		if pairs[i].Key.Inspect() != tt.key.Inspect() {
+			t.Errorf("pair %d has wrong key, want %s, got %s", i, tt.key.Inspect(), pairs[i].Key.Inspect())
+		}
+
+		value, ok := result.Get(tt.key)
 		if !ok {
 			t.Errorf("no pair for given key in Pairs")
 		}
 
-		checkIntegerObject(t, pair.Value, expectedValue)
+		checkIntegerObject(t, value, tt.value)
 	}
 }
 
@@ -419,7 +955,7 @@ func TestHashIndexExpressions(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 		integer, ok := tt.expected.(int)
 		if ok {
 			checkIntegerObject(t, evaluated, int64(integer))
@@ -435,20 +971,24 @@ func TestErrorHandling(t *testing.T) {
 		input       string
 		expectedMsg string
 	}{
-		{"5 + sant;", "type mismatch: INTEGER + BOOLEAN"},
-		{"5 + sant; 5;", "type mismatch: INTEGER + BOOLEAN"},
-		{"-sant", "unknown operator: -BOOLEAN"},
-		{"sant + falskt;", "unknown operator: BOOLEAN + BOOLEAN"},
-		{"5; sant + falskt; 5", "unknown operator: BOOLEAN + BOOLEAN"},
-		{"hvis (10 > 1) { sant + falskt; }", "unknown operator: BOOLEAN + BOOLEAN"},
-		{"hvis (10 > 1) { hvis (10 > 1) { returner sant + falskt; } returner 1 }", "unknown operator: BOOLEAN + BOOLEAN"},
-		{"foobar", "identifier not found: foobar"},
-		{`"Hello" - "World!"`, "unknown operator: STRING - STRING"},
-		{`{"name": "Monkey"}[funksjon(x) { x }];`, "unusable as hash key: FUNCTION"},
+		{"5 + sant;", "typene passer ikke sammen: heltall + sannhetsverdi"},
+		{"5 + sant; 5;", "typene passer ikke sammen: heltall + sannhetsverdi"},
+		{"-sant", "ukjent operator: -sannhetsverdi"},
+		{"sant + falskt;", "ukjent operator: sannhetsverdi + sannhetsverdi"},
+		{"5; sant + falskt; 5", "ukjent operator: sannhetsverdi + sannhetsverdi"},
+		{"hvis (10 > 1) { sant + falskt; }", "ukjent operator: sannhetsverdi + sannhetsverdi"},
+		{"hvis (10 > 1) { hvis (10 > 1) { returner sant + falskt; } returner 1 }", "ukjent operator: sannhetsverdi + sannhetsverdi"},
+		{"foobar", "navnet er ikke definert: foobar"},
+		{`"Hello" - "World!"`, "ukjent operator: streng - streng"},
+		{`{"name": "Monkey"}[funksjon(x) { x }];`, "kan ikke brukes som nøkkel i en tabell: funksjon"},
+		{"la f = funksjon(x, y) { x }; f(1)", "feil antall argumenter, fikk 1, forventet 2"},
+		{"la f = funksjon(x) { x }; f(1, 2)", "feil antall argumenter, fikk 2, forventet 1"},
+		{"{x: 1, y: 2}", "navnet er ikke definert: x"},
+		{"{1: x, y: 2}", "navnet er ikke definert: x"},
 	}
 
 	for _, tt := range tests {
-		evaluated := testEval(tt.input)
+		evaluated := testEval(t, tt.input)
 
 		errObj, ok := evaluated.(*object.Error)
 		if !ok {
@@ -462,12 +1002,120 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
-func testEval(input string) object.Object {
+func TestErrorHandlingEnglish(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedCode diagnostic.Code
+		expectedMsg  string
+	}{
+		{"5 + sant;", diagnostic.TypeMismatch, "type mismatch: INTEGER + BOOLEAN"},
+		{"-sant", diagnostic.UnknownPrefixOperator, "unknown operator: -BOOLEAN"},
+		{"foobar", diagnostic.IdentifierNotFound, "identifier not found: foobar"},
+		{`lengde(1)`, diagnostic.ArgumentNotSupported, "argument to 'lengde' not supported, got INTEGER"},
+		{`kutt([1], 2)`, diagnostic.InvalidSliceIndices, "invalid slice indices: start=2, stop=1"},
+	}
+
+	defer diagnostic.SetLanguage(diagnostic.CurrentLanguage())
+	diagnostic.SetLanguage(diagnostic.English)
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned, got %T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Code != tt.expectedCode {
+			t.Errorf("wrong error code, expected %s, got %s", tt.expectedCode, errObj.Code)
+		}
+
+		if errObj.Message != tt.expectedMsg {
+			t.Errorf("wrong error message, expected %q, got %q", tt.expectedMsg, errObj.Message)
+		}
+	}
+}
+
+// testEval evaluates input. It also evaluates input after running the
+// optimizer on it, and reports a test error with t.Errorf if the result is
+// not the same, so every test that uses testEval tests the optimizer too.
+func testEval(t *testing.T, input string) object.Object {
+	t.Helper()
+
 	l := lexer.New(input)
 	p := parser.New(l)
 	env := object.NewEnvironment()
 	program := p.ParseProgram()
-	return Eval(program, env)
+	evaluated := Eval(program, env)
+
+	optimized := optimizer.Optimize(parser.New(lexer.New(input)).ParseProgram(), token.Bokmal)
+	if result := Eval(optimized, object.NewEnvironment()); !sameResult(evaluated, result) {
+		t.Errorf("optimizer changed the result of %q from %s to %s\noptimized program: %s",
+			input, inspect(evaluated), inspect(result), optimized.String())
+	}
+
+	return evaluated
+}
+
+// sameResult reports whether a program gave the same result as the
+// optimized program. Functions are the same if they have the same parameters
+// and the body of a, optimized, is the body of b.
+func sameResult(a, b object.Object) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+
+	switch a := a.(type) {
+	case *object.Function:
+		other := b.(*object.Function)
+		if len(a.Parameters) != len(other.Parameters) {
+			return false
+		}
+		for i := range a.Parameters {
+			if a.Parameters[i].Value != other.Parameters[i].Value {
+				return false
+			}
+		}
+		// The body is copied, since the optimizer changes it in place.
+		body := ast.Copy(a.Body).(*ast.BlockStatement)
+		optimized := optimizer.Optimize(&ast.Program{Statements: body.Statements}, token.Bokmal)
+		return optimized.String() == other.Body.String()
+	case *object.Array:
+		elements := b.(*object.Array).Elements
+		if len(a.Elements) != len(elements) {
+			return false
+		}
+		for i := range a.Elements {
+			if !sameResult(a.Elements[i], elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *object.Hash:
+		pairs, other := a.Pairs(), b.(*object.Hash).Pairs()
+		if len(pairs) != len(other) {
+			return false
+		}
+		for i := range pairs {
+			if !sameResult(pairs[i].Key, other[i].Key) || !sameResult(pairs[i].Value, other[i].Value) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a.Inspect() == b.Inspect()
+	}
+}
+
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return "nil"
+	}
+	return obj.Inspect()
 }
 
 func checkNullObject(t *testing.T, obj object.Object) bool {
@@ -506,3 +1154,256 @@ func checkBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	}
 	return true
 }
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`sitat(5)`, `5`},
+		{`sitat(5 + 8)`, `(5 + 8)`},
+		{`sitat(foobar)`, `foobar`},
+		{`sitat(foobar + barfoo)`, `(foobar + barfoo)`},
+	}
+
+	for _, tt := range tests {
+		checkQuote(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`sitat(avsitat(4))`, `4`},
+		{`sitat(avsitat(4 + 4))`, `8`},
+		{`sitat(8 + avsitat(4 + 4))`, `(8 + 8)`},
+		{`sitat(avsitat(4 + 4) + 8)`, `(8 + 8)`},
+		{`la foobar = 8; sitat(foobar)`, `foobar`},
+		{`la foobar = 8; sitat(avsitat(foobar))`, `8`},
+		{`sitat(avsitat(sant))`, `sant`},
+		{`sitat(avsitat(sant == falskt))`, `falskt`},
+		{`sitat(avsitat("hei"))`, `hei`},
+		{`sitat(avsitat(første([])) ?? 1)`, `(ingenting ?? 1)`},
+		{`sitat(avsitat(sitat(4 + 4)))`, `(4 + 4)`},
+		{`la quotedInfixExpression = sitat(4 + 4);
+		  sitat(avsitat(4 + 4) + avsitat(quotedInfixExpression))`, `(8 + (4 + 4))`},
+		{`la f = funksjon(x) { sitat(avsitat(x) + 1) }; f(1); f(2)`, `(2 + 1)`},
+	}
+
+	for _, tt := range tests {
+		checkQuote(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestQuoteErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected diagnostic.Code
+	}{
+		{`sitat(1, 2)`, diagnostic.WrongArgumentCount},
+		{`sitat(avsitat(1, 2))`, diagnostic.WrongArgumentCount},
+		{`sitat(avsitat(x))`, diagnostic.IdentifierNotFound},
+		{`sitat(avsitat([1]))`, diagnostic.UnquoteNotSupported},
+		{`avsitat(1)`, diagnostic.IdentifierNotFound},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(t, tt.input).(*object.Error)
+		if !ok || errObj.Code != tt.expected {
+			t.Errorf("%q should fail with %s, got %v", tt.input, tt.expected, errObj)
+		}
+	}
+}
+
+func checkQuote(t *testing.T, evaluated object.Object, expected string) {
+	t.Helper()
+
+	quote, ok := evaluated.(*object.Quote)
+	if !ok {
+		t.Fatalf("expected *object.Quote, got %T (%+v)", evaluated, evaluated)
+	}
+
+	if quote.Node == nil {
+		t.Fatalf("quote.Node is nil")
+	}
+
+	if quote.Node.String() != expected {
+		t.Errorf("not equal, got %q, want %q", quote.Node.String(), expected)
+	}
+}
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+	la number = 1;
+	la function = funksjon(x, y) { x + y };
+	la mymacro = makro(x, y) { x + y; };
+	`
+
+	env := object.NewEnvironment()
+	program := testParseProgram(input)
+
+	DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("wrong number of statements, got %d", len(program.Statements))
+	}
+
+	if _, ok := env.Get("number"); ok {
+		t.Fatalf("number should not be defined")
+	}
+	if _, ok := env.Get("function"); ok {
+		t.Fatalf("function should not be defined")
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment")
+	}
+
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro, got %T (%+v)", obj, obj)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("wrong number of macro parameters, got %d", len(macro.Parameters))
+	}
+	if macro.Parameters[0].String() != "x" || macro.Parameters[1].String() != "y" {
+		t.Fatalf("parameters are not 'x' and 'y', got %v", macro.Parameters)
+	}
+
+	if macro.Body.String() != "(x + y)" {
+		t.Fatalf("body is not %q, got %q", "(x + y)", macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`la infixExpression = makro() { sitat(1 + 2); }; infixExpression();`,
+			`(1 + 2)`,
+		},
+		{
+			`la reverse = makro(a, b) { sitat(avsitat(b) - avsitat(a)); }; reverse(2 + 2, 10 - 5);`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`
+			la unless = makro(condition, consequence, alternative) {
+				sitat(hvis (!(avsitat(condition))) {
+					avsitat(consequence);
+				} ellers {
+					avsitat(alternative);
+				});
+			};
+
+			unless(10 > 5, skriv("not greater"), skriv("greater"));
+			`,
+			`hvis (!(10 > 5)) { skriv("not greater") } ellers { skriv("greater") }`,
+		},
+		{
+			`la twice = makro(x) { sitat(avsitat(x) + avsitat(x)) }; twice(1); twice(2)`,
+			`(1 + 1); (2 + 2)`,
+		},
+		{
+			`la id = makro(x) { x }; funksjon() { id(1) + id(2) }`,
+			`funksjon() { 1 + 2 }`,
+		},
+	}
+
+	for _, tt := range tests {
+		expected := testParseProgram(tt.expected)
+		program := testParseProgram(tt.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+		expanded, err := ExpandMacros(program, env)
+		if err != nil {
+			t.Fatalf("error expanding %q: %s", tt.input, err.Message)
+		}
+
+		if expanded.String() != expected.String() {
+			t.Errorf("not equal, want %q, got %q", expected.String(), expanded.String())
+		}
+	}
+}
+
+func TestExpandMacrosErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected diagnostic.Code
+	}{
+		{`la m = makro(x) { x }; m()`, diagnostic.WrongArgumentCount},
+		{`la m = makro() { 1 }; m()`, diagnostic.MacroMustReturnQuote},
+		{`la m = makro() { la x = 1; }; m()`, diagnostic.MacroMustReturnQuote},
+		{`la m = makro() { y }; m()`, diagnostic.IdentifierNotFound},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		program := testParseProgram(tt.input)
+		DefineMacros(program, env)
+		_, err := ExpandMacros(program, env)
+		if err == nil || err.Code != tt.expected {
+			t.Errorf("%q should fail with %s, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestExpandProgramDialect(t *testing.T) {
+	l := lexer.NewWithDialect(`let m = macro(x) { quote(unquote(x) * 2) }; m(21)`, token.English)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	expanded, err := ExpandProgram(program, l.Dialect())
+	if err != nil {
+		t.Fatalf("error expanding: %s", err.Message)
+	}
+
+	checkIntegerObject(t, Eval(expanded, object.NewEnvironment()), 42)
+}
+
+func testParseProgram(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+func TestJSONRoundTripEvaluates(t *testing.T) {
+	inputs := []string{
+		"5 + 10 * 2 - -3",
+		`"Hei" + ", " + "verden"`,
+		"hvis (1 < 2) { 10 } ellers { 20 }",
+		"la f = funksjon(x) { hvis (x > 10) { returner x; } f(x + 1) }; f(0)",
+		"la lag = funksjon(x) { funksjon(y) { x + y } }; lag(2)(3)",
+		`la t = {"en": 1, 2: [1, 2, 3], sant: "ja"}; t[2][1] + t["en"]`,
+		`lengde(tilføy([1, 2], 3)) + lengde("abc")`,
+		"!sant == falskt",
+		`la m = makro(a) { sitat(avsitat(a) * 2) }; sitat(m(1))`,
+		"1 + sant",
+	}
+
+	for _, input := range inputs {
+		program := testParseProgram(input)
+
+		data, err := ast.EncodeJSON(program)
+		if err != nil {
+			t.Fatalf("%q: encode error: %s", input, err)
+		}
+		decoded, err := ast.DecodeJSON(data)
+		if err != nil {
+			t.Fatalf("%q: decode error: %s", input, err)
+		}
+
+		expected := Eval(program, object.NewEnvironment())
+		actual := Eval(decoded, object.NewEnvironment())
+		if actual.Inspect() != expected.Inspect() {
+			t.Errorf("%q: decoded program gave %q, want %q", input, actual.Inspect(), expected.Inspect())
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// evaluator/macro_expansion.go
+
+package evaluator
+
+import (
+	"github.com/solbero/pytonskript/ast"
+	"github.com/solbero/pytonskript/diagnostic"
+	"github.com/solbero/pytonskript/object"
+	"github.com/solbero/pytonskript/token"
+)
+
+// ExpandProgram defines the macros of a whole program and expands the calls
+// to them, in an environment of their own that looks up sitat and avsitat in
+// dialect.
+func ExpandProgram(program *ast.Program, dialect *token.Dialect) (*ast.Program, *object.Error) {
+	env := object.NewEnvironment()
+	env.SetDialect(dialect)
+
+	DefineMacros(program, env)
+	return ExpandMacros(program, env)
+}
+
+// DefineMacros moves the macros defined with la at the top level of program
+// into env, so that ExpandMacros can find them.
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	statements := []ast.Statement{}
+
+	for _, statement := range program.Statements {
+		if let, ok := statement.(*ast.LetStatement); ok {
+			if macro, ok := let.Value.(*ast.MacroLiteral); ok {
+				env.Set(let.Name.Value, &object.Macro{Parameters: macro.Parameters, Body: macro.Body, Env: env})
+				continue
+			}
+		}
+		statements = append(statements, statement)
+	}
+
+	program.Statements = statements
+}
+
+// ExpandMacros replaces every call to a macro in env with the sitat the
+// macro returns when it is called with its arguments quoted. It stops at the
+// first macro that fails.
+func ExpandMacros(program *ast.Program, env *object.Environment) (*ast.Program, *object.Error) {
+	var err *object.Error
+
+	expanded := ast.Modify(program, func(node ast.Node) ast.Node {
+		if err != nil {
+			return node
+		}
+
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return node
+		}
+
+		macro, ok := isMacroCall(call, env)
+		if !ok {
+			return node
+		}
+
+		if len(call.Arguments) != len(macro.Parameters) {
+			err = newError(diagnostic.WrongArgumentCount, len(call.Arguments), len(macro.Parameters))
+			return node
+		}
+
+		evalEnv := extendMacroEnv(macro, quoteArgs(call))
+		evaluated := unwrapReturnValue(Eval(macro.Body, evalEnv))
+		if isError(evaluated) {
+			err = evaluated.(*object.Error)
+			return node
+		}
+
+		quote, ok := evaluated.(*object.Quote)
+		if !ok {
+			if evaluated == nil {
+				evaluated = NULL
+			}
+			err = newError(diagnostic.MacroMustReturnQuote, typeName(evaluated))
+			return node
+		}
+
+		return quote.Node
+	})
+
+	return expanded.(*ast.Program), err
+}
+
+func isMacroCall(call *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+
+	obj, ok := env.Get(ident.Value)
+	if !ok {
+		return nil, false
+	}
+
+	macro, ok := obj.(*object.Macro)
+	return macro, ok
+}
+
+func quoteArgs(call *ast.CallExpression) []*object.Quote {
+	args := []*object.Quote{}
+
+	for _, a := range call.Arguments {
+		args = append(args, &object.Quote{Node: a})
+	}
+
+	return args
+}
+
+func extendMacroEnv(macro *object.Macro, args []*object.Quote) *object.Environment {
+	extended := object.NewEnclosedEnvironment(macro.Env)
+
+	for i, param := range macro.Parameters {
+		extended.Set(param.Value, args[i])
+	}
+
+	return extended
+}
@@ -0,0 +1,117 @@
+// evaluator/quote_unquote.go
+
+package evaluator
+
+import (
+	"strconv"
+
+	"github.com/solbero/pytonskript/ast"
+	"github.com/solbero/pytonskript/diagnostic"
+	"github.com/solbero/pytonskript/object"
+	"github.com/solbero/pytonskript/token"
+)
+
+// The bokmål names of sitat and avsitat. They look like builtins, but their
+// arguments are not evaluated, so the evaluator handles them itself.
+const (
+	quoteName   = "sitat"
+	unquoteName = "avsitat"
+)
+
+// isSpecialCall reports whether node calls the special form with the bokmål
+// name, spelled as in the dialect of env.
+func isSpecialCall(node ast.Node, name string, env *object.Environment) bool {
+	call, ok := node.(*ast.CallExpression)
+	if !ok {
+		return false
+	}
+
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return false
+	}
+
+	canonical, ok := env.Dialect().Builtin(ident.Value)
+	return ok && canonical == name
+}
+
+// quote returns the argument of a call to sitat without evaluating it, except
+// for the calls to avsitat in it, which are replaced by their values.
+func quote(call *ast.CallExpression, env *object.Environment) object.Object {
+	if len(call.Arguments) != 1 {
+		return newError(diagnostic.WrongArgumentCount, len(call.Arguments), 1)
+	}
+
+	node, err := evalUnquoteCalls(call.Arguments[0], env)
+	if err != nil {
+		return err
+	}
+
+	return &object.Quote{Node: node}
+}
+
+// evalUnquoteCalls replaces the calls to avsitat in a copy of quoted, so that
+// a function or macro that quotes gets a fresh tree every time it runs.
+func evalUnquoteCalls(quoted ast.Node, env *object.Environment) (ast.Node, *object.Error) {
+	var err *object.Error
+
+	node := ast.Modify(ast.Copy(quoted), func(node ast.Node) ast.Node {
+		if err != nil || !isSpecialCall(node, unquoteName, env) {
+			return node
+		}
+
+		call := node.(*ast.CallExpression)
+		if len(call.Arguments) != 1 {
+			err = newError(diagnostic.WrongArgumentCount, len(call.Arguments), 1)
+			return node
+		}
+
+		unquoted := Eval(call.Arguments[0], env)
+		if isError(unquoted) {
+			err = unquoted.(*object.Error)
+			return node
+		}
+
+		converted, ok := convertObjectToASTNode(unquoted, call.Function.(*ast.Identifier).Token.Pos, env)
+		if !ok {
+			err = newError(diagnostic.UnquoteNotSupported, typeName(unquoted))
+			return node
+		}
+		return converted
+	})
+
+	return node, err
+}
+
+// convertObjectToASTNode turns the value of a call to avsitat back into a
+// node. The new node gets the position of the call.
+func convertObjectToASTNode(obj object.Object, pos token.Position, env *object.Environment) (ast.Node, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{Type: token.INT, Literal: strconv.FormatInt(obj.Value, 10), Pos: pos}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}, true
+
+	case *object.String:
+		t := token.Token{Type: token.STRING, Literal: obj.Value, Pos: pos}
+		return &ast.StringLiteral{Token: t, Value: obj.Value}, true
+
+	case *object.Boolean:
+		t := token.Token{Type: token.FALSE, Pos: pos}
+		if obj.Value {
+			t.Type = token.TRUE
+		}
+		t.Literal, _ = env.Dialect().Keyword(t.Type)
+		return &ast.Boolean{Token: t, Value: obj.Value}, true
+
+	case *object.Null:
+		t := token.Token{Type: token.NULL, Pos: pos}
+		t.Literal, _ = env.Dialect().Keyword(t.Type)
+		return &ast.NullLiteral{Token: t}, true
+
+	case *object.Quote:
+		return obj.Node, true
+
+	default:
+		return nil, false
+	}
+}
@@ -3,36 +3,112 @@ package exec
 import (
 	"io"
 
+	"github.com/solbero/pytonskript/ast"
+	"github.com/solbero/pytonskript/bytecode"
+	"github.com/solbero/pytonskript/compiler"
+	"github.com/solbero/pytonskript/diagnostic"
 	"github.com/solbero/pytonskript/evaluator"
 	"github.com/solbero/pytonskript/lexer"
 	"github.com/solbero/pytonskript/object"
+	"github.com/solbero/pytonskript/optimizer"
 	"github.com/solbero/pytonskript/parser"
+	"github.com/solbero/pytonskript/token"
+	"github.com/solbero/pytonskript/vm"
 )
 
-func Start(in io.Reader, out io.Writer) {
+// Start runs the program read from in. The program is read in dialect unless
+// it starts with a dialect directive of its own. If optimize is set, the
+// optimizer runs on the program first.
+func Start(in io.Reader, out io.Writer, dialect *token.Dialect, optimize bool) {
+	program, dialect, ok := parse(in, out, dialect, optimize)
+	if !ok {
+		return
+	}
+
 	env := object.NewEnvironment()
+	env.SetDialect(dialect)
+	evaluated := evaluator.Eval(program, env)
+	if errObj, ok := evaluated.(*object.Error); ok {
+		io.WriteString(out, errObj.Inspect()+"\n")
+	}
+}
+
+// StartVM runs the program read from in, as Start does, but compiles it to
+// bytecode and runs it in the virtual machine.
+func StartVM(in io.Reader, out io.Writer, dialect *token.Dialect, optimize bool) {
+	program, dialect, ok := parse(in, out, dialect, optimize)
+	if !ok {
+		return
+	}
+
+	comp := compiler.New(dialect)
+	if err := comp.Compile(program); err != nil {
+		io.WriteString(out, err.Error()+"\n")
+		return
+	}
+
+	run(comp.Bytecode(), out)
+}
+
+// StartBytecode runs a program compiled with `pytonskript bygg`. It fails if
+// in does not hold a valid bytecode file.
+func StartBytecode(in io.Reader, out io.Writer) error {
+	bc, err := bytecode.Read(in)
+	if err != nil {
+		return err
+	}
+
+	run(bc, out)
+	return nil
+}
+
+func run(bc *compiler.Bytecode, out io.Writer) {
+	machine := vm.New(bc)
+	if err := machine.Run(); err != nil {
+		if errObj, ok := err.(*object.Error); ok {
+			io.WriteString(out, errObj.Inspect()+"\n")
+		} else {
+			io.WriteString(out, err.Error()+"\n")
+		}
+	}
+}
+
+// parse reads and parses the program in in, expands its macros and optimizes
+// it if optimize is set, printing any syntax errors or errors in the macros.
+// It returns the dialect the program is written in.
+func parse(in io.Reader, out io.Writer, dialect *token.Dialect, optimize bool) (*ast.Program, *token.Dialect, bool) {
 	bytes, err := readContents(in)
 	if err != nil {
 		panic(err)
 	}
 
 	s := string(bytes)
-	l := lexer.New(s)
+	l := lexer.NewWithDialect(s, dialect)
 	p := parser.New(l)
 
 	program := p.ParseProgram()
 	if len(p.Errors()) != 0 {
 		printParserErrors(out, p.Errors())
-		return
+		return nil, nil, false
+	}
+
+	program, errObj := evaluator.ExpandProgram(program, l.Dialect())
+	if errObj != nil {
+		io.WriteString(out, errObj.Inspect()+"\n")
+		return nil, nil, false
+	}
+
+	if optimize {
+		program = optimizer.Optimize(program, l.Dialect())
 	}
 
-	evaluator.Eval(program, env)
+	return program, l.Dialect(), true
 }
 
-func printParserErrors(out io.Writer, errors []string) {
-	io.WriteString(out, "parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+func printParserErrors(out io.Writer, errors []*diagnostic.Diagnostic) {
+	io.WriteString(out, diagnostic.Sprintf(diagnostic.LabelSyntaxError)+"\n")
+	for _, err := range errors {
+		io.WriteString(out, "\t"+err.Error()+"\n")
 	}
 }
 
@@ -0,0 +1,69 @@
+// format.go
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/solbero/pytonskript/formatter"
+	"github.com/solbero/pytonskript/token"
+)
+
+// formatCommand implements `pytonskript formater [--skriv | --sjekk] fil...`,
+// which prints the files in the canonical layout, rewrites them with --skriv
+// or lists the files that are not formatted with --sjekk.
+func formatCommand(args []string, dialect *token.Dialect) int {
+	flags := flag.NewFlagSet("formater", flag.ContinueOnError)
+	write := flags.Bool("skriv", false, "skriv resultatet tilbake til filene")
+	check := flags.Bool("sjekk", false, "avslutt med feilkode hvis en fil ikke er formatert")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 || (*write && *check) {
+		fmt.Fprintf(os.Stderr, "bruk: pytonskript formater [--skriv | --sjekk] fil...\n")
+		return 2
+	}
+
+	status := 0
+
+	for _, path := range flags.Args() {
+		input, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+
+		formatted, errors := formatter.Format(string(input), dialect)
+		if len(errors) != 0 {
+			for _, err := range errors {
+				fmt.Fprintf(os.Stderr, "%s:%s\n", path, err)
+			}
+			status = 1
+			continue
+		}
+
+		switch {
+		case *check:
+			if formatted != string(input) {
+				fmt.Println(path)
+				status = 1
+			}
+		case *write:
+			if formatted == string(input) {
+				continue
+			}
+			if err := os.WriteFile(path, []byte(formatted), 0644); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				status = 1
+			}
+		default:
+			fmt.Print(formatted)
+		}
+	}
+
+	return status
+}
@@ -0,0 +1,391 @@
+// formatter/formatter.go
+
+package formatter
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/solbero/pytonskript/ast"
+	"github.com/solbero/pytonskript/diagnostic"
+	"github.com/solbero/pytonskript/lexer"
+	"github.com/solbero/pytonskript/parser"
+	"github.com/solbero/pytonskript/token"
+)
+
+const indentation = "  "
+
+// Format parses input and prints it back in the canonical layout: one
+// statement per line, blocks indented by two spaces, single spaces around
+// operators and no more parentheses than needed. Comments are kept, and so
+// are single blank lines between statements.
+//
+// Blocks written on one line with at most one statement, such as
+// `funksjon(x) { x * 2 }`, stay on one line.
+func Format(input string, dialect *token.Dialect) (string, []*diagnostic.Diagnostic) {
+	l := lexer.NewWithDialect(input, dialect)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return "", p.Errors()
+	}
+
+	pr := &printer{
+		dialect:  l.Dialect(),
+		lines:    strings.Split(input, "\n"),
+		comments: l.Comments(),
+	}
+	pr.program(program)
+
+	return pr.out.String(), nil
+}
+
+type printer struct {
+	out     bytes.Buffer
+	dialect *token.Dialect
+	lines   []string // the source, to find blank lines
+
+	comments []token.Token
+	next     int    // index of the next comment to print
+	trailing string // comment to print at the end of the current line
+
+	indent       int
+	atBlockStart bool // nothing printed in the current block yet
+}
+
+func (p *printer) program(program *ast.Program) {
+	p.atBlockStart = true
+	p.statements(program.Statements, len(p.lines)+1)
+}
+
+// statements prints stmts one per line, followed by the comments before
+// endLine.
+func (p *printer) statements(stmts []ast.Statement, endLine int) {
+	for i, stmt := range stmts {
+		line := statementLine(stmt)
+		p.commentsBefore(line)
+		p.blankLineBefore(line)
+
+		sharesLine := i+1 < len(stmts) && statementLine(stmts[i+1]) == line
+		if !sharesLine {
+			p.trailingCommentOn(line)
+		}
+
+		p.writeIndent()
+		p.statement(stmt)
+		p.newline()
+		p.atBlockStart = false
+	}
+
+	p.commentsBefore(endLine)
+}
+
+// commentsBefore prints the comments that start before line on lines of
+// their own.
+func (p *printer) commentsBefore(line int) {
+	for p.next < len(p.comments) && p.comments[p.next].Pos.Line < line {
+		comment := p.comments[p.next]
+		p.next++
+
+		p.blankLineBefore(comment.Pos.Line)
+		p.writeIndent()
+		p.out.WriteString(comment.Literal)
+		p.newline()
+		p.atBlockStart = false
+	}
+}
+
+// trailingCommentOn sets the comment on line, if any, to be printed at the
+// end of the next line.
+func (p *printer) trailingCommentOn(line int) {
+	if p.next < len(p.comments) && p.comments[p.next].Pos.Line == line {
+		if p.trailing != "" {
+			p.trailing += " "
+		}
+		p.trailing += p.comments[p.next].Literal
+		p.next++
+	}
+}
+
+// blankLineBefore prints a blank line if there is one before line in the
+// source, except at the start of a block.
+func (p *printer) blankLineBefore(line int) {
+	if p.atBlockStart || line < 2 || line-2 >= len(p.lines) {
+		return
+	}
+	if strings.TrimSpace(p.lines[line-2]) == "" {
+		p.out.WriteString("\n")
+	}
+}
+
+func (p *printer) writeIndent() {
+	p.out.WriteString(strings.Repeat(indentation, p.indent))
+}
+
+func (p *printer) newline() {
+	if p.trailing != "" {
+		p.out.WriteString(" " + p.trailing)
+		p.trailing = ""
+	}
+	p.out.WriteString("\n")
+}
+
+func (p *printer) keyword(t token.TokenType) string {
+	word, _ := p.dialect.Keyword(t)
+	return word
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.out.WriteString(p.keyword(token.LET) + " " + stmt.Name.Value + " = ")
+		p.expression(stmt.Value, parser.LOWEST)
+		p.out.WriteString(";")
+	case *ast.ReturnStatement:
+		p.out.WriteString(p.keyword(token.RETURN) + " ")
+		p.expression(stmt.ReturnValue, parser.LOWEST)
+		p.out.WriteString(";")
+	case *ast.ExpressionStatement:
+		p.expression(stmt.Expression, parser.LOWEST)
+		if !endsWithBlock(stmt.Expression) {
+			p.out.WriteString(";")
+		}
+	}
+}
+
+// expression prints exp, with parentheses unless it binds more tightly than
+// precedence.
+func (p *printer) expression(exp ast.Expression, precedence int) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		p.out.WriteString(exp.Value)
+	case *ast.IntegerLiteral:
+		p.out.WriteString(exp.Token.Literal)
+	case *ast.StringLiteral:
+		if exp.Token.Type == token.RAW_STRING {
+			p.out.WriteString("`" + exp.Value + "`")
+			break
+		}
+		p.out.WriteString(quote(exp.Value))
+	case *ast.Boolean:
+		if exp.Value {
+			p.out.WriteString(p.keyword(token.TRUE))
+		} else {
+			p.out.WriteString(p.keyword(token.FALSE))
+		}
+	case *ast.NullLiteral:
+		p.out.WriteString(p.keyword(token.NULL))
+	case *ast.PrefixExpression:
+		p.parenthesize(precedence >= parser.PREFIX, func() {
+			p.out.WriteString(exp.Operator)
+			p.expression(exp.Right, parser.PREFIX-1)
+		})
+	case *ast.InfixExpression:
+		operator := parser.Precedence(exp.Token.Type)
+		p.parenthesize(precedence >= operator, func() {
+			p.expression(exp.Left, operator-1)
+			p.out.WriteString(" " + exp.Operator + " ")
+			p.expression(exp.Right, operator)
+		})
+	case *ast.IfExpression:
+		p.out.WriteString(p.keyword(token.IF) + " (")
+		p.expression(exp.Condition, parser.LOWEST)
+		p.out.WriteString(") ")
+		p.block(exp.Consequence)
+		if exp.Alternative != nil {
+			p.out.WriteString(" " + p.keyword(token.ELSE) + " ")
+			p.block(exp.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		p.function(token.FUNCTION, exp.Parameters, exp.Body)
+	case *ast.MacroLiteral:
+		p.function(token.MACRO, exp.Parameters, exp.Body)
+	case *ast.CallExpression:
+		p.expression(exp.Function, parser.CALL-1)
+		p.out.WriteString("(")
+		p.expressionList(exp.Arguments)
+		p.out.WriteString(")")
+	case *ast.IndexExpression:
+		p.expression(exp.Left, parser.INDEX-1)
+		if exp.Token.Type == token.OPTIONAL_LBRACKET {
+			p.out.WriteString("?")
+		}
+		p.out.WriteString("[")
+		p.expression(exp.Index, parser.LOWEST)
+		p.out.WriteString("]")
+	case *ast.InterpolatedString:
+		p.out.WriteString(`"`)
+		for i, part := range exp.Parts {
+			if text, ok := part.(*ast.StringLiteral); ok && i%2 == 0 {
+				p.out.WriteString(escapes.Replace(text.Value))
+				continue
+			}
+			p.out.WriteString("{")
+			p.expression(part, parser.LOWEST)
+			p.out.WriteString("}")
+		}
+		p.out.WriteString(`"`)
+	case *ast.ArrayLiteral:
+		p.out.WriteString("[")
+		p.expressionList(exp.Elements)
+		p.out.WriteString("]")
+	case *ast.HashLiteral:
+		p.out.WriteString("{")
+		for i, pair := range exp.Pairs {
+			if i > 0 {
+				p.out.WriteString(", ")
+			}
+			p.expression(pair.Key, parser.LOWEST)
+			p.out.WriteString(": ")
+			p.expression(pair.Value, parser.LOWEST)
+		}
+		p.out.WriteString("}")
+	}
+}
+
+// parenthesize prints the output of print in parentheses if needed is true.
+func (p *printer) parenthesize(needed bool, print func()) {
+	if needed {
+		p.out.WriteString("(")
+	}
+	print()
+	if needed {
+		p.out.WriteString(")")
+	}
+}
+
+func (p *printer) expressionList(exps []ast.Expression) {
+	for i, exp := range exps {
+		if i > 0 {
+			p.out.WriteString(", ")
+		}
+		p.expression(exp, parser.LOWEST)
+	}
+}
+
+func (p *printer) block(block *ast.BlockStatement) {
+	if isOneLine(block) {
+		if len(block.Statements) == 0 {
+			p.out.WriteString("{}")
+			return
+		}
+		p.out.WriteString("{ ")
+		p.inlineStatement(block.Statements[0])
+		p.out.WriteString(" }")
+		return
+	}
+
+	p.out.WriteString("{")
+	p.newline()
+
+	p.indent++
+	p.atBlockStart = true
+	p.statements(block.Statements, block.Rbrace.Pos.Line)
+	p.indent--
+
+	p.writeIndent()
+	p.out.WriteString("}")
+	p.trailingCommentOn(block.Rbrace.Pos.Line)
+}
+
+// inlineStatement prints stmt inside a block on one line, where the
+// semicolon after an expression is left out.
+func (p *printer) inlineStatement(stmt ast.Statement) {
+	if stmt, ok := stmt.(*ast.ExpressionStatement); ok {
+		p.expression(stmt.Expression, parser.LOWEST)
+		return
+	}
+	p.statement(stmt)
+}
+
+// function prints a function or macro literal, which starts with keyword.
+func (p *printer) function(keyword token.TokenType, parameters []*ast.Identifier, body *ast.BlockStatement) {
+	params := []string{}
+	for _, param := range parameters {
+		params = append(params, param.Value)
+	}
+	p.out.WriteString(p.keyword(keyword) + "(" + strings.Join(params, ", ") + ") ")
+	p.block(body)
+}
+
+// isOneLine reports whether block was written on one line with at most one
+// statement.
+func isOneLine(block *ast.BlockStatement) bool {
+	return len(block.Statements) <= 1 && block.Token.Pos.Line == block.Rbrace.Pos.Line
+}
+
+// endsWithBlock reports whether exp is printed with a block at the end, in
+// which case it needs no semicolon when it is a statement of its own.
+func endsWithBlock(exp ast.Expression) bool {
+	switch exp.(type) {
+	case *ast.IfExpression, *ast.FunctionLiteral, *ast.MacroLiteral:
+		return true
+	default:
+		return false
+	}
+}
+
+func statementLine(stmt ast.Statement) int {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token.Pos.Line
+	case *ast.ReturnStatement:
+		return stmt.Token.Pos.Line
+	case *ast.ExpressionStatement:
+		return stmt.Token.Pos.Line
+	default:
+		return 0
+	}
+}
+
+// startPos returns the position of the first token of exp.
+func startPos(exp ast.Expression) token.Position {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return startPos(exp.Left)
+	case *ast.CallExpression:
+		return startPos(exp.Function)
+	case *ast.IndexExpression:
+		return startPos(exp.Left)
+	case *ast.Identifier:
+		return exp.Token.Pos
+	case *ast.IntegerLiteral:
+		return exp.Token.Pos
+	case *ast.StringLiteral:
+		return exp.Token.Pos
+	case *ast.InterpolatedString:
+		return exp.Token.Pos
+	case *ast.Boolean:
+		return exp.Token.Pos
+	case *ast.NullLiteral:
+		return exp.Token.Pos
+	case *ast.PrefixExpression:
+		return exp.Token.Pos
+	case *ast.IfExpression:
+		return exp.Token.Pos
+	case *ast.FunctionLiteral:
+		return exp.Token.Pos
+	case *ast.MacroLiteral:
+		return exp.Token.Pos
+	case *ast.ArrayLiteral:
+		return exp.Token.Pos
+	case *ast.HashLiteral:
+		return exp.Token.Pos
+	default:
+		return token.Position{}
+	}
+}
+
+var escapes = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\n", `\n`,
+	"\t", `\t`,
+	"\r", `\r`,
+	"{", `\{`,
+)
+
+func quote(s string) string {
+	return `"` + escapes.Replace(s) + `"`
+}
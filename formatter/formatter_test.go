@@ -0,0 +1,146 @@
+// formatter/formatter_test.go
+
+package formatter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/solbero/pytonskript/token"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"la   x=5", "la x = 5;\n"},
+		{"la x = 5; la y = x;x+y", "la x = 5;\nla y = x;\nx + y;\n"},
+		{"returner   sant", "returner sant;\n"},
+		{"(1 + 2) * 3", "(1 + 2) * 3;\n"},
+		{"1 + (2 * 3)", "1 + 2 * 3;\n"},
+		{"(1 - 2) - 3", "1 - 2 - 3;\n"},
+		{"1 - (2 - 3)", "1 - (2 - 3);\n"},
+		{"-(1 + 2)", "-(1 + 2);\n"},
+		{"!(-a)", "!-a;\n"},
+		{"(-f)(x)", "(-f)(x);\n"},
+		{"((a < b)) == (c > d)", "a < b == c > d;\n"},
+		{"a * [1,2,3][b*c] * d", "a * [1, 2, 3][b * c] * d;\n"},
+		{"(a i b) == ((c < d) i e)", "a i b == c < d i e;\n"},
+		{"(a == b) i c", "(a == b) i c;\n"},
+		{`"a{ b+1 }\{c\}{ {"d":"e{f}"} }"`, `"a{b + 1}\{c}{{"d": "e{f}"}}";` + "\n"},
+		{`"\{}"`, `"\{}";` + "\n"},
+		{"a??(b??c)", "a ?? (b ?? c);\n"},
+		{"(a ?? b) == c", "(a ?? b) == c;\n"},
+		{"(a?[1])?[ingenting]", "a?[1]?[ingenting];\n"},
+		{`"bl\u{e5}"`, `"blå";` + "\n"},
+		{"la  m = `a\\n{b}\n  \"c\"`", "la m = `a\\n{b}\n  \"c\"`;\n"},
+		{`f( "hei \"du\"\n",{"a":1,  "b" : 2} )`, `f("hei \"du\"\n", {"a": 1, "b": 2});` + "\n"},
+		{"funksjon(x){x*2}(4)", "funksjon(x) { x * 2 }(4);\n"},
+		{"la f = funksjon() {}", "la f = funksjon() {};\n"},
+		{"la m=makro(a){sitat(avsitat(a)*2)}", "la m = makro(a) { sitat(avsitat(a) * 2) };\n"},
+		{
+			"hvis (x) { 1 } ellers {\n2 }",
+			"hvis (x) { 1 } ellers {\n  2;\n}\n",
+		},
+		{
+			"la f = funksjon(a,b) {\n\tla c = a + b\n\n\n\treturner c }",
+			"la f = funksjon(a, b) {\n  la c = a + b;\n\n  returner c;\n};\n",
+		},
+		{
+			"# dialekt: nynorsk\nlat x = viss (sann) { usann } elles { sann }",
+			"# dialekt: nynorsk\nlat x = viss (sann) { usann } elles { sann };\n",
+		},
+	}
+
+	for _, tt := range tests {
+		actual, errors := Format(tt.input, token.Bokmal)
+		if len(errors) != 0 {
+			t.Errorf("unexpected errors formatting %q: %v", tt.input, errors)
+			continue
+		}
+
+		if actual != tt.expected {
+			t.Errorf("format wrong for %q:\nexpected %q\ngot      %q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestFormatComments(t *testing.T) {
+	input := `# Første kommentar
+
+# Om x
+la x = 1  # en
+la f = funksjon(n) {   # funksjon
+    # inni
+
+  hvis (n) {
+        n # n
+        # etter n
+  } # slutt på hvis
+
+  # før slutten
+}; # slutt på f
+
+   # helt til slutt
+`
+
+	expected := `# Første kommentar
+
+# Om x
+la x = 1; # en
+la f = funksjon(n) { # funksjon
+  # inni
+
+  hvis (n) {
+    n; # n
+    # etter n
+  } # slutt på hvis
+
+  # før slutten
+}; # slutt på f
+
+# helt til slutt
+`
+
+	actual, errors := Format(input, token.Bokmal)
+	if len(errors) != 0 {
+		t.Fatalf("unexpected errors: %v", errors)
+	}
+
+	if actual != expected {
+		t.Errorf("format wrong:\nexpected %q\ngot      %q", expected, actual)
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	_, errors := Format("la = 5", token.Bokmal)
+	if len(errors) == 0 {
+		t.Errorf("expected errors for invalid program")
+	}
+}
+
+func TestFormatExamples(t *testing.T) {
+	paths, err := filepath.Glob("../examples/*.pytonskript")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range paths {
+		input, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		formatted, errors := Format(string(input), token.Bokmal)
+		if len(errors) != 0 {
+			t.Errorf("%s: unexpected errors: %v", path, errors)
+			continue
+		}
+
+		if formatted != string(input) {
+			t.Errorf("%s is not formatted", path)
+		}
+	}
+}
@@ -4,13 +4,28 @@ package lexer
 
 import (
 	"bytes"
+	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 
+	"github.com/solbero/pytonskript/diagnostic"
 	"github.com/solbero/pytonskript/token"
 )
 
+// New creates a lexer for input. The keywords are bokmål unless the input
+// starts with a dialect directive.
 func New(input string) *Lexer {
-	l := &Lexer{input: []rune(input)}
+	return NewWithDialect(input, token.Bokmal)
+}
+
+// NewWithDialect creates a lexer for input using the keywords of dialect,
+// unless the input starts with a dialect directive such as
+//
+//	# dialekt: nynorsk
+func NewWithDialect(input string, dialect *token.Dialect) *Lexer {
+	l := &Lexer{input: []rune(input), line: 1, dialect: dialect}
+	l.readDirective()
 	l.readChar()
 	return l
 }
@@ -19,18 +34,69 @@ func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
+// interpolation is an expression in a string. braces counts the '{' in the
+// expression that are not closed yet, and a '}' when it is 0 ends the
+// expression. start is the '{' that starts it.
+type interpolation struct {
+	braces int
+	start  token.Position
+}
+
 type Lexer struct {
 	input        []rune
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
 	ch           rune // current char
+	line         int  // line of current char
+	column       int  // column of current char
+
+	// interpolations has an entry for each expression in a string being
+	// read, innermost last.
+	interpolations []interpolation
+
+	// illegal is set when the token being read has an error, which has
+	// been added to errors.
+	illegal bool
+
+	// last is the type of the token read before the one being read.
+	last token.TokenType
+
+	dialect  *token.Dialect
+	errors   []*diagnostic.Diagnostic
+	comments []token.Token
+}
+
+// Dialect returns the dialect the input is read in.
+func (l *Lexer) Dialect() *token.Dialect {
+	return l.dialect
+}
+
+// Comments returns the comments skipped so far, in the order they appear.
+func (l *Lexer) Comments() []token.Token {
+	return l.comments
+}
+
+// Errors returns the problems found so far, such as an unknown dialect in
+// the directive or a string that is never ended. A token with a problem is
+// read as ILLEGAL.
+func (l *Lexer) Errors() []*diagnostic.Diagnostic {
+	return l.errors
 }
 
 func (l *Lexer) NextToken() token.Token {
+	tok := l.nextToken()
+	l.last = tok.Type
+	return tok
+}
+
+func (l *Lexer) nextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
 
+	pos := l.currentPosition()
+	l.illegal = false
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -54,6 +120,17 @@ func (l *Lexer) NextToken() token.Token {
 		} else {
 			tok = newToken(token.BANG, l.ch)
 		}
+	case '?':
+		switch l.peekChar() {
+		case '?':
+			l.readChar()
+			tok = token.Token{Type: token.NULLISH, Literal: "??"}
+		case '[':
+			l.readChar()
+			tok = token.Token{Type: token.OPTIONAL_LBRACKET, Literal: "?["}
+		default:
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case '/':
 		tok = newToken(token.SLASH, l.ch)
 	case '*':
@@ -73,38 +150,99 @@ func (l *Lexer) NextToken() token.Token {
 	case ')':
 		tok = newToken(token.RPAREN, l.ch)
 	case '{':
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1].braces++
+		}
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
+		n := len(l.interpolations)
+		if n > 0 && l.interpolations[n-1].braces == 0 {
+			l.interpolations = l.interpolations[:n-1]
+			var more bool
+			tok.Literal, more = l.readString(pos)
+			tok.Type = token.STRING_END
+			if more {
+				tok.Type = token.STRING_MIDDLE
+				l.interpolations = append(l.interpolations, interpolation{start: l.currentPosition()})
+			}
+			if l.illegal {
+				tok.Type = token.ILLEGAL
+			}
+			break
+		}
+		if n > 0 {
+			l.interpolations[n-1].braces--
+		}
 		tok = newToken(token.RBRACE, l.ch)
 	case '[':
 		tok = newToken(token.LBRACKET, l.ch)
 	case ']':
 		tok = newToken(token.RBRACKET, l.ch)
 	case '"':
+		var more bool
+		tok.Literal, more = l.readString(pos)
 		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		if more {
+			tok.Type = token.STRING_START
+			l.interpolations = append(l.interpolations, interpolation{start: l.currentPosition()})
+		}
+		if l.illegal {
+			tok.Type = token.ILLEGAL
+		}
+	case '`':
+		tok.Literal = l.readRawString(pos)
+		tok.Type = token.RAW_STRING
+		if l.illegal {
+			tok.Type = token.ILLEGAL
+		}
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
+		l.unclosedInterpolation()
 	default:
 		if isLetter(l.ch) {
 			tok.Literal = string(l.readIdentifier())
-			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Type = l.dialect.LookupIdent(tok.Literal)
+			if tok.Type == token.IN && !endsOperand(l.last) {
+				tok.Type = token.IDENT
+			}
+			tok.Pos = pos
 			return tok
 		} else if isDigit(l.ch) {
 			tok.Type = token.INT
 			tok.Literal = string(l.readNumber())
+			tok.Pos = pos
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
 		}
 	}
 
+	tok.Pos = pos
 	l.readChar()
 	return tok
 }
 
+// endsOperand reports whether a token of type t can be the last token of an
+// operand. The keyword i is only the membership operator after one, as in
+// x i liste, and is a name everywhere else, as in la i = 0 and liste[i].
+func endsOperand(t token.TokenType) bool {
+	switch t {
+	case token.IDENT, token.INT, token.STRING, token.STRING_END, token.RAW_STRING,
+		token.TRUE, token.FALSE, token.NULL, token.RPAREN, token.RBRACKET, token.RBRACE:
+		return true
+	default:
+		return false
+	}
+}
+
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+	l.column += 1
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -139,38 +277,133 @@ func (l *Lexer) readNumber() []rune {
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readString() string {
+// readString reads the text of a string from after the current character,
+// which is the '"' that starts the string or the '}' that ends an expression
+// in it. It stops at the '"' that ends the string, or at a '{' that starts an
+// expression, and then reports that there is more to the string. start is
+// where the token began, for the error if the string is never ended.
+func (l *Lexer) readString(start token.Position) (string, bool) {
 	buff := bytes.Buffer{}
-	l.readChar() // skip the first '"'
+	l.readChar() // skip the '"' or '}'
 
 	for {
-		if l.ch == '\\' { // skip the escape character
-			switch l.peekChar() {
-			case '"':
-				buff.WriteByte('"')
-			case 'n':
-				buff.WriteByte('\n')
-			case 't':
-				buff.WriteByte('\t')
-			case 'r':
-				buff.WriteByte('\r')
-			case '\\':
-				buff.WriteByte('\\')
+		switch l.ch {
+		case '"':
+			return buff.String(), false
+		case '{':
+			return buff.String(), true
+		case 0:
+			if !l.unclosedInterpolation() {
+				l.error(diagnostic.UnterminatedString, start, `'"'`)
 			}
-			l.readChar()
-			l.readChar()
+			return buff.String(), false
+		case '\\':
+			l.readEscape(&buff)
 			continue
 		}
 
-		if l.ch == '"' || l.ch == 0 {
-			break
-		}
+		buff.WriteRune(l.ch)
+		l.readChar()
+	}
+}
+
+// unclosedInterpolation reports the innermost expression in a string that is
+// still open at the end of the input, if there is one. The '{' that starts it
+// was more likely meant as text than the string after it was meant to run to
+// the end, as in "{", so that is what is reported.
+func (l *Lexer) unclosedInterpolation() bool {
+	n := len(l.interpolations)
+	if n == 0 {
+		return false
+	}
+
+	l.error(diagnostic.UnclosedInterpolation, l.interpolations[n-1].start)
+	l.interpolations = nil
+	return true
+}
 
+// readEscape reads the escape sequence starting at the current '\' and
+// writes the character it stands for to buff.
+func (l *Lexer) readEscape(buff *bytes.Buffer) {
+	pos := l.currentPosition()
+	l.readChar() // skip the '\'
+
+	switch l.ch {
+	case '"', '\\', '{', '}':
 		buff.WriteRune(l.ch)
+	case 'n':
+		buff.WriteByte('\n')
+	case 't':
+		buff.WriteByte('\t')
+	case 'r':
+		buff.WriteByte('\r')
+	case 'u':
+		l.readUnicodeEscape(pos, buff)
+		return
+	case 0:
+		return // the string is not ended, which readString reports
+	default:
+		l.error(diagnostic.UnknownEscape, pos, `\`+string(l.ch))
+	}
+	l.readChar()
+}
+
+// readUnicodeEscape reads the rest of an escape such as \u{e5}, from the
+// 'u', and writes the character with that code point to buff.
+func (l *Lexer) readUnicodeEscape(pos token.Position, buff *bytes.Buffer) {
+	start := l.position - 1 // the '\'
+	l.readChar()            // skip the 'u'
+
+	if l.ch != '{' {
+		l.error(diagnostic.InvalidUnicodeEscape, pos, string(l.input[start:l.position]))
+		return
+	}
+	l.readChar()
+
+	digits := l.position
+	for isHexDigit(l.ch) {
 		l.readChar()
 	}
+	hex := string(l.input[digits:l.position])
+
+	if l.ch != '}' {
+		l.error(diagnostic.InvalidUnicodeEscape, pos, string(l.input[start:l.position]))
+		return
+	}
+	l.readChar()
 
-	return buff.String()
+	code, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		l.error(diagnostic.InvalidUnicodeEscape, pos, string(l.input[start:l.position]))
+		return
+	}
+	buff.WriteRune(rune(code))
+}
+
+// readRawString reads a string between backticks. It can span several lines
+// and is taken as it is, without escapes or expressions.
+func (l *Lexer) readRawString(start token.Position) string {
+	l.readChar() // skip the '`'
+	position := l.position
+
+	for l.ch != '`' {
+		if l.ch == 0 {
+			l.error(diagnostic.UnterminatedString, start, "'`'")
+			break
+		}
+		l.readChar()
+	}
+
+	return strings.ReplaceAll(string(l.input[position:l.position]), "\r\n", "\n")
+}
+
+func (l *Lexer) error(code diagnostic.Code, pos token.Position, a ...interface{}) {
+	l.errors = append(l.errors, diagnostic.New(code, pos, a...))
+	l.illegal = true
+}
+
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{Offset: l.position, Line: l.line, Column: l.column}
 }
 
 func isLetter(ch rune) bool {
@@ -181,8 +414,98 @@ func isDigit(ch rune) bool {
 	return unicode.IsNumber(ch)
 }
 
+func isHexDigit(ch rune) bool {
+	return '0' <= ch && ch <= '9' || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+	for {
+		switch l.ch {
+		case ' ', '\t', '\n', '\r':
+			l.readChar()
+		case '#':
+			l.skipComment()
+		default:
+			return
+		}
+	}
+}
+
+func (l *Lexer) skipComment() {
+	pos := l.currentPosition()
+	position := l.position
+
+	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
 	}
+
+	literal := strings.TrimRight(string(l.input[position:l.position]), " \t\r")
+	l.comments = append(l.comments, token.Token{Type: token.COMMENT, Literal: literal, Pos: pos})
+}
+
+// readDirective switches to the dialect named in the directive at the start
+// of the input, if there is one.
+func (l *Lexer) readDirective() {
+	directive, ok := FindDirective(string(l.input))
+	if !ok {
+		return
+	}
+
+	dialect, ok := token.LookupDialect(directive.Name)
+	if !ok {
+		l.errors = append(l.errors, diagnostic.New(diagnostic.UnknownDialect, directive.Pos, directive.Name))
+		return
+	}
+
+	l.dialect = dialect
+}
+
+// Directive is a comment such as "# dialekt: nynorsk" among the comments at
+// the start of a program.
+type Directive struct {
+	Pos  token.Position // position of the '#'
+	Name string         // the dialect named by the directive
+
+	// NameStart and NameEnd are the character offsets of Name in the input.
+	NameStart int
+	NameEnd   int
+}
+
+// FindDirective looks for a dialect directive among the comments before the
+// first token of input.
+func FindDirective(input string) (Directive, bool) {
+	offset := 0
+
+	for i, line := range strings.Split(input, "\n") {
+		lineStart := offset
+		offset += len([]rune(line)) + 1
+
+		text := strings.TrimSpace(line)
+		if text == "" {
+			continue
+		}
+		if !strings.HasPrefix(text, "#") {
+			break
+		}
+
+		comment := strings.TrimSpace(strings.TrimPrefix(text, "#"))
+		for _, keyword := range []string{"dialekt:", "dialect:"} {
+			if !strings.HasPrefix(strings.ToLower(comment), keyword) {
+				continue
+			}
+
+			name := strings.TrimSpace(comment[len(keyword):])
+			column := len([]rune(line[:strings.Index(line, "#")])) + 1
+			nameStart := lineStart + len([]rune(line[:strings.LastIndex(line, name)]))
+
+			return Directive{
+				Pos:       token.Position{Offset: lineStart + column - 1, Line: i + 1, Column: column},
+				Name:      name,
+				NameStart: nameStart,
+				NameEnd:   nameStart + len([]rune(name)),
+			}, true
+		}
+	}
+
+	return Directive{}, false
 }
@@ -128,7 +128,7 @@ la fem_fem = 55
 		{token.STRING, "hello\nworld"},
 		{token.STRING, "hello\t\t\tworld"},
 		{token.STRING, "hello\\world"},
-		{token.STRING, "helloworld"},
+		{token.ILLEGAL, "helloworld"},
 		{token.LBRACKET, "["},
 		{token.INT, "1"},
 		{token.COMMA, ","},
@@ -166,3 +166,355 @@ la fem_fem = 55
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := `la x = 5;
+  hvis (x) {
+	"blåbær" + y
+}`
+
+	tests := []struct {
+		expectedType token.TokenType
+		expectedLine int
+		expectedCol  int
+	}{
+		{token.LET, 1, 1},
+		{token.IDENT, 1, 4},
+		{token.ASSIGN, 1, 6},
+		{token.INT, 1, 8},
+		{token.SEMICOLON, 1, 9},
+		{token.IF, 2, 3},
+		{token.LPAREN, 2, 8},
+		{token.IDENT, 2, 9},
+		{token.RPAREN, 2, 10},
+		{token.LBRACE, 2, 12},
+		{token.STRING, 3, 2},
+		{token.PLUS, 3, 11},
+		{token.IDENT, 3, 13},
+		{token.RBRACE, 4, 1},
+		{token.EOF, 4, 2},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong: expected %q got %q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedCol {
+			t.Fatalf("tests[%d] - position wrong: expected %d:%d got %d:%d",
+				i, tt.expectedLine, tt.expectedCol, tok.Pos.Line, tok.Pos.Column)
+		}
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	input := `"a{b + {"c": "{d}"}["c"]} e\{f\}" "{}"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedCol     int
+	}{
+		{token.STRING_START, "a", 1},
+		{token.IDENT, "b", 4},
+		{token.PLUS, "+", 6},
+		{token.LBRACE, "{", 8},
+		{token.STRING, "c", 9},
+		{token.COLON, ":", 12},
+		{token.STRING_START, "", 14},
+		{token.IDENT, "d", 16},
+		{token.STRING_END, "", 17},
+		{token.RBRACE, "}", 19},
+		{token.LBRACKET, "[", 20},
+		{token.STRING, "c", 21},
+		{token.RBRACKET, "]", 24},
+		{token.STRING_END, " e{f}", 25},
+		{token.STRING_START, "", 35},
+		{token.STRING_END, "", 37},
+		{token.EOF, "", 39},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token: expected %q %q got %q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+
+		if tok.Pos.Column != tt.expectedCol {
+			t.Fatalf("tests[%d] - column wrong: expected %d got %d", i, tt.expectedCol, tok.Pos.Column)
+		}
+	}
+}
+
+func TestUnclosedInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`skriv("{")`, `1:8: uttrykket i strengen blir aldri avsluttet med '}', skriv \{ for en krøllparentes i teksten [S008]`},
+		{`"a{1 + 2`, `1:3: uttrykket i strengen blir aldri avsluttet med '}', skriv \{ for en krøllparentes i teksten [S008]`},
+		{`"a{b}c{d"`, `1:7: uttrykket i strengen blir aldri avsluttet med '}', skriv \{ for en krøllparentes i teksten [S008]`},
+		{`"a{"b{c}"`, `1:3: uttrykket i strengen blir aldri avsluttet med '}', skriv \{ for en krøllparentes i teksten [S008]`},
+		{`"a{"b`, `1:3: uttrykket i strengen blir aldri avsluttet med '}', skriv \{ for en krøllparentes i teksten [S008]`},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		errors := l.Errors()
+		if len(errors) != 1 {
+			t.Errorf("%s: expected 1 error, got %v", tt.input, errors)
+			continue
+		}
+		if errors[0].Error() != tt.expected {
+			t.Errorf("%s: wrong error, expected %q got %q", tt.input, tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestContextualIn(t *testing.T) {
+	input := `la i = 0; [i] i f(i) i "{i}" i i`
+
+	expected := []token.TokenType{
+		token.LET, token.IDENT, token.ASSIGN, token.INT, token.SEMICOLON,
+		token.LBRACKET, token.IDENT, token.RBRACKET, token.IN,
+		token.IDENT, token.LPAREN, token.IDENT, token.RPAREN, token.IN,
+		token.STRING_START, token.IDENT, token.STRING_END, token.IN,
+		token.IDENT, token.EOF,
+	}
+
+	l := New(input)
+
+	for i, tokenType := range expected {
+		if tok := l.NextToken(); tok.Type != tokenType {
+			t.Fatalf("tests[%d] - wrong type: expected %q got %q (%q)", i, tokenType, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestStringForms(t *testing.T) {
+	input := "\"\\u{e5}\\u{1F600}\" `a\\n{b}\r\n  \"c\"` x \"\\q\" `d"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedCol     int
+	}{
+		{token.STRING, "å\U0001F600", 1, 1},
+		{token.RAW_STRING, "a\\n{b}\n  \"c\"", 1, 19},
+		{token.IDENT, "x", 2, 8},
+		{token.ILLEGAL, "", 2, 10},
+		{token.ILLEGAL, "d", 2, 15},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token: expected %q %q got %q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedCol {
+			t.Fatalf("tests[%d] - position wrong: expected %d:%d got %d:%d",
+				i, tt.expectedLine, tt.expectedCol, tok.Pos.Line, tok.Pos.Column)
+		}
+	}
+
+	expected := []string{
+		`2:11: ukjent escape-sekvens i strengen: \q [S005]`,
+		"2:15: strengen blir aldri avsluttet, mangler '`' [S007]",
+	}
+	if len(l.Errors()) != len(expected) {
+		t.Fatalf("wrong number of errors, expected %d got %d", len(expected), len(l.Errors()))
+	}
+	for i, err := range l.Errors() {
+		if err.Error() != expected[i] {
+			t.Errorf("errors[%d] wrong: expected %q got %q", i, expected[i], err.Error())
+		}
+	}
+}
+
+func TestDialects(t *testing.T) {
+	tests := []struct {
+		input    string
+		dialect  *token.Dialect
+		expected []token.Token
+	}{
+		{
+			input:   "lat x = viss (sann) { usann } elles { returner x }",
+			dialect: token.Nynorsk,
+			expected: []token.Token{
+				{Type: token.LET, Literal: "lat"},
+				{Type: token.IDENT, Literal: "x"},
+				{Type: token.ASSIGN, Literal: "="},
+				{Type: token.IF, Literal: "viss"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.TRUE, Literal: "sann"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.LBRACE, Literal: "{"},
+				{Type: token.FALSE, Literal: "usann"},
+				{Type: token.RBRACE, Literal: "}"},
+				{Type: token.ELSE, Literal: "elles"},
+				{Type: token.LBRACE, Literal: "{"},
+				{Type: token.RETURN, Literal: "returner"},
+				{Type: token.IDENT, Literal: "x"},
+				{Type: token.RBRACE, Literal: "}"},
+			},
+		},
+		{
+			input:   "let m = macro() { quote(1) }",
+			dialect: token.English,
+			expected: []token.Token{
+				{Type: token.LET, Literal: "let"},
+				{Type: token.IDENT, Literal: "m"},
+				{Type: token.ASSIGN, Literal: "="},
+				{Type: token.MACRO, Literal: "macro"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.LBRACE, Literal: "{"},
+				{Type: token.IDENT, Literal: "quote"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.RBRACE, Literal: "}"},
+			},
+		},
+		{
+			input:   "let add = fn(x) { return true }",
+			dialect: token.English,
+			expected: []token.Token{
+				{Type: token.LET, Literal: "let"},
+				{Type: token.IDENT, Literal: "add"},
+				{Type: token.ASSIGN, Literal: "="},
+				{Type: token.FUNCTION, Literal: "fn"},
+				{Type: token.LPAREN, Literal: "("},
+				{Type: token.IDENT, Literal: "x"},
+				{Type: token.RPAREN, Literal: ")"},
+				{Type: token.LBRACE, Literal: "{"},
+				{Type: token.RETURN, Literal: "return"},
+				{Type: token.TRUE, Literal: "true"},
+				{Type: token.RBRACE, Literal: "}"},
+			},
+		},
+		{
+			input:   "x?[0] ?? null",
+			dialect: token.English,
+			expected: []token.Token{
+				{Type: token.IDENT, Literal: "x"},
+				{Type: token.OPTIONAL_LBRACKET, Literal: "?["},
+				{Type: token.INT, Literal: "0"},
+				{Type: token.RBRACKET, Literal: "]"},
+				{Type: token.NULLISH, Literal: "??"},
+				{Type: token.NULL, Literal: "null"},
+			},
+		},
+		{
+			input:   "# dialekt: nynorsk\n# ein kommentar\nlat la = sann",
+			dialect: token.Bokmal,
+			expected: []token.Token{
+				{Type: token.LET, Literal: "lat"},
+				{Type: token.IDENT, Literal: "la"},
+				{Type: token.ASSIGN, Literal: "="},
+				{Type: token.TRUE, Literal: "sann"},
+			},
+		},
+		{
+			input:   "\n  # Dialect: english\nlet x = 1 # comment\n# la\nx",
+			dialect: token.Nynorsk,
+			expected: []token.Token{
+				{Type: token.LET, Literal: "let"},
+				{Type: token.IDENT, Literal: "x"},
+				{Type: token.ASSIGN, Literal: "="},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.IDENT, Literal: "x"},
+			},
+		},
+		{
+			input:   "la x = 1\n# dialekt: nynorsk\nlat",
+			dialect: token.Bokmal,
+			expected: []token.Token{
+				{Type: token.LET, Literal: "la"},
+				{Type: token.IDENT, Literal: "x"},
+				{Type: token.ASSIGN, Literal: "="},
+				{Type: token.INT, Literal: "1"},
+				{Type: token.IDENT, Literal: "lat"},
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		l := NewWithDialect(tt.input, tt.dialect)
+
+		for j, expected := range append(tt.expected, token.Token{Type: token.EOF}) {
+			tok := l.NextToken()
+
+			if tok.Type != expected.Type {
+				t.Fatalf("tests[%d][%d] - tokentype wrong: expected %q got %q", i, j, expected.Type, tok.Type)
+			}
+
+			if tok.Literal != expected.Literal {
+				t.Fatalf("tests[%d][%d] - literal wrong: expected %q got %q", i, j, expected.Literal, tok.Literal)
+			}
+		}
+
+		if len(l.Errors()) != 0 {
+			t.Errorf("tests[%d] - unexpected errors: %v", i, l.Errors())
+		}
+	}
+}
+
+func TestUnknownDialect(t *testing.T) {
+	l := New("\n  # dialekt: klingon\nla x = 1")
+
+	if l.Dialect() != token.Bokmal {
+		t.Errorf("dialect wrong: expected %s, got %s", token.Bokmal.Name, l.Dialect().Name)
+	}
+
+	errors := l.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("wrong number of errors, expected 1, got %d", len(errors))
+	}
+
+	if errors[0].Pos.Line != 2 || errors[0].Pos.Column != 3 {
+		t.Errorf("error position wrong: expected 2:3, got %d:%d", errors[0].Pos.Line, errors[0].Pos.Column)
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := "# først\nla x = 1 # etter   \n\t#inni\nx"
+
+	l := New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+	}
+
+	expected := []token.Token{
+		{Type: token.COMMENT, Literal: "# først", Pos: token.Position{Offset: 0, Line: 1, Column: 1}},
+		{Type: token.COMMENT, Literal: "# etter", Pos: token.Position{Offset: 17, Line: 2, Column: 10}},
+		{Type: token.COMMENT, Literal: "#inni", Pos: token.Position{Offset: 29, Line: 3, Column: 2}},
+	}
+
+	comments := l.Comments()
+	if len(comments) != len(expected) {
+		t.Fatalf("wrong number of comments, expected %d, got %d", len(expected), len(comments))
+	}
+
+	for i, comment := range comments {
+		if comment != expected[i] {
+			t.Errorf("comments[%d] wrong, expected %+v, got %+v", i, expected[i], comment)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+// lsp.go
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/solbero/pytonskript/lsp"
+	"github.com/solbero/pytonskript/token"
+)
+
+// lspCommand implements `pytonskript lsp`, which runs a language server for
+// editors on stdin and stdout.
+func lspCommand(args []string, dialect *token.Dialect) int {
+	flags := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 0 {
+		fmt.Fprintf(os.Stderr, "bruk: pytonskript lsp\n")
+		return 2
+	}
+
+	if err := lsp.NewServer(os.Stdin, os.Stdout, dialect).Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
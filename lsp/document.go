@@ -0,0 +1,230 @@
+// lsp/document.go
+
+package lsp
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/solbero/pytonskript/ast"
+	"github.com/solbero/pytonskript/checker"
+	"github.com/solbero/pytonskript/diagnostic"
+	"github.com/solbero/pytonskript/lexer"
+	"github.com/solbero/pytonskript/parser"
+	"github.com/solbero/pytonskript/token"
+)
+
+// document is an open file, lexed and parsed again on every change.
+type document struct {
+	text    string
+	lines   []string
+	dialect *token.Dialect
+	tokens  []token.Token
+
+	program *ast.Program
+	errors  []*diagnostic.Diagnostic // from the parser
+}
+
+func newDocument(text string, dialect *token.Dialect) *document {
+	d := &document{text: text, lines: strings.Split(text, "\n")}
+
+	l := lexer.NewWithDialect(text, dialect)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		d.tokens = append(d.tokens, tok)
+	}
+	d.dialect = l.Dialect()
+
+	p := parser.New(lexer.NewWithDialect(text, dialect))
+	d.program = p.ParseProgram()
+	d.errors = p.Errors()
+
+	return d
+}
+
+// position converts a position in the source to a protocol position.
+func (d *document) position(pos token.Position) Position {
+	line := pos.Line - 1
+	if line < 0 || line >= len(d.lines) {
+		return Position{Line: line}
+	}
+	return Position{Line: line, Character: utf16Len(d.lines[line], pos.Column-1)}
+}
+
+// sourcePosition converts a protocol position to a line and rune column,
+// both counted from 1.
+func (d *document) sourcePosition(pos Position) (line, column int) {
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return pos.Line + 1, 1
+	}
+
+	units := 0
+	column = 1
+	for _, r := range d.lines[pos.Line] {
+		if units >= pos.Character {
+			break
+		}
+		units += utf16RuneLen(r)
+		column++
+	}
+	return pos.Line + 1, column
+}
+
+// tokenRange returns the range of tok in the document.
+func (d *document) tokenRange(tok token.Token) Range {
+	length := utf8.RuneCountInString(tok.Literal)
+	switch tok.Type {
+	case token.STRING, token.STRING_START, token.STRING_MIDDLE, token.STRING_END:
+		length += 2 // the quotes or braces around the text
+	case token.RAW_STRING:
+		lines := strings.Split(tok.Literal, "\n")
+		if len(lines) > 1 {
+			last := lines[len(lines)-1]
+			end := token.Position{Line: tok.Pos.Line + len(lines) - 1, Column: utf8.RuneCountInString(last) + 2}
+			return Range{Start: d.position(tok.Pos), End: d.position(end)}
+		}
+		length += 2 // the backticks
+	}
+	if length == 0 {
+		length = 1
+	}
+
+	end := tok.Pos
+	end.Column += length
+	return Range{Start: d.position(tok.Pos), End: d.position(end)}
+}
+
+// diagnosticRange returns the range of the token a diagnostic points at.
+func (d *document) diagnosticRange(pos token.Position) Range {
+	for _, tok := range d.tokens {
+		if tok.Pos.Offset == pos.Offset {
+			return d.tokenRange(tok)
+		}
+	}
+
+	end := pos
+	end.Column++
+	return Range{Start: d.position(pos), End: d.position(end)}
+}
+
+// tokenAt returns the token that covers the protocol position pos.
+func (d *document) tokenAt(pos Position) (token.Token, bool) {
+	line, column := d.sourcePosition(pos)
+
+	for _, tok := range d.tokens {
+		if tok.Pos.Line != line {
+			continue
+		}
+		start := tok.Pos.Column
+		end := start + utf8.RuneCountInString(tok.Literal)
+		if start <= column && column <= end {
+			return tok, true
+		}
+	}
+	return token.Token{}, false
+}
+
+// end returns the position after the last character in the document.
+func (d *document) end() Position {
+	last := len(d.lines) - 1
+	return Position{Line: last, Character: utf16Len(d.lines[last], -1)}
+}
+
+// definitionOf returns where the name at tok is bound, if the document
+// parses and the name is bound by la or as a parameter.
+func (d *document) definitionOf(tok token.Token) (*ast.Identifier, bool) {
+	if len(d.errors) != 0 {
+		return nil, false
+	}
+
+	for use, def := range checker.Definitions(d.program, d.dialect) {
+		if use.Token.Pos.Offset == tok.Pos.Offset {
+			return def, true
+		}
+	}
+	return nil, false
+}
+
+// namesBefore returns the names bound by la or as parameters before the
+// given line and column that are in scope there. It works on the tokens, so
+// that names are found while the program is being written and does not parse.
+func (d *document) namesBefore(line, column int) []string {
+	type frame struct {
+		depth int // the brace depth of the function body
+		names []string
+	}
+
+	frames := []*frame{{}}
+	depth := 0
+	var params []string // parameters of the function whose body is next
+	inParams := false
+
+	for i, tok := range d.tokens {
+		if tok.Pos.Line > line || (tok.Pos.Line == line && tok.Pos.Column >= column) {
+			break
+		}
+
+		top := frames[len(frames)-1]
+
+		switch tok.Type {
+		case token.LET:
+			if i+1 < len(d.tokens) && d.tokens[i+1].Type == token.IDENT {
+				top.names = append(top.names, d.tokens[i+1].Literal)
+			}
+		case token.FUNCTION, token.MACRO:
+			params = []string{}
+			inParams = true
+		case token.IDENT:
+			if inParams {
+				params = append(params, tok.Literal)
+			}
+		case token.RPAREN:
+			inParams = false
+		case token.LBRACE:
+			depth++
+			if params != nil {
+				frames = append(frames, &frame{depth: depth, names: params})
+				params = nil
+			}
+		case token.RBRACE:
+			if len(frames) > 1 && top.depth == depth {
+				frames = frames[:len(frames)-1]
+			}
+			depth--
+		}
+	}
+
+	seen := make(map[string]bool)
+	names := []string{}
+	for _, f := range frames {
+		for _, name := range f.names {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// utf16Len returns the number of UTF-16 code units in the first n runes of s,
+// or in all of s if n is negative.
+func utf16Len(s string, n int) int {
+	units := 0
+	for _, r := range s {
+		if n == 0 {
+			break
+		}
+		units += utf16RuneLen(r)
+		n--
+	}
+	return units
+}
+
+func utf16RuneLen(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,339 @@
+// lsp/server.go
+
+// Package lsp is a language server for editors, speaking the Language Server
+// Protocol over a pair of streams.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/solbero/pytonskript/checker"
+	"github.com/solbero/pytonskript/diagnostic"
+	"github.com/solbero/pytonskript/evaluator"
+	"github.com/solbero/pytonskript/formatter"
+	"github.com/solbero/pytonskript/rpc"
+	"github.com/solbero/pytonskript/token"
+)
+
+// ErrExitWithoutShutdown is returned by Serve if the client asks the server
+// to exit without asking it to shut down first.
+var ErrExitWithoutShutdown = errors.New("lsp: exit without shutdown")
+
+type Server struct {
+	in      *bufio.Reader
+	out     io.Writer
+	dialect *token.Dialect
+
+	documents map[string]*document
+	shutdown  bool
+}
+
+// NewServer returns a server reading messages from in and writing to out.
+// Documents without a dialect directive are read in dialect.
+func NewServer(in io.Reader, out io.Writer, dialect *token.Dialect) *Server {
+	return &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		dialect:   dialect,
+		documents: make(map[string]*document),
+	}
+}
+
+type (
+	requestHandler      func(s *Server, params json.RawMessage) (interface{}, error)
+	notificationHandler func(s *Server, params json.RawMessage) error
+)
+
+var requests = map[string]requestHandler{
+	"initialize":              (*Server).initialize,
+	"shutdown":                (*Server).shutdownRequest,
+	"textDocument/hover":      (*Server).hover,
+	"textDocument/definition": (*Server).definition,
+	"textDocument/completion": (*Server).completion,
+	"textDocument/formatting": (*Server).formatting,
+}
+
+var notifications = map[string]notificationHandler{
+	"textDocument/didOpen":   (*Server).didOpen,
+	"textDocument/didChange": (*Server).didChange,
+	"textDocument/didClose":  (*Server).didClose,
+}
+
+// Serve handles messages until the client asks the server to exit or closes
+// the input.
+func (s *Server) Serve() error {
+	for {
+		body, err := rpc.Read(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			if err := s.replyError(nil, &responseError{Code: codeParseError, Message: err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return ErrExitWithoutShutdown
+			}
+			return nil
+		}
+
+		if err := s.handle(&req); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(req *request) error {
+	if req.ID == nil {
+		// Unknown notifications, such as initialized, need no answer.
+		if handler, ok := notifications[req.Method]; ok {
+			return handler(s, req.Params)
+		}
+		return nil
+	}
+
+	handler, ok := requests[req.Method]
+	if !ok {
+		return s.replyError(req.ID, &responseError{Code: codeMethodNotFound, Message: "ukjent metode: " + req.Method})
+	}
+
+	result, err := handler(s, req.Params)
+	if err != nil {
+		var rerr *responseError
+		if !errors.As(err, &rerr) {
+			rerr = &responseError{Code: codeInternalError, Message: err.Error()}
+		}
+		return s.replyError(req.ID, rerr)
+	}
+
+	return s.write(&response{JSONRPC: "2.0", ID: req.ID, Result: result})
+}
+
+func (s *Server) replyError(id *json.RawMessage, err *responseError) error {
+	return s.write(&errorResponse{JSONRPC: "2.0", ID: id, Error: err})
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	return s.write(&notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *Server) write(msg interface{}) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return rpc.Write(s.out, body)
+}
+
+func decode(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) initialize(params json.RawMessage) (interface{}, error) {
+	result := InitializeResult{ServerInfo: ServerInfo{Name: "pytonskript"}}
+	result.Capabilities.TextDocumentSync = syncFull
+	result.Capabilities.HoverProvider = true
+	result.Capabilities.DefinitionProvider = true
+	result.Capabilities.DocumentFormattingProvider = true
+	return result, nil
+}
+
+func (s *Server) shutdownRequest(params json.RawMessage) (interface{}, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) error {
+	var p DidOpenTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil
+	}
+	return s.update(p.TextDocument.URI, p.TextDocument.Text)
+}
+
+func (s *Server) didChange(params json.RawMessage) error {
+	var p DidChangeTextDocumentParams
+	if err := decode(params, &p); err != nil || len(p.ContentChanges) == 0 {
+		return nil
+	}
+	return s.update(p.TextDocument.URI, p.ContentChanges[len(p.ContentChanges)-1].Text)
+}
+
+func (s *Server) didClose(params json.RawMessage) error {
+	var p DidCloseTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil
+	}
+	delete(s.documents, p.TextDocument.URI)
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         p.TextDocument.URI,
+		Diagnostics: []Diagnostic{},
+	})
+}
+
+// update replaces the text of a document and publishes its diagnostics:
+// syntax errors if it does not parse, or else what the checker finds.
+func (s *Server) update(uri, text string) error {
+	doc := newDocument(text, s.dialect)
+	s.documents[uri] = doc
+
+	found := doc.errors
+	if len(found) == 0 {
+		found = checker.Check(doc.program, doc.dialect)
+	}
+
+	diagnostics := []Diagnostic{}
+	for _, d := range found {
+		severity := SeverityError
+		if d.Severity == diagnostic.SeverityWarning {
+			severity = SeverityWarning
+		}
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    doc.diagnosticRange(d.Pos),
+			Severity: severity,
+			Code:     string(d.Code),
+			Source:   "pytonskript",
+			Message:  d.Message,
+		})
+	}
+
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+}
+
+// identifierAt returns the document and the identifier token at a position.
+func (s *Server) identifierAt(params json.RawMessage) (*document, token.Token, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, token.Token{}, err
+	}
+
+	doc, ok := s.documents[p.TextDocument.URI]
+	if !ok {
+		return nil, token.Token{}, nil
+	}
+
+	tok, ok := doc.tokenAt(p.Position)
+	if !ok || tok.Type != token.IDENT {
+		return nil, token.Token{}, nil
+	}
+
+	return doc, tok, nil
+}
+
+func (s *Server) hover(params json.RawMessage) (interface{}, error) {
+	doc, tok, err := s.identifierAt(params)
+	if doc == nil {
+		return nil, err
+	}
+
+	if _, ok := doc.definitionOf(tok); ok {
+		return nil, nil
+	}
+
+	name, ok := doc.dialect.Builtin(tok.Literal)
+	if !ok {
+		return nil, nil
+	}
+	builtin, ok := evaluator.LookupBuiltin(name)
+	if !ok {
+		return nil, nil
+	}
+
+	r := doc.tokenRange(tok)
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: fmt.Sprintf("`%s`\n\n%s", tok.Literal, builtin.Doc)},
+		Range:    &r,
+	}, nil
+}
+
+func (s *Server) definition(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	doc, tok, err := s.identifierAt(params)
+	if doc == nil {
+		return nil, err
+	}
+
+	def, ok := doc.definitionOf(tok)
+	if !ok {
+		return nil, nil
+	}
+
+	return &Location{URI: p.TextDocument.URI, Range: doc.tokenRange(def.Token)}, nil
+}
+
+func (s *Server) completion(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	items := []CompletionItem{}
+
+	doc, ok := s.documents[p.TextDocument.URI]
+	if !ok {
+		return items, nil
+	}
+
+	for _, keyword := range doc.dialect.Keywords() {
+		items = append(items, CompletionItem{Label: keyword, Kind: CompletionKeyword})
+	}
+
+	for _, name := range doc.dialect.Builtins() {
+		item := CompletionItem{Label: name, Kind: CompletionFunction}
+		if canonical, ok := doc.dialect.Builtin(name); ok {
+			if builtin, ok := evaluator.LookupBuiltin(canonical); ok {
+				item.Detail = builtin.Doc
+			}
+		}
+		items = append(items, item)
+	}
+
+	line, column := doc.sourcePosition(p.Position)
+	for _, name := range doc.namesBefore(line, column) {
+		items = append(items, CompletionItem{Label: name, Kind: CompletionVariable})
+	}
+
+	return items, nil
+}
+
+func (s *Server) formatting(params json.RawMessage) (interface{}, error) {
+	var p DocumentFormattingParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	doc, ok := s.documents[p.TextDocument.URI]
+	if !ok {
+		return nil, nil
+	}
+
+	formatted, errors := formatter.Format(doc.text, doc.dialect)
+	if len(errors) != 0 || formatted == doc.text {
+		return []TextEdit{}, nil
+	}
+
+	return []TextEdit{{
+		Range:   Range{Start: Position{}, End: doc.end()},
+		NewText: formatted,
+	}}, nil
+}
@@ -0,0 +1,393 @@
+// lsp/server_test.go
+
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/solbero/pytonskript/rpc"
+	"github.com/solbero/pytonskript/token"
+)
+
+// client drives a server over pipes, the way an editor would.
+type client struct {
+	t      *testing.T
+	in     *io.PipeWriter
+	out    *bufio.Reader
+	nextID int
+	done   chan error
+
+	notifications []notification
+}
+
+func newClient(t *testing.T, dialect *token.Dialect) *client {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+
+	c := &client{t: t, in: inWriter, out: bufio.NewReader(outReader), done: make(chan error, 1)}
+
+	go func() {
+		err := NewServer(inReader, outWriter, dialect).Serve()
+		outWriter.Close()
+		c.done <- err
+	}()
+
+	return c
+}
+
+func (c *client) send(msg interface{}) {
+	c.t.Helper()
+	body, err := json.Marshal(msg)
+	if err != nil {
+		c.t.Fatalf("could not encode message: %s", err)
+	}
+	if err := rpc.Write(c.in, body); err != nil {
+		c.t.Fatalf("could not send message: %s", err)
+	}
+}
+
+type incoming struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+func (c *client) receive() incoming {
+	c.t.Helper()
+	body, err := rpc.Read(c.out)
+	if err != nil {
+		c.t.Fatalf("could not read message: %s", err)
+	}
+	var msg incoming
+	if err := json.Unmarshal(body, &msg); err != nil {
+		c.t.Fatalf("could not decode message %s: %s", body, err)
+	}
+	return msg
+}
+
+// request sends a request and decodes the result into result. Notifications
+// that arrive first are kept.
+func (c *client) request(method string, params interface{}, result interface{}) *responseError {
+	c.t.Helper()
+	c.nextID++
+	c.send(map[string]interface{}{"jsonrpc": "2.0", "id": c.nextID, "method": method, "params": params})
+
+	for {
+		msg := c.receive()
+		if msg.ID == nil {
+			c.notifications = append(c.notifications, notification{Method: msg.Method, Params: msg.Params})
+			continue
+		}
+		if *msg.ID != c.nextID {
+			c.t.Fatalf("response to the wrong request, expected %d, got %d", c.nextID, *msg.ID)
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("could not decode result %s: %s", msg.Result, err)
+			}
+		}
+		return nil
+	}
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+	c.send(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+// diagnostics waits for the next diagnostics published by the server.
+func (c *client) diagnostics() PublishDiagnosticsParams {
+	c.t.Helper()
+	msg := c.receive()
+	if msg.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("expected diagnostics, got %q", msg.Method)
+	}
+	var params PublishDiagnosticsParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		c.t.Fatalf("could not decode diagnostics: %s", err)
+	}
+	return params
+}
+
+func (c *client) open(uri, text string) PublishDiagnosticsParams {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "pytonskript", Version: 1, Text: text},
+	})
+	return c.diagnostics()
+}
+
+func (c *client) close() error {
+	c.t.Helper()
+	c.request("shutdown", nil, nil)
+	c.notify("exit", nil)
+	return <-c.done
+}
+
+func at(uri string, line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: line, Character: character},
+	}
+}
+
+const uri = "file:///program.pytonskript"
+
+func TestInitializeAndShutdown(t *testing.T) {
+	c := newClient(t, token.Bokmal)
+
+	var result InitializeResult
+	if err := c.request("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}}, &result); err != nil {
+		t.Fatalf("initialize failed: %s", err)
+	}
+	c.notify("initialized", map[string]interface{}{})
+
+	if result.Capabilities.TextDocumentSync != syncFull || !result.Capabilities.HoverProvider ||
+		!result.Capabilities.DefinitionProvider || !result.Capabilities.DocumentFormattingProvider {
+		t.Errorf("wrong capabilities: %+v", result.Capabilities)
+	}
+
+	if err := rpc.Write(c.in, []byte("{")); err != nil {
+		t.Fatalf("could not send message: %s", err)
+	}
+	if msg := c.receive(); msg.Error == nil || msg.Error.Code != codeParseError {
+		t.Errorf("expected a parse error, got %+v", msg)
+	}
+
+	err := c.request("textDocument/rename", map[string]interface{}{}, nil)
+	if err == nil || err.Code != codeMethodNotFound {
+		t.Errorf("expected method not found, got %v", err)
+	}
+
+	if err := c.close(); err != nil {
+		t.Errorf("Serve failed: %s", err)
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	c := newClient(t, token.Bokmal)
+	c.notify("exit", nil)
+
+	if err := <-c.done; err != ErrExitWithoutShutdown {
+		t.Errorf("expected ErrExitWithoutShutdown, got %v", err)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := newClient(t, token.Bokmal)
+
+	published := c.open(uri, "la x = ;")
+	if published.URI != uri || len(published.Diagnostics) != 1 {
+		t.Fatalf("expected one diagnostic for %s, got %+v", uri, published)
+	}
+
+	d := published.Diagnostics[0]
+	expected := Diagnostic{
+		Range:    Range{Start: Position{0, 7}, End: Position{0, 8}},
+		Severity: SeverityError,
+		Code:     "S002",
+		Source:   "pytonskript",
+		Message:  "uventet ';' i starten av et uttrykk",
+	}
+	if d != expected {
+		t.Errorf("wrong diagnostic, expected %+v, got %+v", expected, d)
+	}
+
+	// Once the program parses, the checker's findings are published.
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: uri},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "la blåbær = 1;\nla x = blåbær + y;"}},
+	})
+	published = c.diagnostics()
+
+	if len(published.Diagnostics) != 2 {
+		t.Fatalf("expected two diagnostics, got %+v", published.Diagnostics)
+	}
+
+	unused := published.Diagnostics[0]
+	if unused.Code != "A001" || unused.Severity != SeverityWarning ||
+		unused.Range != (Range{Start: Position{1, 3}, End: Position{1, 4}}) {
+		t.Errorf("wrong diagnostic for unused name: %+v", unused)
+	}
+
+	undefined := published.Diagnostics[1]
+	if undefined.Code != "K001" || undefined.Severity != SeverityError ||
+		undefined.Range != (Range{Start: Position{1, 16}, End: Position{1, 17}}) {
+		t.Errorf("wrong diagnostic for undefined name: %+v", undefined)
+	}
+
+	c.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}})
+	if published := c.diagnostics(); len(published.Diagnostics) != 0 {
+		t.Errorf("expected diagnostics to be cleared, got %+v", published.Diagnostics)
+	}
+
+	c.close()
+}
+
+func TestHover(t *testing.T) {
+	c := newClient(t, token.Bokmal)
+	// 𝑥 is two UTF-16 code units, which moves everything after it.
+	c.open(uri, "la 𝑥 = [1]; skriv(lengde(𝑥));")
+
+	var hover *Hover
+	if err := c.request("textDocument/hover", at(uri, 0, 21), &hover); err != nil {
+		t.Fatalf("hover failed: %s", err)
+	}
+	if hover == nil {
+		t.Fatalf("expected hover for lengde")
+	}
+	expected := "`lengde`\n\nGir antall elementer i en liste eller antall tegn i en streng."
+	if hover.Contents.Value != expected {
+		t.Errorf("wrong hover, expected %q, got %q", expected, hover.Contents.Value)
+	}
+	if *hover.Range != (Range{Start: Position{0, 19}, End: Position{0, 25}}) {
+		t.Errorf("wrong hover range: %+v", *hover.Range)
+	}
+
+	hover = nil
+	if err := c.request("textDocument/hover", at(uri, 0, 26), &hover); err != nil {
+		t.Fatalf("hover failed: %s", err)
+	}
+	if hover != nil {
+		t.Errorf("expected no hover for a name bound by la, got %+v", hover)
+	}
+
+	var location *Location
+	if err := c.request("textDocument/definition", at(uri, 0, 27), &location); err != nil {
+		t.Fatalf("definition failed: %s", err)
+	}
+	if location == nil || location.Range != (Range{Start: Position{0, 3}, End: Position{0, 5}}) {
+		t.Errorf("wrong definition of 𝑥: %+v", location)
+	}
+
+	c.close()
+}
+
+func TestHoverDialect(t *testing.T) {
+	c := newClient(t, token.Bokmal)
+	c.open(uri, "# dialekt: engelsk\nputs(len([]))")
+
+	var hover *Hover
+	if err := c.request("textDocument/hover", at(uri, 1, 6), &hover); err != nil {
+		t.Fatalf("hover failed: %s", err)
+	}
+	if hover == nil || hover.Contents.Value[:5] != "`len`" {
+		t.Errorf("expected hover for len, got %+v", hover)
+	}
+
+	c.close()
+}
+
+func TestDefinition(t *testing.T) {
+	c := newClient(t, token.Bokmal)
+	c.open(uri, "la x = 1;\nla f = funksjon(x) {\n  x + g()\n};\nla g = funksjon() { x };\nf(x);")
+
+	tests := []struct {
+		line, character int
+		expected        *Range
+	}{
+		{2, 2, &Range{Start: Position{1, 16}, End: Position{1, 17}}}, // the parameter x
+		{2, 6, &Range{Start: Position{4, 3}, End: Position{4, 4}}},   // g, bound further down
+		{4, 20, &Range{Start: Position{0, 3}, End: Position{0, 4}}},  // x in g
+		{5, 0, &Range{Start: Position{1, 3}, End: Position{1, 4}}},   // f
+		{0, 3, &Range{Start: Position{0, 3}, End: Position{0, 4}}},   // the binding itself
+		{0, 7, nil}, // not a name
+	}
+
+	for i, tt := range tests {
+		var location *Location
+		if err := c.request("textDocument/definition", at(uri, tt.line, tt.character), &location); err != nil {
+			t.Fatalf("tests[%d] - definition failed: %s", i, err)
+		}
+
+		if tt.expected == nil {
+			if location != nil {
+				t.Errorf("tests[%d] - expected no definition, got %+v", i, location)
+			}
+			continue
+		}
+
+		if location == nil || location.URI != uri || location.Range != *tt.expected {
+			t.Errorf("tests[%d] - wrong definition, expected %+v, got %+v", i, *tt.expected, location)
+		}
+	}
+
+	c.close()
+}
+
+func TestCompletion(t *testing.T) {
+	c := newClient(t, token.Bokmal)
+	// The program is still being written and does not parse.
+	c.open(uri, "la a = 1;\nla f = funksjon(b) {\n  la c = 2;\n  \n};\nla d = funksjon(e) { e };\n")
+
+	tests := []struct {
+		line, character int
+		names           []string
+	}{
+		{3, 2, []string{"a", "b", "c", "f"}},
+		{5, 0, []string{"a", "f"}},
+		{6, 0, []string{"a", "d", "f"}},
+	}
+
+	for i, tt := range tests {
+		var items []CompletionItem
+		if err := c.request("textDocument/completion", at(uri, tt.line, tt.character), &items); err != nil {
+			t.Fatalf("tests[%d] - completion failed: %s", i, err)
+		}
+
+		names := []string{}
+		kinds := make(map[string]CompletionItemKind)
+		for _, item := range items {
+			kinds[item.Label] = item.Kind
+			if item.Kind == CompletionVariable {
+				names = append(names, item.Label)
+			}
+		}
+
+		if len(names) != len(tt.names) {
+			t.Errorf("tests[%d] - wrong names, expected %v, got %v", i, tt.names, names)
+		} else {
+			for j := range names {
+				if names[j] != tt.names[j] {
+					t.Errorf("tests[%d] - wrong names, expected %v, got %v", i, tt.names, names)
+					break
+				}
+			}
+		}
+
+		if kinds["hvis"] != CompletionKeyword || kinds["tilføy"] != CompletionFunction {
+			t.Errorf("tests[%d] - keywords and builtins missing: %v", i, kinds)
+		}
+	}
+
+	c.close()
+}
+
+func TestFormatting(t *testing.T) {
+	c := newClient(t, token.Bokmal)
+	c.open(uri, "la   x=1\nskriv( x )")
+
+	var edits []TextEdit
+	params := DocumentFormattingParams{TextDocument: TextDocumentIdentifier{URI: uri}}
+	if err := c.request("textDocument/formatting", params, &edits); err != nil {
+		t.Fatalf("formatting failed: %s", err)
+	}
+
+	expected := TextEdit{
+		Range:   Range{Start: Position{0, 0}, End: Position{1, 10}},
+		NewText: "la x = 1;\nskriv(x);\n",
+	}
+	if len(edits) != 1 || edits[0] != expected {
+		t.Errorf("wrong edits, expected [%+v], got %+v", expected, edits)
+	}
+
+	c.close()
+}
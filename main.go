@@ -3,36 +3,110 @@
 package main
 
 import (
+	"bytes"
+	"flag"
 	"fmt"
 	"os"
 	"os/user"
 
+	"github.com/solbero/pytonskript/bytecode"
+	"github.com/solbero/pytonskript/diagnostic"
 	"github.com/solbero/pytonskript/exec"
 	"github.com/solbero/pytonskript/repl"
+	"github.com/solbero/pytonskript/token"
 )
 
+// commands are run as `pytonskript kommando [argumenter]` and return the exit
+// code of the program.
+var commands = map[string]func(args []string, dialect *token.Dialect) int{
+	"ast":      astCommand,
+	"bygg":     buildCommand,
+	"feilsøk":  debugCommand,
+	"formater": formatCommand,
+	"lsp":      lspCommand,
+	"oversett": translateCommand,
+	"sjekk":    checkCommand,
+}
+
 func main() {
+	language := flag.String("språk", "", "språk for feilmeldinger, nb (bokmål) eller en (engelsk)")
+	dialectName := flag.String("dialekt", "bokmål", "nøkkelord og innebygde funksjoner, bokmål, nynorsk eller engelsk")
+	engine := flag.String("motor", "tolk", "hvordan programmer kjøres, tolk (treet tolkes direkte) eller vm (kompileres til bytekode)")
+	optimize := flag.Bool("optimaliser", false, "regn ut konstante uttrykk og fjern grener som aldri kjøres før programmet kjøres")
+	flag.Parse()
+
+	if !setLanguage(*language) {
+		fmt.Fprintf(os.Stderr, "%q: ukjent språk: %s\n", os.Args[0], *language)
+		os.Exit(2)
+	}
+
+	dialect, ok := token.LookupDialect(*dialectName)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "%q: ukjent dialekt: %s\n", os.Args[0], *dialectName)
+		os.Exit(2)
+	}
+
+	startREPL, startFile := repl.Start, exec.Start
+	switch *engine {
+	case "tolk":
+	case "vm":
+		startREPL, startFile = repl.StartVM, exec.StartVM
+	default:
+		fmt.Fprintf(os.Stderr, "%q: ukjent motor: %s\n", os.Args[0], *engine)
+		os.Exit(2)
+	}
+
+	args := flag.Args()
+
+	if len(args) > 0 {
+		if command, ok := commands[args[0]]; ok {
+			os.Exit(command(args[1:], dialect))
+		}
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
 	}
 
-	args := os.Args
-
 	switch len(args) {
-	case 1:
+	case 0:
 		fmt.Printf("Hei %s! Dette er programmeringsspråket Pyton!\n", user.Username)
 		fmt.Printf("Her kan du skrive inn instruksjoner\n")
-		repl.Start(os.Stdin, os.Stdout)
-	case 2:
-		file, err := os.Open(args[1])
+		startREPL(os.Stdin, os.Stdout, dialect)
+	case 1:
+		data, err := os.ReadFile(args[0])
 		if err != nil {
 			panic(err)
 		}
-		defer file.Close()
-		exec.Start(file, os.Stdout)
+		if !bytecode.IsBytecode(data) {
+			startFile(bytes.NewReader(data), os.Stdout, dialect, *optimize)
+			return
+		}
+		if err := exec.StartBytecode(bytes.NewReader(data), os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "%q: %s\n", os.Args[0], err)
+			os.Exit(1)
+		}
 	default:
-		fmt.Fprintf(os.Stderr, "%q: incorrect usage: Usage: `monkey [filePath]`\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "%q: incorrect usage: Usage: `pytonskript [-språk nb|en] [-dialekt bokmål|nynorsk|engelsk] [-motor tolk|vm] [-optimaliser] [filePath | ast ... | bygg ... | formater ... | oversett ... | sjekk ... | lsp | feilsøk ...]`\n", os.Args[0])
 	}
 
 }
+
+// setLanguage selects the language of diagnostics from the flag, falling back
+// to the environment. It reports false if the requested language is unknown.
+func setLanguage(flagValue string) bool {
+	if flagValue == "" {
+		if lang, ok := diagnostic.LanguageFromEnv(); ok {
+			diagnostic.SetLanguage(lang)
+		}
+		return true
+	}
+
+	lang, ok := diagnostic.ParseLanguage(flagValue)
+	if !ok {
+		return false
+	}
+	diagnostic.SetLanguage(lang)
+	return true
+}
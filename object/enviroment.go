@@ -1,5 +1,12 @@
 package object
 
+import (
+	"sort"
+
+	"github.com/solbero/pytonskript/ast"
+	"github.com/solbero/pytonskript/token"
+)
+
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
@@ -7,8 +14,10 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 }
 
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store   map[string]Object
+	outer   *Environment
+	dialect *token.Dialect
+	hook    Hook
 }
 
 func NewEnvironment() *Environment {
@@ -28,3 +37,58 @@ func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
 	return val
 }
+
+// Dialect returns the dialect builtin names are looked up in. Enclosed
+// environments use the dialect of the outermost environment, which is bokmål
+// unless SetDialect has been called.
+func (e *Environment) Dialect() *token.Dialect {
+	if e.dialect != nil {
+		return e.dialect
+	}
+	if e.outer != nil {
+		return e.outer.Dialect()
+	}
+	return token.Bokmal
+}
+
+func (e *Environment) SetDialect(dialect *token.Dialect) {
+	e.dialect = dialect
+}
+
+// Hook is told about every statement and call to a user function while a
+// program runs, so that a debugger can follow it.
+type Hook interface {
+	Statement(stmt ast.Statement, env *Environment)
+	Call(call *ast.CallExpression, fn *Function)
+	Return(call *ast.CallExpression, result Object)
+}
+
+// Hook returns the hook set on the outermost environment, or nil.
+func (e *Environment) Hook() Hook {
+	if e.hook != nil {
+		return e.hook
+	}
+	if e.outer != nil {
+		return e.outer.Hook()
+	}
+	return nil
+}
+
+func (e *Environment) SetHook(hook Hook) {
+	e.hook = hook
+}
+
+// Outer returns the environment e is enclosed in, or nil.
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// Names returns the names set in e itself, sorted.
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
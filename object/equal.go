@@ -0,0 +1,95 @@
+// object/equal.go
+
+package object
+
+// Equal reports whether a and b are the same value. Numbers, strings,
+// booleans and null are compared by value, so 1 is equal to 1.0, arrays are
+// equal if their elements are, and hashes are equal if they have the same
+// keys with equal values, in any order. Other objects, like functions, are
+// only equal to themselves.
+func Equal(a, b Object) bool {
+	return equal(a, b, map[[2]Object]bool{})
+}
+
+// equal compares a and b. comparing holds the arrays and hashes being
+// compared further up, so that a value that contains itself does not make
+// equal go on forever. A pair met again is taken to be equal, since any
+// difference is found where the pair was first compared.
+func equal(a, b Object, comparing map[[2]Object]bool) bool {
+	switch a := a.(type) {
+	case *Integer:
+		if b, ok := b.(*Integer); ok {
+			return a.Value == b.Value
+		}
+		y, ok := AsFloat(b)
+		return ok && float64(a.Value) == y
+	case *Float:
+		y, ok := AsFloat(b)
+		return ok && a.Value == y
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *Null:
+		_, ok := b.(*Null)
+		return ok
+
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		if a == b || comparing[[2]Object{a, b}] {
+			return true
+		}
+		comparing[[2]Object{a, b}] = true
+		for i := range a.Elements {
+			if !equal(a.Elements[i], b.Elements[i], comparing) {
+				return false
+			}
+		}
+		return true
+
+	case *Hash:
+		b, ok := b.(*Hash)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		if a == b || comparing[[2]Object{a, b}] {
+			return true
+		}
+		comparing[[2]Object{a, b}] = true
+		for _, pair := range a.pairs {
+			key, _ := pair.Key.(Hashable)
+			i, ok := b.find(key, key.HashKey())
+			if !ok || !equal(pair.Value, b.pairs[i].Value, comparing) {
+				return false
+			}
+		}
+		return true
+
+	default:
+		return a == b
+	}
+}
+
+// HashableKey returns obj as a key for a hash, or false if it cannot be one.
+// Arrays are Hashable, but can only be keys if all their elements can.
+func HashableKey(obj Object) (Hashable, bool) {
+	key, ok := obj.(Hashable)
+	if !ok {
+		return nil, false
+	}
+
+	if array, ok := obj.(*Array); ok {
+		for _, element := range array.Elements {
+			if _, ok := HashableKey(element); !ok {
+				return nil, false
+			}
+		}
+	}
+
+	return key, true
+}
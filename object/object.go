@@ -4,17 +4,23 @@ package object
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"strconv"
 	"strings"
 
 	"github.com/solbero/pytonskript/ast"
+	"github.com/solbero/pytonskript/code"
+	"github.com/solbero/pytonskript/diagnostic"
 )
 
 type ObjectType string
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
@@ -24,26 +30,80 @@ const (
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
+	QUOTE_OBJ        = "QUOTE"
+	MACRO_OBJ        = "MACRO"
+
+	PRINT_OPTIONS_OBJ = "PRINT_OPTIONS"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	CELL_OBJ              = "CELL"
 )
 
+// typeNames are the bokmål names of the types a program can see.
+var typeNames = map[ObjectType]string{
+	INTEGER_OBJ:       "heltall",
+	FLOAT_OBJ:         "desimaltall",
+	BOOLEAN_OBJ:       "sannhetsverdi",
+	NULL_OBJ:          "ingenting",
+	FUNCTION_OBJ:      "funksjon",
+	STRING_OBJ:        "streng",
+	BUILTIN_OBJ:       "innebygd funksjon",
+	ARRAY_OBJ:         "liste",
+	HASH_OBJ:          "tabell",
+	QUOTE_OBJ:         "sitat",
+	MACRO_OBJ:         "makro",
+	PRINT_OPTIONS_OBJ: "skrivevalg",
+}
+
+// Name returns the name of the type in bokmål, as the builtin type gives
+// it. Programs see the same name whatever language messages are shown in.
+func (t ObjectType) Name() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return string(t)
+}
+
 type Object interface {
 	Inspect() string
 	Type() ObjectType
 }
 
 type Hashable interface {
+	Object
 	HashKey() HashKey
 }
 
-type BuiltinFunction func(args ...Object) Object
+// BuiltinFunction implements a builtin. caller calls the functions it gets
+// as arguments, in whichever engine runs the program.
+type BuiltinFunction func(caller Caller, args ...Object) Object
+
+// Caller calls functions and builtins on behalf of a builtin. Errors in the
+// call are returned as *Error.
+type Caller interface {
+	Call(fn Object, args ...Object) Object
+}
 
 type Error struct {
+	Code    diagnostic.Code
 	Message string
 }
 
-func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+// Inspect gives the message with its code after it, the way diagnostics from
+// the parser show theirs.
+func (e *Error) Inspect() string {
+	message := diagnostic.Sprintf(diagnostic.LabelError, e.Message)
+	if e.Code == "" {
+		return message
+	}
+	return fmt.Sprintf("%s [%s]", message, e.Code)
+}
+
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 
+// Error lets the virtual machine return errors in programs as Go errors.
+func (e *Error) Error() string { return e.Message }
+
 type Integer struct {
 	Value int64
 }
@@ -51,6 +111,35 @@ type Integer struct {
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 
+// Float is a decimal number. Programs get one from desimal, or from
+// arithmetic on a float and an integer.
+type Float struct {
+	Value float64
+}
+
+// Inspect always shows a decimal point, so 3.0 is not taken for 3.
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'f', -1, 64)
+	if math.IsInf(f.Value, 0) || math.IsNaN(f.Value) || strings.Contains(s, ".") {
+		return s
+	}
+	return s + ".0"
+}
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
+// AsFloat returns the value of an integer or a float as a float64, for
+// arithmetic where either side is a float.
+func AsFloat(obj Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return float64(obj.Value), true
+	case *Float:
+		return obj.Value, true
+	default:
+		return 0, false
+	}
+}
+
 type String struct {
 	Value string
 }
@@ -102,8 +191,107 @@ func (f *Function) Inspect() string {
 }
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
 
+// Quote is a piece of the program that sitat has kept from being evaluated.
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Inspect() string {
+	return "QUOTE(" + q.Node.String() + ")"
+}
+func (q *Quote) Type() ObjectType { return QUOTE_OBJ }
+
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+func (m *Macro) Type() ObjectType { return MACRO_OBJ }
+
+// PrintOptions are the options made by skrivevalg, which skriv takes after
+// the values it writes.
+type PrintOptions struct {
+	Separator string // written between the values
+	End       string // written after the last value
+}
+
+func (po *PrintOptions) Inspect() string {
+	return fmt.Sprintf("skrivevalg({%q: %q, %q: %q})", "skille", po.Separator, "slutt", po.End)
+}
+func (po *PrintOptions) Type() ObjectType { return PRINT_OPTIONS_OBJ }
+
+// CompiledFunction is a function literal compiled to bytecode. Closures made
+// from it share its instructions.
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+	NumFree       int
+
+	// Positions maps the instructions back to the source.
+	Positions code.SourceMap
+
+	// LocalNames are the names of the locals, by index, for messages about
+	// names used before they are given a value.
+	LocalNames []string
+
+	// Literal is the function as the evaluator shows it.
+	Literal string
+}
+
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+
+// Closure is a compiled function together with the cells of the variables
+// it refers to in the functions around it.
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []*Cell
+}
+
+func (c *Closure) Inspect() string  { return c.Fn.Literal }
+func (c *Closure) Type() ObjectType { return FUNCTION_OBJ }
+
+// Cell holds a local variable that closures refer to. Value is nil until the
+// variable is given a value.
+type Cell struct {
+	Name  string
+	Value Object
+}
+
+func (c *Cell) Inspect() string  { return fmt.Sprintf("Cell[%s]", c.Name) }
+func (c *Cell) Type() ObjectType { return CELL_OBJ }
+
 type Builtin struct {
 	Fn BuiltinFunction
+
+	// MinArgs and MaxArgs are the number of arguments Fn accepts, so calls can
+	// be checked without running them. MaxArgs is -1 if there is no limit.
+	MinArgs int
+	MaxArgs int
+
+	// Doc describes the function for editors.
+	Doc string
 }
 
 func (b *Builtin) Inspect() string  { return "builtin function" }
@@ -134,15 +322,70 @@ type HashPair struct {
 	Value Object
 }
 
+// Hash maps keys to values. It remembers the order its keys were first set
+// in, and lists and prints its pairs in that order. The zero value is an
+// empty hash.
+//
+// Different keys can have the same HashKey, so the pairs are kept in buckets
+// by HashKey, and the keys in a bucket are compared to find the right one.
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	pairs   []HashPair
+	buckets map[HashKey][]int // indexes in pairs
+}
+
+// Get returns the value of key.
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	i, ok := h.find(key, key.HashKey())
+	if !ok {
+		return nil, false
+	}
+	return h.pairs[i].Value, true
+}
+
+// Set sets the value of key. A new key is put after the others, while a key
+// that is already in the hash keeps its place.
+func (h *Hash) Set(key Hashable, value Object) {
+	hashKey := key.HashKey()
+	if i, ok := h.find(key, hashKey); ok {
+		h.pairs[i].Value = value
+		return
+	}
+
+	if h.buckets == nil {
+		h.buckets = make(map[HashKey][]int)
+	}
+	h.buckets[hashKey] = append(h.buckets[hashKey], len(h.pairs))
+	h.pairs = append(h.pairs, HashPair{Key: key, Value: value})
+}
+
+// find returns the index in pairs of key, whose HashKey is hashKey.
+func (h *Hash) find(key Hashable, hashKey HashKey) (int, bool) {
+	for _, i := range h.buckets[hashKey] {
+		if Equal(h.pairs[i].Key, key) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Len returns the number of pairs in the hash.
+func (h *Hash) Len() int {
+	return len(h.pairs)
+}
+
+// Pairs returns the pairs in the hash in the order their keys were first
+// set in.
+func (h *Hash) Pairs() []HashPair {
+	pairs := make([]HashPair, len(h.pairs))
+	copy(pairs, h.pairs)
+	return pairs
 }
 
 func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.Pairs() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
 	}
 
@@ -175,14 +418,47 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+// HashKey gives a float that is a whole number the key of the integer it is
+// equal to, so that either can be used to look up the other.
+func (f *Float) HashKey() HashKey {
+	if f.Value == math.Trunc(f.Value) && math.Abs(f.Value) < 1<<63 {
+		return (&Integer{Value: int64(f.Value)}).HashKey()
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
 func (s *String) HashKey() HashKey {
+	return HashKey{Type: s.Type(), Value: stringHash(s.Value)}
+}
+
+// HashKey combines the hash keys of the elements, so arrays with equal
+// elements have the same hash key. Use HashableKey to check that the elements
+// can be keys before using an array as one.
+func (ao *Array) HashKey() HashKey {
 	h := fnv.New64a()
-	h.Write([]byte(s.Value))
+	var value [8]byte
+
+	for _, element := range ao.Elements {
+		if key, ok := element.(Hashable); ok {
+			elementKey := key.HashKey()
+			h.Write([]byte(elementKey.Type))
+			binary.BigEndian.PutUint64(value[:], elementKey.Value)
+			h.Write(value[:])
+		}
+	}
 
-	return HashKey{Type: s.Type(), Value: h.Sum64()}
+	return HashKey{Type: ao.Type(), Value: h.Sum64()}
+}
+
+// stringHash hashes the value of strings for HashKey. It is a variable so
+// that tests can replace it with one that collides.
+var stringHash = func(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
 }
 
 type Null struct{}
 
-func (n *Null) Inspect() string  { return "null" }
+func (n *Null) Inspect() string  { return "ingenting" }
 func (n *Null) Type() ObjectType { return NULL_OBJ }
@@ -57,3 +57,196 @@ func TestIntegerHashKey(t *testing.T) {
 		t.Errorf("integers with different content have same hash keys")
 	}
 }
+
+func TestFloatHashKey(t *testing.T) {
+	whole := &Float{Value: 1}
+	half := &Float{Value: 1.5}
+
+	if whole.HashKey() != (&Integer{Value: 1}).HashKey() {
+		t.Errorf("a whole float has a different hash key than the same integer")
+	}
+
+	if half.HashKey() != (&Float{Value: 1.5}).HashKey() {
+		t.Errorf("floats with same content have different hash keys")
+	}
+
+	if half.HashKey() == whole.HashKey() {
+		t.Errorf("floats with different content have same hash keys")
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{1, "1.0"},
+		{0.25, "0.25"},
+		{-3, "-3.0"},
+	}
+
+	for _, tt := range tests {
+		if got := (&Float{Value: tt.value}).Inspect(); got != tt.expected {
+			t.Errorf("Inspect of %v = %q, expected %q", tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestHashOrder(t *testing.T) {
+	hash := &Hash{}
+	hash.Set(&String{Value: "b"}, &Integer{Value: 1})
+	hash.Set(&Integer{Value: 3}, &Integer{Value: 2})
+	hash.Set(&String{Value: "a"}, &Integer{Value: 3})
+	hash.Set(&String{Value: "b"}, &Integer{Value: 4})
+
+	if hash.Len() != 3 {
+		t.Fatalf("hash has wrong length, got %d", hash.Len())
+	}
+
+	expected := "{b: 4, 3: 2, a: 3}"
+	for i := 0; i < 10; i++ {
+		if hash.Inspect() != expected {
+			t.Fatalf("wrong Inspect, expected %q, got %q", expected, hash.Inspect())
+		}
+	}
+
+	value, ok := hash.Get(&Integer{Value: 3})
+	if !ok || value.Inspect() != "2" {
+		t.Errorf("wrong value for 3, got %v", value)
+	}
+
+	if _, ok := hash.Get(&String{Value: "c"}); ok {
+		t.Errorf("found a value for a key that is not in the hash")
+	}
+}
+
+func TestHashCollisions(t *testing.T) {
+	defer func(hash func(string) uint64) { stringHash = hash }(stringHash)
+	stringHash = func(string) uint64 { return 42 }
+
+	a, b := &String{Value: "a"}, &String{Value: "b"}
+	if a.HashKey() != b.HashKey() {
+		t.Fatalf("hash keys do not collide")
+	}
+
+	hash := &Hash{}
+	hash.Set(a, &Integer{Value: 1})
+	hash.Set(b, &Integer{Value: 2})
+	hash.Set(&Integer{Value: 42}, &Integer{Value: 3})
+	hash.Set(&String{Value: "a"}, &Integer{Value: 4})
+
+	expected := "{a: 4, b: 2, 42: 3}"
+	if hash.Inspect() != expected {
+		t.Errorf("wrong Inspect, expected %q, got %q", expected, hash.Inspect())
+	}
+
+	tests := []struct {
+		key      Hashable
+		expected string
+	}{
+		{&String{Value: "a"}, "4"},
+		{&String{Value: "b"}, "2"},
+		{&Integer{Value: 42}, "3"},
+		{&String{Value: "c"}, ""},
+	}
+
+	for _, tt := range tests {
+		value, ok := hash.Get(tt.key)
+		if tt.expected == "" {
+			if ok {
+				t.Errorf("found %s for %s, which is not in the hash", value.Inspect(), tt.key.Inspect())
+			}
+			continue
+		}
+		if !ok || value.Inspect() != tt.expected {
+			t.Errorf("wrong value for %s, expected %s, got %v", tt.key.Inspect(), tt.expected, value)
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	one, two := &Integer{Value: 1}, &Integer{Value: 2}
+	array := func(elements ...Object) *Array { return &Array{Elements: elements} }
+	hash := func(pairs ...Object) *Hash {
+		h := &Hash{}
+		for i := 0; i < len(pairs); i += 2 {
+			h.Set(pairs[i].(Hashable), pairs[i+1])
+		}
+		return h
+	}
+	fn := &Builtin{}
+
+	tests := []struct {
+		a, b     Object
+		expected bool
+	}{
+		{one, &Integer{Value: 1}, true},
+		{one, two, false},
+		{&String{Value: "a"}, &String{Value: "a"}, true},
+		{&Boolean{Value: true}, &Boolean{Value: true}, true},
+		{one, &String{Value: "1"}, false},
+		{one, &Float{Value: 1}, true},
+		{&Float{Value: 1.5}, one, false},
+		{array(&Float{Value: 2}), array(two), true},
+		{&Null{}, &Null{}, true},
+		{array(one, two), array(one, two), true},
+		{array(one, two), array(two, one), false},
+		{array(one), array(one, one), false},
+		{array(array(one)), array(array(one)), true},
+		{hash(one, two, two, one), hash(two, one, one, two), true},
+		{hash(one, two), hash(one, one), false},
+		{hash(one, two), hash(two, two), false},
+		{hash(), array(), false},
+		{array(fn), array(fn), true},
+		{array(&Builtin{}), array(&Builtin{}), false},
+	}
+
+	for _, tt := range tests {
+		if got := Equal(tt.a, tt.b); got != tt.expected {
+			t.Errorf("Equal(%s, %s) = %t, expected %t", tt.a.Inspect(), tt.b.Inspect(), got, tt.expected)
+		}
+	}
+}
+
+func TestEqualCycles(t *testing.T) {
+	a, b := &Array{}, &Array{}
+	a.Elements = []Object{&Integer{Value: 1}, a}
+	b.Elements = []Object{&Integer{Value: 1}, b}
+
+	if !Equal(a, b) {
+		t.Errorf("arrays that contain themselves are not equal")
+	}
+
+	c := &Array{}
+	c.Elements = []Object{&Integer{Value: 2}, c}
+	if Equal(a, c) {
+		t.Errorf("different arrays that contain themselves are equal")
+	}
+
+	h, g := &Hash{}, &Hash{}
+	h.Set(&String{Value: "selv"}, h)
+	g.Set(&String{Value: "selv"}, g)
+	if !Equal(h, g) {
+		t.Errorf("hashes that contain themselves are not equal")
+	}
+}
+
+func TestArrayHashKey(t *testing.T) {
+	a := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+	b := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+	c := &Array{Elements: []Object{&String{Value: "a"}, &Integer{Value: 1}}}
+
+	if a.HashKey() != b.HashKey() {
+		t.Errorf("arrays with same elements have different hash keys")
+	}
+	if a.HashKey() == c.HashKey() {
+		t.Errorf("arrays with different elements have same hash keys")
+	}
+
+	if _, ok := HashableKey(a); !ok {
+		t.Errorf("array of hashable elements is not hashable")
+	}
+	if _, ok := HashableKey(&Array{Elements: []Object{a, &Builtin{}}}); ok {
+		t.Errorf("array with a builtin is hashable")
+	}
+}
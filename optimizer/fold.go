@@ -0,0 +1,278 @@
+// optimizer/fold.go
+
+package optimizer
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/solbero/pytonskript/ast"
+	"github.com/solbero/pytonskript/object"
+	"github.com/solbero/pytonskript/token"
+)
+
+// fold is called by ast.Modify with every node, children first, so the
+// operands of an expression are folded before the expression itself.
+func (o *optimizer) fold(node ast.Node) ast.Node {
+	if o.quoted[node] {
+		return node
+	}
+
+	switch node := node.(type) {
+	case *ast.PrefixExpression:
+		return o.foldPrefix(node)
+	case *ast.InfixExpression:
+		return o.foldInfix(node)
+	case *ast.IfExpression:
+		return o.foldIf(node)
+	case *ast.InterpolatedString:
+		return o.foldInterpolatedString(node)
+	case *ast.BlockStatement:
+		node.Statements = o.spliceBranches(node.Statements)
+	case *ast.Program:
+		node.Statements = o.spliceBranches(node.Statements)
+	}
+
+	return node
+}
+
+func (o *optimizer) foldPrefix(node *ast.PrefixExpression) ast.Node {
+	pos := node.Token.Pos
+
+	switch right := node.Right.(type) {
+	case *ast.IntegerLiteral:
+		switch node.Operator {
+		case "-":
+			o.changed = true
+			return o.integer(-right.Value, pos)
+		case "!":
+			o.changed = true
+			return o.boolean(false, pos)
+		}
+	case *ast.StringLiteral:
+		if node.Operator == "!" {
+			o.changed = true
+			return o.boolean(false, pos)
+		}
+	case *ast.Boolean:
+		if node.Operator == "!" {
+			o.changed = true
+			return o.boolean(!right.Value, pos)
+		}
+	}
+
+	return node
+}
+
+// foldInfix folds operators on two literals of the same type. Everything
+// else is left to run, including comparisons of different types, whose
+// result depends on the engine the program runs in.
+func (o *optimizer) foldInfix(node *ast.InfixExpression) ast.Node {
+	if node.Token.Type == token.NULLISH {
+		return o.foldNullish(node)
+	}
+
+	pos := node.Token.Pos
+	var folded ast.Expression
+
+	switch left := node.Left.(type) {
+	case *ast.IntegerLiteral:
+		right, ok := node.Right.(*ast.IntegerLiteral)
+		if !ok {
+			return node
+		}
+		l, r := left.Value, right.Value
+		switch node.Operator {
+		case "+":
+			folded = o.integer(l+r, pos)
+		case "-":
+			folded = o.integer(l-r, pos)
+		case "*":
+			folded = o.integer(l*r, pos)
+		case "/":
+			if r != 0 {
+				folded = o.integer(l/r, pos)
+			}
+		case "<":
+			folded = o.boolean(l < r, pos)
+		case ">":
+			folded = o.boolean(l > r, pos)
+		case "==":
+			folded = o.boolean(l == r, pos)
+		case "!=":
+			folded = o.boolean(l != r, pos)
+		}
+
+	case *ast.StringLiteral:
+		right, ok := node.Right.(*ast.StringLiteral)
+		if !ok {
+			return node
+		}
+		switch node.Operator {
+		case "+":
+			folded = o.string(left.Value+right.Value, pos)
+		case "==":
+			folded = o.boolean(left.Value == right.Value, pos)
+		case "!=":
+			folded = o.boolean(left.Value != right.Value, pos)
+		}
+
+	case *ast.Boolean:
+		right, ok := node.Right.(*ast.Boolean)
+		if !ok {
+			return node
+		}
+		switch node.Operator {
+		case "==":
+			folded = o.boolean(left.Value == right.Value, pos)
+		case "!=":
+			folded = o.boolean(left.Value != right.Value, pos)
+		}
+	}
+
+	if folded == nil {
+		return node
+	}
+	o.changed = true
+	return folded
+}
+
+// foldInterpolatedString joins a string whose expressions are all literals
+// into one string literal, writing each the way it is shown when the
+// program runs.
+func (o *optimizer) foldInterpolatedString(node *ast.InterpolatedString) ast.Node {
+	var out strings.Builder
+
+	for _, part := range node.Parts {
+		switch part := part.(type) {
+		case *ast.StringLiteral:
+			out.WriteString(part.Value)
+		case *ast.IntegerLiteral:
+			out.WriteString(strconv.FormatInt(part.Value, 10))
+		case *ast.Boolean:
+			out.WriteString((&object.Boolean{Value: part.Value}).Inspect())
+		default:
+			return node
+		}
+	}
+
+	o.changed = true
+	return o.string(out.String(), node.Token.Pos)
+}
+
+// foldIf removes the branch of an if expression with a literal condition
+// that can never run. If the branch that is left is a single expression, the
+// expression replaces the whole if expression.
+func (o *optimizer) foldIf(node *ast.IfExpression) ast.Node {
+	truthy, ok := isTruthy(node.Condition)
+	if !ok {
+		return node
+	}
+
+	switch {
+	case truthy && node.Alternative != nil:
+		node.Alternative = nil
+		o.changed = true
+	case !truthy && node.Alternative != nil:
+		node.Condition = o.boolean(true, node.Token.Pos)
+		node.Consequence, node.Alternative = node.Alternative, nil
+		o.changed = true
+	case !truthy:
+		// The value is null, which has no literal, so the if expression is
+		// kept without the branch.
+		if len(node.Consequence.Statements) != 0 {
+			node.Consequence = &ast.BlockStatement{Token: node.Consequence.Token, Rbrace: node.Consequence.Rbrace}
+			o.changed = true
+		}
+		return node
+	}
+
+	if len(node.Consequence.Statements) == 1 {
+		if stmt, ok := node.Consequence.Statements[0].(*ast.ExpressionStatement); ok {
+			o.changed = true
+			return stmt.Expression
+		}
+	}
+
+	return node
+}
+
+// foldNullish picks the side of ?? that is the result when the left side is
+// a literal.
+func (o *optimizer) foldNullish(node *ast.InfixExpression) ast.Node {
+	switch {
+	case isNull(node.Left):
+		o.changed = true
+		return node.Right
+	case isLiteral(node.Left):
+		o.changed = true
+		return node.Left
+	default:
+		return node
+	}
+}
+
+// spliceBranches replaces if statements with a literal condition by the
+// statements of the branch that runs. Blocks do not make scopes, so this
+// does not change what names mean. An if statement that runs nothing is
+// removed, unless it is the last statement, whose value is the value of the
+// block.
+func (o *optimizer) spliceBranches(stmts []ast.Statement) []ast.Statement {
+	result := make([]ast.Statement, 0, len(stmts))
+
+	for i, stmt := range stmts {
+		branch, ok := o.constantBranch(stmt)
+		last := i == len(stmts)-1
+
+		switch {
+		case !ok:
+			result = append(result, stmt)
+		case branch == nil && !last:
+			o.changed = true
+		case branch == nil || (last && len(branch.Statements) == 0):
+			result = append(result, stmt)
+		default:
+			result = append(result, branch.Statements...)
+			o.changed = true
+		}
+	}
+
+	return result
+}
+
+// constantBranch reports whether stmt is an if statement with a literal
+// condition, and returns the branch that runs, or nil if none does.
+func (o *optimizer) constantBranch(stmt ast.Statement) (*ast.BlockStatement, bool) {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok || o.quoted[es] {
+		return nil, false
+	}
+	ie, ok := es.Expression.(*ast.IfExpression)
+	if !ok {
+		return nil, false
+	}
+	truthy, ok := isTruthy(ie.Condition)
+	if !ok {
+		return nil, false
+	}
+
+	if truthy {
+		return ie.Consequence, true
+	}
+	return ie.Alternative, true
+}
+
+// isTruthy reports whether the condition is a literal, and if it is, whether
+// it is true. Every integer and string is true, and ingenting is false.
+func isTruthy(condition ast.Expression) (bool, bool) {
+	switch condition := condition.(type) {
+	case *ast.Boolean:
+		return condition.Value, true
+	case *ast.IntegerLiteral, *ast.StringLiteral:
+		return true, true
+	case *ast.NullLiteral:
+		return false, true
+	default:
+		return false, false
+	}
+}
@@ -0,0 +1,71 @@
+// optimizer/inline.go
+
+package optimizer
+
+import (
+	"github.com/solbero/pytonskript/ast"
+)
+
+// inline replaces names bound to a literal by the literal. A name is only
+// replaced if it is bound once in the whole program, so it cannot mean
+// anything else anywhere, and only in the statements after its la statement
+// in the same program or function body, since those are the only ones that
+// are sure to run after it. The la statement is kept, for the code before it
+// that uses the name when it is called later.
+func (o *optimizer) inline(program *ast.Program) {
+	bindings := map[string]int{}
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			bindings[node.Name.Value]++
+		case *ast.FunctionLiteral:
+			for _, param := range node.Parameters {
+				bindings[param.Value]++
+			}
+		case *ast.MacroLiteral:
+			for _, param := range node.Parameters {
+				bindings[param.Value]++
+			}
+		}
+		return true
+	})
+
+	o.inlineStatements(program.Statements, bindings)
+}
+
+func (o *optimizer) inlineStatements(stmts []ast.Statement, bindings map[string]int) {
+	values := map[string]ast.Expression{}
+
+	for _, stmt := range stmts {
+		if len(values) > 0 {
+			ast.Modify(stmt, func(node ast.Node) ast.Node {
+				ident, ok := node.(*ast.Identifier)
+				if !ok || o.quoted[ident] {
+					return node
+				}
+				value, ok := values[ident.Value]
+				if !ok {
+					return node
+				}
+				o.changed = true
+				return o.copyLiteral(value, ident.Token.Pos)
+			})
+		}
+
+		ast.Inspect(stmt, func(node ast.Node) bool {
+			if o.quoted[node] {
+				return false
+			}
+			if fn, ok := node.(*ast.FunctionLiteral); ok {
+				o.inlineStatements(fn.Body.Statements, bindings)
+				return false
+			}
+			return true
+		})
+
+		if let, ok := stmt.(*ast.LetStatement); ok && bindings[let.Name.Value] == 1 && isLiteral(let.Value) && !o.quoted[let] {
+			values[let.Name.Value] = let.Value
+		}
+	}
+}
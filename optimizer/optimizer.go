@@ -0,0 +1,138 @@
+// optimizer/optimizer.go
+
+// Package optimizer rewrites programs so that they do less work when they
+// run, without changing what they do. It folds expressions whose operands
+// are literals, removes the branches of hvis that can never run and replaces
+// names bound once with la to a literal by the literal.
+package optimizer
+
+import (
+	"strconv"
+
+	"github.com/solbero/pytonskript/ast"
+	"github.com/solbero/pytonskript/token"
+)
+
+// The bokmål name of sitat, whose argument is syntax and must be left as it
+// was written.
+const quoteName = "sitat"
+
+// Optimize optimizes program, which is written in dialect, and returns it.
+// The program is changed in place. Macros should be expanded first, since
+// the bodies of macros and the arguments of sitat are left alone.
+//
+// Expressions that would fail when they run, such as division by zero or
+// adding a string to an integer, are not folded, so the program fails the
+// same way it would have without the optimizer.
+func Optimize(program *ast.Program, dialect *token.Dialect) *ast.Program {
+	o := &optimizer{dialect: dialect, quoted: quotedNodes(program, dialect)}
+
+	for {
+		o.changed = false
+		program, _ = ast.Modify(program, o.fold).(*ast.Program)
+		o.inline(program)
+		if !o.changed {
+			return program
+		}
+	}
+}
+
+type optimizer struct {
+	dialect *token.Dialect
+
+	// quoted holds the nodes that must not be changed.
+	quoted map[ast.Node]bool
+
+	// changed is set when a pass changes the program, since that may let
+	// the other pass do more.
+	changed bool
+}
+
+// quotedNodes returns every node in the bodies of macros and the arguments
+// of sitat.
+func quotedNodes(program *ast.Program, dialect *token.Dialect) map[ast.Node]bool {
+	quoted := map[ast.Node]bool{}
+
+	mark := func(node ast.Node) {
+		ast.Inspect(node, func(n ast.Node) bool {
+			quoted[n] = true
+			return true
+		})
+	}
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.MacroLiteral:
+			mark(node)
+			return false
+		case *ast.CallExpression:
+			if isQuote(node, dialect) {
+				mark(node)
+				return false
+			}
+		}
+		return true
+	})
+
+	return quoted
+}
+
+func isQuote(call *ast.CallExpression, dialect *token.Dialect) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return false
+	}
+	name, ok := dialect.Builtin(ident.Value)
+	return ok && name == quoteName
+}
+
+// isLiteral reports whether node is a literal the optimizer can fold.
+func isLiteral(node ast.Node) bool {
+	switch node.(type) {
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean:
+		return true
+	default:
+		return false
+	}
+}
+
+func isNull(node ast.Node) bool {
+	_, ok := node.(*ast.NullLiteral)
+	return ok
+}
+
+// The literals the optimizer makes get the position of the node they
+// replace.
+
+func (o *optimizer) integer(value int64, pos token.Position) *ast.IntegerLiteral {
+	literal := strconv.FormatInt(value, 10)
+	return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: literal, Pos: pos}, Value: value}
+}
+
+func (o *optimizer) string(value string, pos token.Position) *ast.StringLiteral {
+	return &ast.StringLiteral{Token: token.Token{Type: token.STRING, Literal: value, Pos: pos}, Value: value}
+}
+
+func (o *optimizer) boolean(value bool, pos token.Position) *ast.Boolean {
+	tokenType := token.TokenType(token.FALSE)
+	if value {
+		tokenType = token.TRUE
+	}
+	literal, _ := o.dialect.Keyword(tokenType)
+	return &ast.Boolean{Token: token.Token{Type: tokenType, Literal: literal, Pos: pos}, Value: value}
+}
+
+// copyLiteral returns a new literal with the value of literal and the
+// position pos.
+func (o *optimizer) copyLiteral(literal ast.Expression, pos token.Position) ast.Expression {
+	switch literal := literal.(type) {
+	case *ast.IntegerLiteral:
+		return o.integer(literal.Value, pos)
+	case *ast.StringLiteral:
+		return o.string(literal.Value, pos)
+	case *ast.Boolean:
+		return o.boolean(literal.Value, pos)
+	default:
+		return literal
+	}
+}
@@ -0,0 +1,122 @@
+// optimizer/optimizer_test.go
+
+package optimizer
+
+import (
+	"testing"
+
+	"github.com/solbero/pytonskript/ast"
+	"github.com/solbero/pytonskript/lexer"
+	"github.com/solbero/pytonskript/parser"
+	"github.com/solbero/pytonskript/token"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// Folding
+		{"1 + 2 * 3;", "7"},
+		{"-(2 - 5);", "3"},
+		{"10 / 3 - 1;", "2"},
+		{"1 < 2;", "sant"},
+		{"1 == 2 != sant;", "sant"},
+		{"!5;", "falskt"},
+		{"!!sant;", "sant"},
+		{`"Hei" + ", " + "verden";`, `Hei, verden`},
+		{`"a" == "b";`, "falskt"},
+		{"x + 1 * 2;", "(x + 2)"},
+		{`"a{1 + 2}b{"c"}";`, "a3bc"},
+		{`"a{x}b";`, "a{x}b"},
+		{`"a{sant}{!sant}";`, "asantfalskt"},
+		{`la navn = "Kari"; "Hei {navn}!";`, "la navn = Kari;Hei Kari!"},
+		{"ingenting ?? 1 + 2;", "3"},
+		{`"a" ?? skriv(1);`, "a"},
+		{"x ?? 1;", "(x ?? 1)"},
+		{"la a = hvis (ingenting) { 10 } ellers { 20 };", "la a = 20;"},
+
+		// Expressions that fail when they run are left alone
+		{"1 / 0;", "(1 / 0)"},
+		{`1 + "a";`, "(1 + a)"},
+		{"-sant;", "(-sant)"},
+		{`"a" < "b";`, "(a < b)"},
+		{"1 == sant;", "(1 == sant)"},
+
+		// Branches
+		{"la a = hvis (1 < 2) { 10 } ellers { 20 };", "la a = 10;"},
+		{"la a = hvis (falskt) { 10 } ellers { 20 };", "la a = 20;"},
+		{"la a = hvis (0) { 10 };", "la a = 10;"},
+		{"la a = hvis (falskt) { 10 };", "la a = iffalskt ;"},
+		{"la a = hvis (falskt) { 10 } ellers { skriv(1); 2 };", "la a = ifsant skriv(1)2;"},
+		{"hvis (sant) { skriv(1); skriv(2) }; 3;", "skriv(1)skriv(2)3"},
+		{"hvis (falskt) { skriv(1) }; 3;", "3"},
+		{"hvis (x) { 1 } ellers { 2 };", "ifx 1else 2"},
+
+		// Inlining
+		{"la a = 2 * 3; a * 7;", "la a = 6;42"},
+		{"la a = 1; la b = a + 1; b * 2;", "la a = 1;la b = 2;4"},
+		{"la a = 1; la f = funksjon() { a + 1 };", "la a = 1;la f = funksjon() 2;"},
+		{"la f = funksjon() { a }; la a = 1; f();", "la f = funksjon() a;la a = 1;f()"},
+		{"la a = 1; la a = 2; a;", "la a = 1;la a = 2;a"},
+		{"la a = 1; la f = funksjon(a) { a }; a;", "la a = 1;la f = funksjon(a) a;a"},
+		{"hvis (x) { la a = 1; }; a;", "ifx la a = 1;a"},
+		{"la a = x; a;", "la a = x;a"},
+		{"la f = funksjon() { la a = 2; a * a };", "la f = funksjon() la a = 2;4;"},
+		{"la a = sant; hvis (a) { 1 } ellers { 2 };", "la a = sant;1"},
+
+		// Quotes
+		{"sitat(1 + 2);", "sitat((1 + 2))"},
+		{"la a = 1; sitat(a);", "la a = 1;sitat(a)"},
+	}
+
+	for _, tt := range tests {
+		program := Optimize(parse(t, tt.input, token.Bokmal), token.Bokmal)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program for %q. expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestOptimizeDialect(t *testing.T) {
+	program := Optimize(parse(t, `lat a = 1 < 2; quote(1 + 1); "{usann}";`, token.Nynorsk), token.Nynorsk)
+
+	expected := "lat a = sann;quote(2)falskt"
+	if program.String() != expected {
+		t.Errorf("wrong program. expected=%q, got=%q", expected, program.String())
+	}
+
+	program = Optimize(parse(t, "let a = 1 < 2; quote(1 + 1);", token.English), token.English)
+
+	expected = "let a = true;quote((1 + 1))"
+	if program.String() != expected {
+		t.Errorf("wrong program. expected=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestOptimizePositions(t *testing.T) {
+	program := Optimize(parse(t, "la a = 5;\nskriv(2 * a);", token.Bokmal), token.Bokmal)
+
+	call := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	literal, ok := call.Arguments[0].(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("argument is not *ast.IntegerLiteral. got=%T", call.Arguments[0])
+	}
+
+	expected := token.Position{Offset: 18, Line: 2, Column: 9}
+	if literal.Value != 10 || literal.Token.Literal != "10" || literal.Token.Pos != expected {
+		t.Errorf("wrong literal. got value=%d, token=%+v", literal.Value, literal.Token)
+	}
+}
+
+func parse(t *testing.T, input string, dialect *token.Dialect) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.NewWithDialect(input, dialect))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
@@ -3,10 +3,10 @@
 package parser
 
 import (
-	"fmt"
 	"strconv"
 
 	"github.com/solbero/pytonskript/ast"
+	"github.com/solbero/pytonskript/diagnostic"
 	"github.com/solbero/pytonskript/lexer"
 	"github.com/solbero/pytonskript/token"
 )
@@ -14,7 +14,9 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	NULLISH     // x ?? y
 	EQUALS      // ==
+	MEMBER      // x i liste
 	LESSGREATER // > or <
 	SUM         // +
 	PRODUCT     // *
@@ -24,8 +26,10 @@ const (
 )
 
 var precedences = map[token.TokenType]int{
+	token.NULLISH:  NULLISH,
 	token.EQ:       EQUALS,
 	token.NOT_EQ:   EQUALS,
+	token.IN:       MEMBER,
 	token.LT:       LESSGREATER,
 	token.GT:       LESSGREATER,
 	token.PLUS:     SUM,
@@ -34,6 +38,8 @@ var precedences = map[token.TokenType]int{
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+
+	token.OPTIONAL_LBRACKET: INDEX,
 }
 
 type (
@@ -44,9 +50,12 @@ type (
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:      l,
-		errors: []string{},
+		errors: []*diagnostic.Diagnostic{},
 	}
 
+	p.errors = append(p.errors, l.Errors()...)
+	p.lexed = len(l.Errors())
+
 	// Register prefix parse functions for the parser
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
@@ -55,10 +64,15 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.NULL, p.parseNullLiteral)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.RAW_STRING, p.parseStringLiteral)
+	p.registerPrefix(token.STRING_START, p.parseInterpolatedString)
+	p.registerPrefix(token.ILLEGAL, p.parseIllegal)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 
@@ -72,8 +86,11 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.IN, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.OPTIONAL_LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.NULLISH, p.parseInfixExpression)
 
 	// Read two tokens, so curToken and peekToken are both set
 	p.nextToken()
@@ -84,11 +101,18 @@ func New(l *lexer.Lexer) *Parser {
 
 type Parser struct {
 	l      *lexer.Lexer
-	errors []string
+	errors []*diagnostic.Diagnostic
 
 	curToken  token.Token
 	peekToken token.Token
 
+	// lexed is the number of errors from the lexer that have been added to
+	// errors. curReported and peekReported tell whether the lexer found an
+	// error in the token, so that it is not reported again.
+	lexed        int
+	curReported  bool
+	peekReported bool
+
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 }
@@ -106,7 +130,7 @@ func (p *Parser) ParseProgram() *ast.Program {
 	return program
 }
 
-func (p *Parser) Errors() []string {
+func (p *Parser) Errors() []*diagnostic.Diagnostic {
 	return p.errors
 }
 
@@ -120,7 +144,13 @@ func (p *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
+	p.curReported = p.peekReported
 	p.peekToken = p.l.NextToken()
+
+	errors := p.l.Errors()
+	p.peekReported = len(errors) > p.lexed
+	p.errors = append(p.errors, errors[p.lexed:]...)
+	p.lexed = len(errors)
 }
 
 func (p *Parser) parseStatement() ast.Statement {
@@ -196,6 +226,8 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		p.nextToken()
 	}
 
+	block.Rbrace = p.curToken
+
 	return block
 }
 
@@ -206,7 +238,7 @@ func (p *Parser) parseIdentifier() ast.Expression {
 func (p *Parser) parseExpression(precedence int) ast.Expression {
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
-		p.noPrefixParseError(p.curToken.Type)
+		p.noPrefixParseError(p.curToken)
 		return nil
 	}
 
@@ -226,18 +258,24 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	return leftExp
 }
 
-func (p *Parser) noPrefixParseError(t token.TokenType) {
-	msg := fmt.Sprintf("No prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+func (p *Parser) noPrefixParseError(t token.Token) {
+	err := diagnostic.New(diagnostic.UnexpectedToken, t.Pos, diagnostic.TokenName(t.Type))
+	p.errors = append(p.errors, err)
 }
 
-func (p *Parser) peekPrecedence() int {
-	if p, ok := precedences[p.peekToken.Type]; ok {
+// Precedence returns how tightly the infix operator t binds, or LOWEST if t
+// is not an infix operator.
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
 		return p
 	}
 	return LOWEST
 }
 
+func (p *Parser) peekPrecedence() int {
+	return Precedence(p.peekToken.Type)
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression {
 	expression := &ast.PrefixExpression{
 		Token:    p.curToken,
@@ -265,10 +303,7 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 }
 
 func (p *Parser) curPrecedence() int {
-	if p, ok := precedences[p.curToken.Type]; ok {
-		return p
-	}
-	return LOWEST
+	return Precedence(p.curToken.Type)
 }
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
@@ -362,6 +397,24 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	lit.Parameters = p.parseFunctionParameters()
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	lit.Body = p.parseBlockStatement()
+
+	return lit
+}
+
 func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	identifiers := []*ast.Identifier{}
 
@@ -407,8 +460,8 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("Could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		err := diagnostic.New(diagnostic.InvalidInteger, p.curToken.Pos, p.curToken.Literal)
+		p.errors = append(p.errors, err)
 		return nil
 	}
 
@@ -421,6 +474,43 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+// parseIllegal reports a token the lexer could not read, unless the lexer
+// already has.
+func (p *Parser) parseIllegal() ast.Expression {
+	if !p.curReported {
+		p.noPrefixParseError(p.curToken)
+	}
+	return nil
+}
+
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.curToken}
+	str.Parts = append(str.Parts, p.parseStringLiteral())
+
+	for {
+		if p.peekTokenIs(token.STRING_MIDDLE) || p.peekTokenIs(token.STRING_END) {
+			// An empty {}, which is reported without losing track of the
+			// rest of the string.
+			p.noPrefixParseError(p.peekToken)
+			str.Parts = append(str.Parts, nil)
+		} else {
+			p.nextToken()
+			str.Parts = append(str.Parts, p.parseExpression(LOWEST))
+		}
+
+		switch {
+		case p.peekTokenIs(token.STRING_MIDDLE):
+			p.nextToken()
+			str.Parts = append(str.Parts, p.parseStringLiteral())
+		case p.expectPeek(token.STRING_END):
+			str.Parts = append(str.Parts, p.parseStringLiteral())
+			return str
+		default:
+			return nil
+		}
+	}
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 
@@ -430,8 +520,7 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 }
 
 func (p *Parser) parseHashLiteral() ast.Expression {
-	hash := &ast.HashLiteral{Token: p.curToken}
-	hash.Pairs = make(map[ast.Expression]ast.Expression)
+	hash := &ast.HashLiteral{Token: p.curToken, Pairs: []ast.HashPair{}}
 
 	for !p.peekTokenIs(token.RBRACE) {
 		p.nextToken()
@@ -443,7 +532,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 
 		value := p.parseExpression(LOWEST)
-		hash.Pairs[key] = value
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -462,6 +551,10 @@ func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
 
+func (p *Parser) parseNullLiteral() ast.Expression {
+	return &ast.NullLiteral{Token: p.curToken}
+}
+
 func (p *Parser) curTokenIs(t token.TokenType) bool {
 	return p.curToken.Type == t
 }
@@ -481,6 +574,11 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("Expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	if p.peekReported {
+		return
+	}
+	expected := diagnostic.TokenName(t)
+	actual := diagnostic.TokenName(p.peekToken.Type)
+	err := diagnostic.New(diagnostic.ExpectedToken, p.peekToken.Pos, expected, actual)
+	p.errors = append(p.errors, err)
 }
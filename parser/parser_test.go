@@ -3,7 +3,11 @@
 package parser
 
 import (
+	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/solbero/pytonskript/ast"
@@ -247,6 +251,14 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"add(a + b + c * d / f + g)", "add((((a + b) + ((c * d) / f)) + g))"},
 		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
 		{"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		{"a + 1 i b", "((a + 1) i b)"},
+		{"a i b == c i d", "((a i b) == (c i d))"},
+		{"a < b i c", "((a < b) i c)"},
+		{"!a i b", "((!a) i b)"},
+		{"a ?? b == c", "(a ?? (b == c))"},
+		{"a ?? b ?? c + 1", "((a ?? b) ?? (c + 1))"},
+		{"a?[1]?[2][3]", "(((a?[1])?[2])[3])"},
+		{"f(ingenting)?[x ?? 0]", "(f(ingenting)?[(x ?? 0)])"},
 	}
 
 	for _, tt := range tests {
@@ -403,7 +415,8 @@ func TestStringLiteralExpression(t *testing.T) {
 		{input: `"hello\nworld"`, expected: "hello\nworld"},
 		{input: `"hello\t\t\tworld"`, expected: "hello\t\t\tworld"},
 		{input: `"hello\\world"`, expected: "hello\\world"},
-		{input: `"hello\bworld"`, expected: "helloworld"},
+		{input: `"hello\u{1F600}"`, expected: "hello\U0001F600"},
+		{input: "`C:\\mappe\\{navn}\n  \"linje to\"`", expected: "C:\\mappe\\{navn}\n  \"linje to\""},
 		{input: `"spis blåbærsyltetøy"`, expected: "spis blåbærsyltetøy"},
 	}
 
@@ -424,6 +437,41 @@ func TestStringLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestInterpolatedStringParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`"Hei {navn}, du er {alder + 1} år"`, []string{"Hei ", "navn", ", du er ", "(alder + 1)", " år"}},
+		{`"{a}"`, []string{"", "a", ""}},
+		{`"\{{"{b}"}\}"`, []string{"{", "{b}", "}"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		str, ok := stmt.Expression.(*ast.InterpolatedString)
+		if !ok {
+			t.Fatalf("exp not *ast.InterpolatedString, got %T", stmt.Expression)
+		}
+		if len(str.Parts) != len(tt.expected) {
+			t.Fatalf("wrong number of parts for %s, expected %d, got %d", tt.input, len(tt.expected), len(str.Parts))
+		}
+		for i, part := range str.Parts {
+			if _, ok := part.(*ast.StringLiteral); i%2 == 0 && !ok {
+				t.Errorf("part %d of %s is not *ast.StringLiteral, got %T", i, tt.input, part)
+			}
+			if part.String() != tt.expected[i] {
+				t.Errorf("part %d of %s wrong, expected %q, got %q", i, tt.input, tt.expected[i], part.String())
+			}
+		}
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `funksjon(x, y) { x + y; }`
 
@@ -465,6 +513,47 @@ func TestFunctionLiteralParsing(t *testing.T) {
 	checkInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
 
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `makro(x, y) { x + y; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program has not enough statements, got %d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement, got %T", program.Statements[0])
+	}
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral, got %T", stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong, want 2, got %d", len(macro.Parameters))
+	}
+
+	checkLiteralExpression(t, macro.Parameters[0], "x")
+	checkLiteralExpression(t, macro.Parameters[1], "y")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not enough statements, got %d", len(macro.Body.Statements))
+	}
+
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement, got %T", macro.Body.Statements[0])
+	}
+
+	checkInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
 func TestFunctionParameterParsing(t *testing.T) {
 	tests := []struct {
 		input          string
@@ -598,7 +687,8 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 		"three": 3,
 	}
 
-	for key, value := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
 		literal, ok := key.(*ast.StringLiteral)
 		if !ok {
 			t.Errorf("key is not ast.StringLiteral, got %T", key)
@@ -632,7 +722,8 @@ func TestParsingHashLiteralsBooleanKeys(t *testing.T) {
 		"falskt": 2,
 	}
 
-	for key, value := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
 		boolean, ok := key.(*ast.Boolean)
 		if !ok {
 			t.Errorf("key is not ast.Boolean, got %T", key)
@@ -667,7 +758,8 @@ func TestParsingHashLiteralsIntegerKeys(t *testing.T) {
 		"3": 3,
 	}
 
-	for key, value := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
 		integer, ok := key.(*ast.IntegerLiteral)
 		if !ok {
 			t.Errorf("key is not ast.IntegerLiteral, got %T", key)
@@ -708,7 +800,8 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 		},
 	}
 
-	for key, value := range hash.Pairs {
+	for _, pair := range hash.Pairs {
+		key, value := pair.Key, pair.Value
 		literal, ok := key.(*ast.StringLiteral)
 		if !ok {
 			t.Errorf("key is not ast.StringLiteral, got %T", key)
@@ -743,6 +836,63 @@ func TestParsingEmptyHashLiteral(t *testing.T) {
 	}
 }
 
+func TestParserErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"la = 5;", "1:4: forventet et navn, men fant '=' [S001]"},
+		{"la x 5;", "1:6: forventet '=', men fant et heltall [S001]"},
+		{"la x = 5;\nfoo(1, 2;", "2:9: forventet ')', men fant ';' [S001]"},
+		{"la x = );", "1:8: uventet ')' i starten av et uttrykk [S002]"},
+		{"99999999999999999999", "1:1: kunne ikke tolke \"99999999999999999999\" som et heltall [S003]"},
+		{`"a{}b"`, "1:4: uventet '}' i starten av et uttrykk [S002]"},
+		{`"a{b c}"`, "1:6: forventet '}', men fant et navn [S001]"},
+		{`"a{b`, `1:3: uttrykket i strengen blir aldri avsluttet med '}', skriv \{ for en krøllparentes i teksten [S008]`},
+		{`"a\qb"`, `1:3: ukjent escape-sekvens i strengen: \q [S005]`},
+		{`"\u{zz}"`, `1:2: ugyldig Unicode-escape i strengen: \u{, skriv for eksempel \u{e5} [S006]`},
+		{`"\u{110000}"`, `1:2: ugyldig Unicode-escape i strengen: \u{110000}, skriv for eksempel \u{e5} [S006]`},
+		{`"\u00e5"`, `1:2: ugyldig Unicode-escape i strengen: \u, skriv for eksempel \u{e5} [S006]`},
+		{`la x = "abc`, `1:8: strengen blir aldri avsluttet, mangler '"' [S007]`},
+		{"la x = `abc\ndef", "1:8: strengen blir aldri avsluttet, mangler '`' [S007]"},
+		{`"a{b}c`, `1:5: strengen blir aldri avsluttet, mangler '"' [S007]`},
+		{"la x = 1 @", "1:10: uventet ugyldig tegn i starten av et uttrykk [S002]"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("parser has no errors for %q", tt.input)
+			continue
+		}
+
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong first error for %q, expected %q, got %q", tt.input, tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestIllegalStringReportedOnce(t *testing.T) {
+	inputs := []string{
+		`la x = "a\qb";`,
+		`skriv("a\qb", 1);`,
+		"la x = `abc",
+	}
+
+	for _, input := range inputs {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		if len(p.Errors()) != 1 {
+			t.Errorf("expected 1 error for %q, got %d: %v", input, len(p.Errors()), p.Errors())
+		}
+	}
+}
+
 func checkLetStatement(t *testing.T, s ast.Statement, name string) bool {
 	t.Helper()
 	if s.TokenLiteral() != "la" {
@@ -867,3 +1017,58 @@ func checkParserErrors(t *testing.T, p *Parser) {
 	}
 	t.FailNow()
 }
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestGolden parses every program in testdata and compares its syntax tree,
+// as JSON, with the .json file next to it. Run the tests with -update to
+// write the files after changing the parser.
+func TestGolden(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.pytonskript"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no programs in testdata: %v", err)
+	}
+
+	for _, path := range paths {
+		input, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("could not read %s: %s", path, err)
+		}
+
+		p := New(lexer.New(string(input)))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		actual, err := ast.EncodeJSON(program)
+		if err != nil {
+			t.Fatalf("%s: encode error: %s", path, err)
+		}
+		actual = append(actual, '\n')
+
+		golden := strings.TrimSuffix(path, ".pytonskript") + ".json"
+		if *update {
+			if err := os.WriteFile(golden, actual, 0644); err != nil {
+				t.Fatalf("could not write %s: %s", golden, err)
+			}
+			continue
+		}
+
+		expected, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("could not read %s, run with -update to write it: %s", golden, err)
+		}
+
+		if string(actual) != string(expected) {
+			t.Errorf("%s: syntax tree differs from %s:\n%s", path, golden, actual)
+		}
+
+		decoded, err := ast.DecodeJSON(expected)
+		if err != nil {
+			t.Fatalf("%s: decode error: %s", golden, err)
+		}
+		again, _ := ast.EncodeJSON(decoded)
+		if string(again)+"\n" != string(expected) {
+			t.Errorf("%s: encoding the decoded program differs:\n%s", golden, again)
+		}
+	}
+}
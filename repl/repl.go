@@ -7,10 +7,14 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/solbero/pytonskript/compiler"
+	"github.com/solbero/pytonskript/diagnostic"
 	"github.com/solbero/pytonskript/evaluator"
 	"github.com/solbero/pytonskript/lexer"
 	"github.com/solbero/pytonskript/object"
 	"github.com/solbero/pytonskript/parser"
+	"github.com/solbero/pytonskript/token"
+	"github.com/solbero/pytonskript/vm"
 )
 
 const PROMPT = ">> "
@@ -28,9 +32,12 @@ const MONKEY_FACE = `            __,__
            '-----'
 `
 
-func Start(in io.Reader, out io.Writer) {
+func Start(in io.Reader, out io.Writer, dialect *token.Dialect) {
 	scanner := bufio.NewScanner(in)
 	env := object.NewEnvironment()
+	env.SetDialect(dialect)
+	macroEnv := object.NewEnvironment()
+	macroEnv.SetDialect(dialect)
 
 	for {
 		fmt.Fprint(out, PROMPT)
@@ -40,7 +47,7 @@ func Start(in io.Reader, out io.Writer) {
 		}
 
 		line := scanner.Text()
-		l := lexer.New(line)
+		l := lexer.NewWithDialect(line, dialect)
 		p := parser.New(l)
 
 		program := p.ParseProgram()
@@ -49,7 +56,14 @@ func Start(in io.Reader, out io.Writer) {
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
+		evaluator.DefineMacros(program, macroEnv)
+		expanded, errObj := evaluator.ExpandMacros(program, macroEnv)
+		if errObj != nil {
+			io.WriteString(out, errObj.Inspect()+"\n")
+			continue
+		}
+
+		evaluated := evaluator.Eval(expanded, env)
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
@@ -57,11 +71,73 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
+// StartVM is Start with programs compiled to bytecode and run in the virtual
+// machine. Globals and constants carry over from line to line.
+func StartVM(in io.Reader, out io.Writer, dialect *token.Dialect) {
+	scanner := bufio.NewScanner(in)
+
+	constants := []object.Object{}
+	globals := make([]object.Object, vm.GlobalsSize)
+	symbolTable := compiler.NewSymbolTable()
+	macroEnv := object.NewEnvironment()
+	macroEnv.SetDialect(dialect)
+
+	for {
+		fmt.Fprint(out, PROMPT)
+		scanned := scanner.Scan()
+		if !scanned {
+			return
+		}
+
+		line := scanner.Text()
+		l := lexer.NewWithDialect(line, dialect)
+		p := parser.New(l)
+
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			printParserErrors(out, p.Errors())
+			continue
+		}
+
+		evaluator.DefineMacros(program, macroEnv)
+		expanded, errObj := evaluator.ExpandMacros(program, macroEnv)
+		if errObj != nil {
+			io.WriteString(out, errObj.Inspect()+"\n")
+			continue
+		}
+
+		comp := compiler.NewWithState(dialect, symbolTable, constants)
+		if err := comp.Compile(expanded); err != nil {
+			fmt.Fprintf(out, "%s\n", err)
+			continue
+		}
+
+		bytecode := comp.Bytecode()
+		constants = bytecode.Constants
+
+		machine := vm.NewWithGlobalsStore(bytecode, globals)
+		if err := machine.Run(); err != nil {
+			if errObj, ok := err.(*object.Error); ok {
+				io.WriteString(out, errObj.Inspect())
+			} else {
+				io.WriteString(out, err.Error())
+			}
+			io.WriteString(out, "\n")
+			continue
+		}
+
+		if result := machine.Result(); result != nil {
+			io.WriteString(out, result.Inspect())
+			io.WriteString(out, "\n")
+		}
+	}
+}
+
+func printParserErrors(out io.Writer, errors []*diagnostic.Diagnostic) {
 	// io.WriteString(out, MONKEY_FACE)
 	// io.WriteString(out, "Woops! We ran into some monkey business here!\n")
-	io.WriteString(out, "parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+	io.WriteString(out, diagnostic.Sprintf(diagnostic.LabelSyntaxError)+"\n")
+	for _, err := range errors {
+		io.WriteString(out, "\t"+err.Error()+"\n")
 	}
 }
@@ -0,0 +1,67 @@
+// rpc/rpc.go
+
+// Package rpc reads and writes messages framed with a Content-Length header,
+// as used by the Language Server Protocol and the Debug Adapter Protocol.
+package rpc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var ErrMissingLength = errors.New("rpc: message has no Content-Length header")
+
+// Read reads the body of the next message from r. It returns io.EOF if there
+// are no more messages.
+func Read(r *bufio.Reader) ([]byte, error) {
+	length := -1
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && line == "" && length == -1 {
+				return nil, io.EOF
+			}
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+
+		name, value, ok := strings.Cut(line, ":")
+		if !ok || !strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			continue
+		}
+
+		length, err = strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || length < 0 {
+			return nil, fmt.Errorf("rpc: invalid Content-Length: %s", strings.TrimSpace(value))
+		}
+	}
+
+	if length == -1 {
+		return nil, ErrMissingLength
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return body, nil
+}
+
+// Write writes body to w as one message.
+func Write(w io.Writer, body []byte) error {
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
@@ -0,0 +1,68 @@
+// rpc/rpc_test.go
+
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	messages := []string{`{"id":1}`, `{"tekst":"blåbær"}`, ``}
+
+	var buf bytes.Buffer
+	for _, msg := range messages {
+		if err := Write(&buf, []byte(msg)); err != nil {
+			t.Fatalf("Write failed: %s", err)
+		}
+	}
+
+	r := bufio.NewReader(&buf)
+	for i, expected := range messages {
+		body, err := Read(r)
+		if err != nil {
+			t.Fatalf("messages[%d] - Read failed: %s", i, err)
+		}
+		if string(body) != expected {
+			t.Errorf("messages[%d] - wrong body, expected %q, got %q", i, expected, body)
+		}
+	}
+
+	if _, err := Read(r); err != io.EOF {
+		t.Errorf("expected io.EOF after the last message, got %v", err)
+	}
+}
+
+func TestReadHeaders(t *testing.T) {
+	input := "Content-Type: application/vscode-jsonrpc; charset=utf-8\r\ncontent-length: 2\r\n\r\n{}"
+
+	body, err := Read(bufio.NewReader(strings.NewReader(input)))
+	if err != nil {
+		t.Fatalf("Read failed: %s", err)
+	}
+	if string(body) != "{}" {
+		t.Errorf("wrong body, expected %q, got %q", "{}", body)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Content-Type: x\r\n\r\n{}", ErrMissingLength.Error()},
+		{"Content-Length: x\r\n\r\n{}", "rpc: invalid Content-Length: x"},
+		{"Content-Length: 10\r\n\r\n{}", io.ErrUnexpectedEOF.Error()},
+		{"Content-Length: 2\r\n", io.ErrUnexpectedEOF.Error()},
+	}
+
+	for i, tt := range tests {
+		_, err := Read(bufio.NewReader(strings.NewReader(tt.input)))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("tests[%d] - wrong error, expected %q, got %v", i, tt.expected, err)
+		}
+	}
+}
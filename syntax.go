@@ -0,0 +1,62 @@
+// syntax.go
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/solbero/pytonskript/ast"
+	"github.com/solbero/pytonskript/lexer"
+	"github.com/solbero/pytonskript/parser"
+	"github.com/solbero/pytonskript/token"
+)
+
+// astCommand implements `pytonskript ast [--json] fil`, which prints the
+// syntax tree of a program, one statement per line in the form the parser
+// tests use, or with --json as JSON with the type, fields and position of
+// every node.
+func astCommand(args []string, dialect *token.Dialect) int {
+	flags := flag.NewFlagSet("ast", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "skriv treet som JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "bruk: pytonskript ast [--json] fil\n")
+		return 2
+	}
+
+	path := flags.Arg(0)
+	input, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	p := parser.New(lexer.NewWithDialect(string(input), dialect))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, err := range p.Errors() {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", path, err)
+		}
+		return 1
+	}
+
+	if !*asJSON {
+		for _, stmt := range program.Statements {
+			fmt.Println(stmt.String())
+		}
+		return 0
+	}
+
+	data, err := ast.EncodeJSON(program)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println(string(data))
+	return 0
+}
@@ -0,0 +1,185 @@
+// token/dialect.go
+
+package token
+
+import (
+	"sort"
+	"strings"
+)
+
+// Dialect is a set of keywords and builtin names. Programs are written in one
+// dialect, and the evaluator always knows builtins by their bokmål names.
+type Dialect struct {
+	Name string
+
+	keywords     map[string]TokenType
+	keywordNames map[TokenType]string
+	builtins     map[string]string // name in this dialect -> name in bokmål
+	builtinNames map[string]string // name in bokmål -> name in this dialect
+}
+
+var keywordTable = []struct {
+	tokenType                TokenType
+	bokmal, nynorsk, english string
+}{
+	{FUNCTION, "funksjon", "funksjon", "fn"},
+	{LET, "la", "lat", "let"},
+	{TRUE, "sant", "sann", "true"},
+	{FALSE, "falskt", "usann", "false"},
+	{IF, "hvis", "viss", "if"},
+	{ELSE, "ellers", "elles", "else"},
+	{RETURN, "returner", "returner", "return"},
+	{MACRO, "makro", "makro", "macro"},
+	{IN, "i", "i", "in"},
+	{NULL, "ingenting", "ingenting", "null"},
+}
+
+var builtinTable = []struct {
+	bokmal, nynorsk, english string
+}{
+	{"lengde", "lengd", "len"},
+	{"første", "fyrste", "first"},
+	{"siste", "siste", "last"},
+	{"resten", "resten", "rest"},
+	{"tilføy", "legg_til", "push"},
+	{"skriv", "skriv", "puts"},
+	{"kutt", "kutt", "slice"},
+	{"streng", "streng", "str"},
+	{"sitat", "sitat", "quote"},
+	{"avsitat", "avsitat", "unquote"},
+	{"del", "del", "split"},
+	{"sett_sammen", "set_saman", "join"},
+	{"fjern_mellomrom", "fjern_mellomrom", "trim"},
+	{"store_bokstaver", "store_bokstavar", "upper"},
+	{"små_bokstaver", "små_bokstavar", "lower"},
+	{"finn", "finn", "find"},
+	{"erstatt", "byt_ut", "replace"},
+	{"starter_med", "byrjar_med", "starts_with"},
+	{"slutter_med", "sluttar_med", "ends_with"},
+	{"inneholder", "inneheld", "contains"},
+	{"gjenta", "gjenta", "repeat"},
+	{"fyll_venstre", "fyll_venstre", "pad_left"},
+	{"fyll_høyre", "fyll_høgre", "pad_right"},
+	{"tilordne", "tilordne", "map"},
+	{"filtrer", "filtrer", "filter"},
+	{"reduser", "reduser", "reduce"},
+	{"sorter", "sorter", "sort"},
+	{"reverser", "reverser", "reverse"},
+	{"alle", "alle", "all"},
+	{"noen", "nokon", "any"},
+	{"nøkler", "nøklar", "keys"},
+	{"verdier", "verdiar", "values"},
+	{"par", "par", "pairs"},
+	{"har_nøkkel", "har_nøkkel", "has_key"},
+	{"fjern", "fjern", "remove"},
+	{"slå_sammen", "slå_saman", "merge"},
+	{"formater", "formater", "format"},
+	{"skrivevalg", "skrivevalg", "print_options"},
+	{"er_ingenting", "er_ingenting", "is_null"},
+	{"type", "type", "type"},
+	{"heltall", "heltal", "int"},
+	{"desimal", "desimal", "float"},
+	{"sannhetsverdi", "sanningsverdi", "bool"},
+	{"liste", "liste", "list"},
+	{"er_tall", "er_tal", "is_number"},
+	{"er_streng", "er_streng", "is_string"},
+	{"er_liste", "er_liste", "is_list"},
+	{"er_tabell", "er_tabell", "is_hash"},
+	{"er_funksjon", "er_funksjon", "is_function"},
+}
+
+var (
+	Bokmal  = newDialect("bokmål", func(bm, nn, en string) string { return bm })
+	Nynorsk = newDialect("nynorsk", func(bm, nn, en string) string { return nn })
+	English = newDialect("engelsk", func(bm, nn, en string) string { return en })
+)
+
+// Dialects lists every dialect, with the default first.
+var Dialects = []*Dialect{Bokmal, Nynorsk, English}
+
+func newDialect(name string, pick func(bm, nn, en string) string) *Dialect {
+	d := &Dialect{
+		Name:         name,
+		keywords:     make(map[string]TokenType),
+		keywordNames: make(map[TokenType]string),
+		builtins:     make(map[string]string),
+		builtinNames: make(map[string]string),
+	}
+
+	for _, kw := range keywordTable {
+		word := pick(kw.bokmal, kw.nynorsk, kw.english)
+		d.keywords[word] = kw.tokenType
+		d.keywordNames[kw.tokenType] = word
+	}
+
+	for _, b := range builtinTable {
+		word := pick(b.bokmal, b.nynorsk, b.english)
+		d.builtins[word] = b.bokmal
+		d.builtinNames[b.bokmal] = word
+	}
+
+	return d
+}
+
+// LookupDialect finds a dialect by name or language code, e.g. "nynorsk",
+// "nn" or "english".
+func LookupDialect(name string) (*Dialect, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "bokmål", "bokmal", "nb":
+		return Bokmal, true
+	case "nynorsk", "nn":
+		return Nynorsk, true
+	case "engelsk", "english", "en", "monkey":
+		return English, true
+	default:
+		return nil, false
+	}
+}
+
+// LookupIdent returns the keyword token type for ident, or IDENT if ident is
+// not a keyword in this dialect.
+func (d *Dialect) LookupIdent(ident string) TokenType {
+	if tok, ok := d.keywords[ident]; ok {
+		return tok
+	}
+	return IDENT
+}
+
+// Keyword returns how the keyword t is spelled in this dialect.
+func (d *Dialect) Keyword(t TokenType) (string, bool) {
+	word, ok := d.keywordNames[t]
+	return word, ok
+}
+
+// Builtin returns the bokmål name of the builtin called name in this dialect.
+func (d *Dialect) Builtin(name string) (string, bool) {
+	builtin, ok := d.builtins[name]
+	return builtin, ok
+}
+
+// BuiltinName returns what the builtin with the bokmål name builtin is called
+// in this dialect.
+func (d *Dialect) BuiltinName(builtin string) (string, bool) {
+	name, ok := d.builtinNames[builtin]
+	return name, ok
+}
+
+// Keywords returns every keyword in this dialect, sorted.
+func (d *Dialect) Keywords() []string {
+	words := make([]string, 0, len(d.keywords))
+	for word := range d.keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
+
+// Builtins returns the name of every builtin in this dialect, sorted.
+func (d *Dialect) Builtins() []string {
+	names := make([]string, 0, len(d.builtins))
+	for name := range d.builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -7,11 +7,24 @@ const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
 
+	COMMENT = "COMMENT"
+
 	// Identifiers + literals
 	IDENT  = "IDENT"
 	INT    = "INT"
 	STRING = "STRING"
 
+	// A string with expressions in it, such as "a{b}c{d}e", is read as
+	// STRING_START "a", the tokens of b, STRING_MIDDLE "c", the tokens of d
+	// and STRING_END "e". STRING_MIDDLE and STRING_END start at the '}'.
+	STRING_START  = "STRING_START"
+	STRING_MIDDLE = "STRING_MIDDLE"
+	STRING_END    = "STRING_END"
+
+	// A raw string is written between backticks. It can span several lines
+	// and has no escapes or expressions.
+	RAW_STRING = "RAW_STRING"
+
 	// Operators
 	ASSIGN   = "="
 	PLUS     = "+"
@@ -23,6 +36,7 @@ const (
 	GT       = ">"
 	EQ       = "=="
 	NOT_EQ   = "!="
+	NULLISH  = "??"
 
 	// Delimiters
 	COLON     = ":"
@@ -35,6 +49,10 @@ const (
 	LBRACKET  = "["
 	RBRACKET  = "]"
 
+	// OPTIONAL_LBRACKET starts an index that gives null instead of an error
+	// when the value indexed is null.
+	OPTIONAL_LBRACKET = "?["
+
 	// Keywords
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
@@ -43,28 +61,27 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	MACRO    = "MACRO"
+	IN       = "IN"
+	NULL     = "NULL"
 )
 
-var keywords = map[string]TokenType{
-	"funksjon": FUNCTION,
-	"la":       LET,
-	"sant":     TRUE,
-	"falskt":   FALSE,
-	"hvis":     IF,
-	"ellers":   ELSE,
-	"returner": RETURN,
-}
-
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position
+}
+
+// Position is where a token starts in the source. Lines and columns are
+// counted from 1, and columns and offsets count characters rather than bytes.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
 }
 
 func LookupIdent(ident string) TokenType {
-	if tok, ok := keywords[ident]; ok {
-		return tok
-	}
-	return IDENT
+	return Bokmal.LookupIdent(ident)
 }
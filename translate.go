@@ -0,0 +1,57 @@
+// translate.go
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/solbero/pytonskript/token"
+	"github.com/solbero/pytonskript/translator"
+)
+
+// translateCommand implements `pytonskript oversett --til dialekt fil`, which
+// prints the program in fil translated to another dialect.
+func translateCommand(args []string, dialect *token.Dialect) int {
+	flags := flag.NewFlagSet("oversett", flag.ContinueOnError)
+	to := flags.String("til", "", "dialekten programmet skal oversettes til")
+	from := flags.String("fra", dialect.Name, "dialekten til programmer uten en dialekt-kommentar")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() != 1 || *to == "" {
+		fmt.Fprintf(os.Stderr, "bruk: pytonskript oversett --til bokmål|nynorsk|engelsk [--fra dialekt] fil\n")
+		return 2
+	}
+
+	toDialect, ok := token.LookupDialect(*to)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "ukjent dialekt: %s\n", *to)
+		return 2
+	}
+
+	fromDialect, ok := token.LookupDialect(*from)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "ukjent dialekt: %s\n", *from)
+		return 2
+	}
+
+	input, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	translated, errors := translator.Translate(string(input), fromDialect, toDialect)
+	if len(errors) != 0 {
+		for _, err := range errors {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", flags.Arg(0), err)
+		}
+		return 1
+	}
+
+	fmt.Print(translated)
+	return 0
+}
@@ -0,0 +1,158 @@
+// translator/translator.go
+
+package translator
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/solbero/pytonskript/diagnostic"
+	"github.com/solbero/pytonskript/lexer"
+	"github.com/solbero/pytonskript/token"
+)
+
+// Translate rewrites the keywords and builtin names of input from the dialect
+// it is written in to the dialect to. The input is read in from unless it has
+// a dialect directive of its own. Identifiers, strings, comments and
+// whitespace are left untouched, and so is a builtin name that the program
+// binds itself with la or as a parameter.
+//
+// The directive of the result names to. Input without a directive gets one,
+// unless to is bokmål. Identifiers that are keywords or builtins in to cannot
+// be translated and are reported as errors.
+func Translate(input string, from, to *token.Dialect) (string, []*diagnostic.Diagnostic) {
+	l := lexer.NewWithDialect(input, from)
+	if errors := l.Errors(); len(errors) != 0 {
+		return "", errors
+	}
+	from = l.Dialect()
+
+	var tokens []token.Token
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+	bound := boundNames(tokens)
+
+	var edits []edit
+	var errors []*diagnostic.Diagnostic
+
+	for _, tok := range tokens {
+		switch {
+		case tok.Type == token.IDENT:
+			builtin, ok := from.Builtin(tok.Literal)
+			if ok && !bound[tok.Literal] {
+				name, _ := to.BuiltinName(builtin)
+				edits = append(edits, replace(tok, name))
+				continue
+			}
+
+			if ok && sameBuiltinName(from, to, tok.Literal) {
+				continue
+			}
+
+			if isReserved(to, tok.Literal) {
+				err := diagnostic.New(diagnostic.ReservedName, tok.Pos, tok.Literal, to.Name)
+				errors = append(errors, err)
+			}
+		default:
+			if keyword, ok := to.Keyword(tok.Type); ok {
+				edits = append(edits, replace(tok, keyword))
+			}
+		}
+	}
+
+	if len(errors) != 0 {
+		return "", errors
+	}
+
+	directive, ok := lexer.FindDirective(input)
+	switch {
+	case ok:
+		edits = append(edits, edit{start: directive.NameStart, end: directive.NameEnd, text: to.Name})
+	case to != token.Bokmal:
+		edits = append(edits, edit{start: 0, end: 0, text: "# dialekt: " + to.Name + "\n\n"})
+	}
+
+	return apply([]rune(input), edits), nil
+}
+
+// boundNames finds the names that tokens bind with la and as the parameters of
+// functions and macros. The names are not scoped, so a name bound anywhere
+// counts as bound everywhere.
+func boundNames(tokens []token.Token) map[string]bool {
+	bound := map[string]bool{}
+
+	for i := 0; i+1 < len(tokens); i++ {
+		switch tokens[i].Type {
+		case token.LET:
+			if tokens[i+1].Type == token.IDENT {
+				bound[tokens[i+1].Literal] = true
+			}
+		case token.FUNCTION, token.MACRO:
+			if tokens[i+1].Type != token.LPAREN {
+				continue
+			}
+			for j := i + 2; j < len(tokens) && tokens[j].Type != token.RPAREN; j++ {
+				if tokens[j].Type == token.IDENT {
+					bound[tokens[j].Literal] = true
+				}
+			}
+		}
+	}
+
+	return bound
+}
+
+// sameBuiltinName reports whether name is a builtin with the same name in
+// both from and to, so that an identifier called name keeps its meaning.
+func sameBuiltinName(from, to *token.Dialect, name string) bool {
+	builtin, ok := from.Builtin(name)
+	if !ok {
+		return false
+	}
+	translated, _ := to.BuiltinName(builtin)
+	return translated == name
+}
+
+// isReserved reports whether name is a keyword or builtin in dialect, which
+// would change the meaning of an identifier called name. The keyword for i
+// is not reserved, since it is only a keyword where a name cannot be.
+func isReserved(dialect *token.Dialect, name string) bool {
+	if tok := dialect.LookupIdent(name); tok != token.IDENT && tok != token.IN {
+		return true
+	}
+	_, ok := dialect.Builtin(name)
+	return ok
+}
+
+// edit replaces the characters from start up to end with text.
+type edit struct {
+	start, end int
+	text       string
+}
+
+func replace(tok token.Token, text string) edit {
+	start := tok.Pos.Offset
+	return edit{start: start, end: start + len([]rune(tok.Literal)), text: text}
+}
+
+func apply(input []rune, edits []edit) string {
+	sort.Slice(edits, func(i, j int) bool {
+		if edits[i].start != edits[j].start {
+			return edits[i].start < edits[j].start
+		}
+		return edits[i].end < edits[j].end
+	})
+
+	var out strings.Builder
+	last := 0
+
+	for _, e := range edits {
+		out.WriteString(string(input[last:e.start]))
+		out.WriteString(e.text)
+		last = e.end
+	}
+	out.WriteString(string(input[last:]))
+
+	return out.String()
+}
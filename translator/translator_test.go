@@ -0,0 +1,129 @@
+// translator/translator_test.go
+
+package translator
+
+import (
+	"testing"
+
+	"github.com/solbero/pytonskript/diagnostic"
+	"github.com/solbero/pytonskript/token"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		input    string
+		from     *token.Dialect
+		to       *token.Dialect
+		expected string
+	}{
+		{
+			input:    "la x = hvis (sant) { 1 } ellers { 2 };",
+			from:     token.Bokmal,
+			to:       token.Nynorsk,
+			expected: "# dialekt: nynorsk\n\nlat x = viss (sann) { 1 } elles { 2 };",
+		},
+		{
+			input: `# dialekt: nynorsk
+# Reknar ut lengda av lista
+lat lengd_av   = funksjon(liste) {
+    returner lengd(liste);   # lengd er innebygd
+};
+skriv("lat viss sann", lengd_av([usann]));`,
+			from: token.Bokmal,
+			to:   token.English,
+			expected: `# dialekt: engelsk
+# Reknar ut lengda av lista
+let lengd_av   = fn(liste) {
+    return len(liste);   # lengd er innebygd
+};
+puts("lat viss sann", lengd_av([false]));`,
+		},
+		{
+			input:    "let add = fn(a, b) { a + b };\nputs(first(rest(push([1], add(1, 2)))));",
+			from:     token.English,
+			to:       token.Bokmal,
+			expected: "la add = funksjon(a, b) { a + b };\nskriv(første(resten(tilføy([1], add(1, 2)))));",
+		},
+		{
+			input:    "la liste = [1, 2];\nlengde(liste)",
+			from:     token.Bokmal,
+			to:       token.English,
+			expected: "# dialekt: engelsk\n\nlet liste = [1, 2];\nlen(liste)",
+		},
+		{
+			input:    "# dialekt: engelsk\nlet æ = \"blåbær\"; æ",
+			from:     token.Bokmal,
+			to:       token.Bokmal,
+			expected: "# dialekt: bokmål\nla æ = \"blåbær\"; æ",
+		},
+		{
+			input:    "let in = [1]; let i = 1; i in in",
+			from:     token.English,
+			to:       token.Bokmal,
+			expected: "la in = [1]; la i = 1; i i in",
+		},
+	}
+
+	for i, tt := range tests {
+		actual, errors := Translate(tt.input, tt.from, tt.to)
+		if len(errors) != 0 {
+			t.Errorf("tests[%d] - unexpected errors: %v", i, errors)
+			continue
+		}
+
+		if actual != tt.expected {
+			t.Errorf("tests[%d] - translation wrong:\nexpected %q\ngot      %q", i, tt.expected, actual)
+		}
+	}
+}
+
+func TestTranslateRoundTrip(t *testing.T) {
+	input := `la vers = funksjon(n) {
+  hvis (n > 0) {
+    skriv(streng(n) + " flasker");
+    vers(n - 1);
+  } ellers {
+    skriv(kutt([1, 2], 1));
+  }
+};`
+
+	for _, dialect := range token.Dialects {
+		translated, errors := Translate(input, token.Bokmal, dialect)
+		if len(errors) != 0 {
+			t.Fatalf("unexpected errors translating to %s: %v", dialect.Name, errors)
+		}
+
+		back, errors := Translate(translated, token.Bokmal, token.Bokmal)
+		if len(errors) != 0 {
+			t.Fatalf("unexpected errors translating back from %s: %v", dialect.Name, errors)
+		}
+
+		if dialect == token.Bokmal {
+			continue
+		}
+
+		expected := "# dialekt: bokmål\n\n" + input
+		if back != expected {
+			t.Errorf("round trip through %s wrong:\nexpected %q\ngot      %q", dialect.Name, expected, back)
+		}
+	}
+}
+
+func TestTranslateReservedNames(t *testing.T) {
+	input := "la fn = 1;\nla len = 2;\nfn + len"
+
+	_, errors := Translate(input, token.Bokmal, token.English)
+	if len(errors) != 4 {
+		t.Fatalf("wrong number of errors, expected 4, got %d: %v", len(errors), errors)
+	}
+
+	for _, err := range errors {
+		if err.Code != diagnostic.ReservedName {
+			t.Errorf("wrong error code, expected %s, got %s", diagnostic.ReservedName, err.Code)
+		}
+	}
+
+	if errors[1].Pos.Line != 2 || errors[1].Pos.Column != 4 {
+		t.Errorf("error position wrong, expected 2:4, got %d:%d", errors[1].Pos.Line, errors[1].Pos.Column)
+	}
+}
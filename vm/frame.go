@@ -0,0 +1,24 @@
+// vm/frame.go
+
+package vm
+
+import (
+	"github.com/solbero/pytonskript/code"
+	"github.com/solbero/pytonskript/object"
+)
+
+// Frame is a call of a closure. Its locals are on the stack from
+// basePointer.
+type Frame struct {
+	cl          *object.Closure
+	ip          int
+	basePointer int
+}
+
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
@@ -0,0 +1,852 @@
+// vm/vm.go
+
+// Package vm runs programs compiled to bytecode by the compiler package.
+//
+// Programs behave as they do in the evaluator.
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/solbero/pytonskript/code"
+	"github.com/solbero/pytonskript/compiler"
+	"github.com/solbero/pytonskript/diagnostic"
+	"github.com/solbero/pytonskript/evaluator"
+	"github.com/solbero/pytonskript/object"
+)
+
+// StackSize and MaxFrames are where the stack and the call frames start;
+// both grow as needed, so recursion is only limited by memory, as in the
+// evaluator.
+const (
+	StackSize   = 2048
+	GlobalsSize = 65536
+	MaxFrames   = 1024
+)
+
+// The VM uses the evaluator's singletons, since the builtins return them.
+var (
+	True  = evaluator.TRUE
+	False = evaluator.FALSE
+	Null  = evaluator.NULL
+)
+
+type VM struct {
+	constants   []object.Object
+	globals     []object.Object
+	globalNames []string
+
+	stack []object.Object
+	sp    int // Always points to the next value. Top of stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	result object.Object
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions, Positions: bytecode.Positions}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+
+	return &VM{
+		constants:   bytecode.Constants,
+		globals:     make([]object.Object, GlobalsSize),
+		globalNames: bytecode.Globals,
+
+		stack: make([]object.Object, StackSize),
+		sp:    0,
+
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+// NewWithGlobalsStore returns a VM that keeps its globals in s, so that
+// they outlive it, as the REPL needs.
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	vm := New(bytecode)
+	vm.globals = s
+	return vm
+}
+
+// Result returns the value the program ended with, which is nil if it did
+// not end with an expression or a return.
+func (vm *VM) Result() object.Object {
+	return vm.result
+}
+
+// Position returns the line and column in the source of the instruction
+// being run, such as the one that failed when Run returns an error. Both are
+// 0 if the bytecode does not say.
+func (vm *VM) Position() (line, column int) {
+	frame := vm.currentFrame()
+	if pos, ok := frame.cl.Fn.Positions.Lookup(frame.ip); ok {
+		return pos.Line, pos.Column
+	}
+	return 0, 0
+}
+
+// Run runs the program. Errors in the program, such as adding a number to
+// a boolean, are returned as *object.Error.
+func (vm *VM) Run() error {
+	return vm.run(1)
+}
+
+// run runs instructions until the frame at depth returns, so that a
+// builtin can call a function and get its value back.
+func (vm *VM) run(depth int) error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.push(vm.constants[constIndex])
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan:
+			if err := vm.executeBinaryOperation(op); err != nil {
+				return err
+			}
+
+		case code.OpIn:
+			collection := vm.pop()
+			element := vm.pop()
+			if err := vm.executeInOperation(element, collection); err != nil {
+				return err
+			}
+
+		case code.OpTrue:
+			vm.push(True)
+
+		case code.OpFalse:
+			vm.push(False)
+
+		case code.OpNull:
+			vm.push(Null)
+
+		case code.OpBang:
+			vm.executeBangOperator()
+
+		case code.OpMinus:
+			if err := vm.executeMinusOperator(); err != nil {
+				return err
+			}
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+
+		case code.OpJumpNull:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if vm.stack[vm.sp-1] == Null {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpJumpNotNull:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if vm.stack[vm.sp-1] != Null {
+				vm.currentFrame().ip = pos - 1
+			} else {
+				vm.pop()
+			}
+
+		case code.OpJumpUnset:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			kind := code.ReadUint8(ins[ip+3:])
+			index := int(code.ReadUint16(ins[ip+4:]))
+			vm.currentFrame().ip += 5
+
+			if !vm.isSet(kind, index) {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			condition := vm.pop()
+			if !isTruthy(condition) {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			value := vm.globals[globalIndex]
+			if value == nil {
+				return newError(diagnostic.IdentifierNotFound, vm.globalNames[globalIndex])
+			}
+			vm.push(value)
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			value := vm.stack[frame.basePointer+int(localIndex)]
+			if value == nil {
+				return newError(diagnostic.IdentifierNotFound, frame.cl.Fn.LocalNames[localIndex])
+			}
+			vm.push(value)
+
+		case code.OpNewCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+			slot := frame.basePointer + int(localIndex)
+			vm.stack[slot] = &object.Cell{Name: frame.cl.Fn.LocalNames[localIndex], Value: vm.stack[slot]}
+
+		case code.OpSetCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			cell := vm.stack[vm.currentFrame().basePointer+int(localIndex)].(*object.Cell)
+			cell.Value = vm.pop()
+
+		case code.OpGetCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			cell := vm.stack[vm.currentFrame().basePointer+int(localIndex)].(*object.Cell)
+			if cell.Value == nil {
+				return newError(diagnostic.IdentifierNotFound, cell.Name)
+			}
+			vm.push(cell.Value)
+
+		case code.OpLoadCell:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			vm.push(vm.stack[vm.currentFrame().basePointer+int(localIndex)])
+
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			cell := vm.currentFrame().cl.Free[freeIndex]
+			if cell.Value == nil {
+				return newError(diagnostic.IdentifierNotFound, cell.Name)
+			}
+			vm.push(cell.Value)
+
+		case code.OpLoadFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			vm.push(vm.currentFrame().cl.Free[freeIndex])
+
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			if int(builtinIndex) >= len(evaluator.BuiltinNames) {
+				return fmt.Errorf("unknown builtin %d", builtinIndex)
+			}
+			builtin, _ := evaluator.LookupBuiltin(evaluator.BuiltinNames[builtinIndex])
+			vm.push(builtin)
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			array := vm.buildArray(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+
+			vm.push(array)
+
+		case code.OpInterpolate:
+			numParts := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			var out strings.Builder
+			for _, part := range vm.stack[vm.sp-numParts : vm.sp] {
+				out.WriteString(part.Inspect())
+			}
+			vm.sp = vm.sp - numParts
+
+			vm.push(&object.String{Value: out.String()})
+
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			hash, err := vm.buildHash(vm.sp-numElements, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp = vm.sp - numElements
+
+			vm.push(hash)
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			if err := vm.executeIndexExpression(left, index); err != nil {
+				return err
+			}
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			if err := vm.executeCall(int(numArgs)); err != nil {
+				return err
+			}
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			if vm.framesIndex == 1 {
+				vm.result = returnValue
+				return nil
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			vm.push(returnValue)
+			if vm.framesIndex < depth {
+				return nil
+			}
+
+		case code.OpReturn:
+			if vm.framesIndex == 1 {
+				return nil
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			vm.push(Null)
+			if vm.framesIndex < depth {
+				return nil
+			}
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+
+			if err := vm.pushClosure(int(constIndex), int(numFree)); err != nil {
+				return err
+			}
+
+		default:
+			return fmt.Errorf("unknown opcode %d", op)
+		}
+	}
+
+	return nil
+}
+
+// isSet tells whether a variable of the kind OpJumpUnset gives has a value.
+func (vm *VM) isSet(kind uint8, index int) bool {
+	frame := vm.currentFrame()
+
+	switch kind {
+	case code.VariableGlobal:
+		return vm.globals[index] != nil
+	case code.VariableLocal:
+		return vm.stack[frame.basePointer+index] != nil
+	case code.VariableCell:
+		cell, ok := vm.stack[frame.basePointer+index].(*object.Cell)
+		return ok && cell.Value != nil
+	default:
+		return frame.cl.Free[index].Value != nil
+	}
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) {
+	if vm.framesIndex == len(vm.frames) {
+		vm.frames = append(vm.frames, f)
+	} else {
+		vm.frames[vm.framesIndex] = f
+	}
+	vm.framesIndex++
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+func (vm *VM) push(o object.Object) {
+	if vm.sp >= len(vm.stack) {
+		vm.grow(vm.sp + 1)
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+// grow makes room for at least size values on the stack.
+func (vm *VM) grow(size int) {
+	newSize := 2 * len(vm.stack)
+	for newSize < size {
+		newSize *= 2
+	}
+
+	stack := make([]object.Object, newSize)
+	copy(stack, vm.stack)
+	vm.stack = stack
+}
+
+func (vm *VM) executeCall(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return newError(diagnostic.NotAFunction, typeName(callee))
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
+	if numArgs != cl.Fn.NumParameters {
+		return newError(diagnostic.WrongArgumentCount, numArgs, cl.Fn.NumParameters)
+	}
+
+	frame := NewFrame(cl, vm.sp-numArgs)
+	vm.pushFrame(frame)
+
+	// Locals start out unset, so that names are looked up around the
+	// function until their la has run.
+	top := frame.basePointer + cl.Fn.NumLocals
+	if top > len(vm.stack) {
+		vm.grow(top)
+	}
+	for i := vm.sp; i < top; i++ {
+		vm.stack[i] = nil
+	}
+	vm.sp = top
+
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	args := make([]object.Object, numArgs)
+	copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+
+	result := builtin.Fn(vm, args...)
+	if err, ok := result.(*object.Error); ok {
+		return err
+	}
+	vm.sp = vm.sp - numArgs - 1
+
+	if result == nil {
+		result = Null
+	}
+	vm.push(result)
+
+	return nil
+}
+
+// Call calls fn with args for a builtin and returns its value. Errors are
+// returned as *object.Error.
+func (vm *VM) Call(fn object.Object, args ...object.Object) object.Object {
+	switch fn := fn.(type) {
+	case *object.Closure:
+		depth := vm.framesIndex + 1
+		vm.push(fn)
+		for _, arg := range args {
+			vm.push(arg)
+		}
+		err := vm.callClosure(fn, len(args))
+		if err == nil {
+			err = vm.run(depth)
+		}
+		if err != nil {
+			if err, ok := err.(*object.Error); ok {
+				return err
+			}
+			return &object.Error{Message: err.Error()}
+		}
+		return vm.pop()
+	case *object.Builtin:
+		return fn.Fn(vm, args...)
+	default:
+		return newError(diagnostic.NotAFunction, typeName(fn))
+	}
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) error {
+	function, ok := vm.constants[constIndex].(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", vm.constants[constIndex])
+	}
+
+	free := make([]*object.Cell, numFree)
+	for i := 0; i < numFree; i++ {
+		cell, ok := vm.stack[vm.sp-numFree+i].(*object.Cell)
+		if !ok {
+			return fmt.Errorf("not a cell: %+v", vm.stack[vm.sp-numFree+i])
+		}
+		free[i] = cell
+	}
+	vm.sp = vm.sp - numFree
+
+	vm.push(&object.Closure{Fn: function, Free: free})
+	return nil
+}
+
+func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
+	elements := make([]object.Object, endIndex-startIndex)
+
+	for i := startIndex; i < endIndex; i++ {
+		elements[i-startIndex] = vm.stack[i]
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
+	hash := &object.Hash{}
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := object.HashableKey(key)
+		if !ok {
+			return nil, newError(diagnostic.UnusableAsHashKey, typeName(key))
+		}
+
+		hash.Set(hashKey, value)
+	}
+
+	return hash, nil
+}
+
+var operators = map[code.Opcode]string{
+	code.OpAdd:         "+",
+	code.OpSub:         "-",
+	code.OpMul:         "*",
+	code.OpDiv:         "/",
+	code.OpEqual:       "==",
+	code.OpNotEqual:    "!=",
+	code.OpGreaterThan: ">",
+	code.OpLessThan:    "<",
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	leftType := left.Type()
+	rightType := right.Type()
+
+	switch {
+	case leftType == object.INTEGER_OBJ && rightType == object.INTEGER_OBJ:
+		result, err := executeIntegerOperation(op, left, right)
+		if err != nil {
+			return err
+		}
+		vm.push(result)
+	case isNumber(left) && isNumber(right):
+		result, err := executeFloatOperation(op, left, right)
+		if err != nil {
+			return err
+		}
+		vm.push(result)
+	case leftType == object.STRING_OBJ && rightType == object.STRING_OBJ:
+		result, ok := executeStringOperation(op, left, right)
+		if !ok {
+			return newError(diagnostic.UnknownInfixOperator, typeName(left), operators[op], typeName(right))
+		}
+		vm.push(result)
+	case op == code.OpEqual:
+		vm.push(nativeBoolToBooleanObject(object.Equal(left, right)))
+	case op == code.OpNotEqual:
+		vm.push(nativeBoolToBooleanObject(!object.Equal(left, right)))
+	case leftType != rightType:
+		return newError(diagnostic.TypeMismatch, typeName(left), operators[op], typeName(right))
+	default:
+		return newError(diagnostic.UnknownInfixOperator, typeName(left), operators[op], typeName(right))
+	}
+
+	return nil
+}
+
+// executeInOperation pushes whether element is a key in a hash, an element
+// of a list or a part of a string.
+func (vm *VM) executeInOperation(element, collection object.Object) error {
+	switch collection := collection.(type) {
+	case *object.Hash:
+		key, ok := object.HashableKey(element)
+		if !ok {
+			return newError(diagnostic.UnusableAsHashKey, typeName(element))
+		}
+		_, ok = collection.Get(key)
+		vm.push(nativeBoolToBooleanObject(ok))
+	case *object.Array:
+		found := false
+		for _, e := range collection.Elements {
+			if object.Equal(e, element) {
+				found = true
+				break
+			}
+		}
+		vm.push(nativeBoolToBooleanObject(found))
+	case *object.String:
+		str, ok := element.(*object.String)
+		if !ok {
+			return newError(diagnostic.TypeMismatch, typeName(element), "i", typeName(collection))
+		}
+		vm.push(nativeBoolToBooleanObject(strings.Contains(collection.Value, str.Value)))
+	default:
+		return newError(diagnostic.UnknownInfixOperator, typeName(element), "i", typeName(collection))
+	}
+
+	return nil
+}
+
+func executeIntegerOperation(op code.Opcode, left, right object.Object) (object.Object, error) {
+	leftValue := left.(*object.Integer).Value
+	rightValue := right.(*object.Integer).Value
+
+	switch op {
+	case code.OpAdd:
+		return &object.Integer{Value: leftValue + rightValue}, nil
+	case code.OpSub:
+		return &object.Integer{Value: leftValue - rightValue}, nil
+	case code.OpMul:
+		return &object.Integer{Value: leftValue * rightValue}, nil
+	case code.OpDiv:
+		if rightValue == 0 {
+			return nil, newError(diagnostic.DivisionByZero)
+		}
+		return &object.Integer{Value: leftValue / rightValue}, nil
+	case code.OpEqual:
+		return nativeBoolToBooleanObject(leftValue == rightValue), nil
+	case code.OpNotEqual:
+		return nativeBoolToBooleanObject(leftValue != rightValue), nil
+	case code.OpGreaterThan:
+		return nativeBoolToBooleanObject(leftValue > rightValue), nil
+	case code.OpLessThan:
+		return nativeBoolToBooleanObject(leftValue < rightValue), nil
+	default:
+		return nil, newError(diagnostic.UnknownInfixOperator, typeName(left), operators[op], typeName(right))
+	}
+}
+
+// executeFloatOperation runs an operator on two numbers where at least one
+// is a float. The other is turned into a float first.
+func executeFloatOperation(op code.Opcode, left, right object.Object) (object.Object, error) {
+	leftValue, _ := object.AsFloat(left)
+	rightValue, _ := object.AsFloat(right)
+
+	switch op {
+	case code.OpAdd:
+		return &object.Float{Value: leftValue + rightValue}, nil
+	case code.OpSub:
+		return &object.Float{Value: leftValue - rightValue}, nil
+	case code.OpMul:
+		return &object.Float{Value: leftValue * rightValue}, nil
+	case code.OpDiv:
+		if rightValue == 0 {
+			return nil, newError(diagnostic.DivisionByZero)
+		}
+		return &object.Float{Value: leftValue / rightValue}, nil
+	case code.OpEqual:
+		return nativeBoolToBooleanObject(leftValue == rightValue), nil
+	case code.OpNotEqual:
+		return nativeBoolToBooleanObject(leftValue != rightValue), nil
+	case code.OpGreaterThan:
+		return nativeBoolToBooleanObject(leftValue > rightValue), nil
+	case code.OpLessThan:
+		return nativeBoolToBooleanObject(leftValue < rightValue), nil
+	default:
+		return nil, newError(diagnostic.UnknownInfixOperator, typeName(left), operators[op], typeName(right))
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	_, ok := object.AsFloat(obj)
+	return ok
+}
+
+func executeStringOperation(op code.Opcode, left, right object.Object) (object.Object, bool) {
+	leftValue := left.(*object.String).Value
+	rightValue := right.(*object.String).Value
+
+	switch op {
+	case code.OpAdd:
+		return &object.String{Value: leftValue + rightValue}, true
+	case code.OpEqual:
+		return nativeBoolToBooleanObject(leftValue == rightValue), true
+	case code.OpNotEqual:
+		return nativeBoolToBooleanObject(leftValue != rightValue), true
+	default:
+		return nil, false
+	}
+}
+
+func (vm *VM) executeBangOperator() {
+	operand := vm.pop()
+
+	switch operand {
+	case True:
+		vm.push(False)
+	case False:
+		vm.push(True)
+	case Null:
+		vm.push(True)
+	default:
+		vm.push(False)
+	}
+}
+
+func (vm *VM) executeMinusOperator() error {
+	operand := vm.pop()
+
+	switch operand := operand.(type) {
+	case *object.Integer:
+		vm.push(&object.Integer{Value: -operand.Value})
+	case *object.Float:
+		vm.push(&object.Float{Value: -operand.Value})
+	default:
+		return newError(diagnostic.UnknownPrefixOperator, "-", typeName(operand))
+	}
+	return nil
+}
+
+func (vm *VM) executeIndexExpression(left, index object.Object) error {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		vm.executeArrayIndex(left, index)
+		return nil
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		vm.executeStringIndex(left, index)
+		return nil
+	case left.Type() == object.HASH_OBJ:
+		return vm.executeHashIndex(left, index)
+	default:
+		return newError(diagnostic.IndexNotSupported, typeName(left))
+	}
+}
+
+func (vm *VM) executeArrayIndex(array, index object.Object) {
+	arrayObject := array.(*object.Array)
+	i := index.(*object.Integer).Value
+	if i < 0 {
+		i += int64(len(arrayObject.Elements))
+	}
+
+	if i < 0 || i >= int64(len(arrayObject.Elements)) {
+		vm.push(Null)
+		return
+	}
+
+	vm.push(arrayObject.Elements[i])
+}
+
+func (vm *VM) executeStringIndex(str, index object.Object) {
+	chars := []rune(str.(*object.String).Value)
+	i := index.(*object.Integer).Value
+	if i < 0 {
+		i += int64(len(chars))
+	}
+
+	if i < 0 || i >= int64(len(chars)) {
+		vm.push(Null)
+		return
+	}
+
+	vm.push(&object.String{Value: string(chars[i])})
+}
+
+func (vm *VM) executeHashIndex(hash, index object.Object) error {
+	hashObject := hash.(*object.Hash)
+
+	key, ok := object.HashableKey(index)
+	if !ok {
+		return newError(diagnostic.UnusableAsHashKey, typeName(index))
+	}
+
+	value, ok := hashObject.Get(key)
+	if !ok {
+		vm.push(Null)
+		return nil
+	}
+
+	vm.push(value)
+	return nil
+}
+
+func newError(code diagnostic.Code, a ...interface{}) *object.Error {
+	return &object.Error{Code: code, Message: diagnostic.Sprintf(code, a...)}
+}
+
+func typeName(obj object.Object) string {
+	return diagnostic.TypeName(string(obj.Type()))
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return True
+	}
+	return False
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case Null:
+		return false
+	case False:
+		return false
+	default:
+		return true
+	}
+}
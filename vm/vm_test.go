@@ -0,0 +1,510 @@
+// vm/vm_test.go
+
+package vm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/solbero/pytonskript/ast"
+	"github.com/solbero/pytonskript/compiler"
+	"github.com/solbero/pytonskript/diagnostic"
+	"github.com/solbero/pytonskript/evaluator"
+	"github.com/solbero/pytonskript/lexer"
+	"github.com/solbero/pytonskript/object"
+	"github.com/solbero/pytonskript/parser"
+	"github.com/solbero/pytonskript/token"
+)
+
+// semanticsTests are run by both the evaluator and the VM, which should
+// agree on every one. expected is the Inspect of the result, "" if there is
+// none.
+var semanticsTests = []struct {
+	input    string
+	expected string
+}{
+	// Integers and booleans
+	{"5", "5"},
+	{"-5", "-5"},
+	{"5 + 5 + 5 + 5 - 10", "10"},
+	{"2 * (5 + 10) / 3", "10"},
+	{"-50 + 100 + -50", "0"},
+	{"1 < 2", "sant"},
+	{"1 > 2", "falskt"},
+	{"1 == 1", "sant"},
+	{"1 != 1", "falskt"},
+	{"sant == sant", "sant"},
+	{"sant != falskt", "sant"},
+	{"(1 < 2) == sant", "sant"},
+	{"!sant", "falskt"},
+	{"!!5", "sant"},
+	{"!0", "falskt"},
+	{"!(hvis (falskt) { 5 })", "sant"},
+
+	// Strings
+	{`"pyton"`, "pyton"},
+	{`"py" + "ton"`, "pyton"},
+	{`"a" == "a"`, "sant"},
+	{`"a" != "b"`, "sant"},
+	{`"blåbær"[2]`, "å"},
+	{`"blåbær"[-1]`, "r"},
+	{`"blåbær"[-6]`, "b"},
+	{`"blåbær"[6]`, "ingenting"},
+	{`"blåbær"[-7]`, "ingenting"},
+	{`""[0]`, "ingenting"},
+	{`la s = "æøå"; s[0] + s[1] + s[2] == s`, "sant"},
+
+	// Conditionals
+	{"hvis (sant) { 10 }", "10"},
+	{"hvis (1) { 10 }", "10"},
+	{"hvis (falskt) { 10 }", "ingenting"},
+	{"hvis (1 > 2) { 10 } ellers { 20 }", "20"},
+	{"hvis (hvis (falskt) { 10 }) { 10 } ellers { 20 }", "20"},
+
+	// Statements and returns
+	{"la x = 5;", ""},
+	{"la x = 5; x", "5"},
+	{"la x = 5; la x = x * 2; x", "10"},
+	{"la a = 5; la b = a; la c = a + b + 5; c", "15"},
+	{"returner 10; 9", "10"},
+	{"9; returner 2 * 5; 9", "10"},
+	{"hvis (10 > 1) { hvis (10 > 1) { returner 10; } returner 1; }", "10"},
+	{"hvis (sant) { la y = 1; }; 2", "2"},
+
+	// Arrays and hashes
+	{"[1, 2 * 2, 3 + 3]", "[1, 4, 6]"},
+	{"[]", "[]"},
+	{"[1, 2, 3][0]", "1"},
+	{"la i = 0; [1][i]", "1"},
+	{"la i = 2; la f = funksjon(i) { i i [i] }; [f(i), i i [1]]", "[sant, falskt]"},
+	{"[1, 2, 3][3]", "ingenting"},
+	{"[1, 2, 3][-1]", "3"},
+	{"[1, 2, 3][-4]", "ingenting"},
+	{`{"a": 1 + 1}`, "{a: 2}"},
+	{`{"a": 5}["a"]`, "5"},
+	{`{"a": 5}["b"]`, "ingenting"},
+	{`{1: 5}[1]`, "5"},
+	{`{sant: 5}[sant]`, "5"},
+	{`{}`, "{}"},
+	{`{"b": 1, "a": 2, 3: 3, sant: 4}`, "{b: 1, a: 2, 3: 3, sant: 4}"},
+	{`{"b": 1, "a": 2, "b": 3}`, "{b: 3, a: 2}"},
+	{"[1, 2] == [1, 2]", "sant"},
+	{"[1, 2] == [2, 1]", "falskt"},
+	{"[1, [2, 3]] != [1, [2, 3]]", "falskt"},
+	{"[1] == [1, 1]", "falskt"},
+	{`[] == {}`, "falskt"},
+	{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, "sant"},
+	{`{"a": 1} == {"a": 2}`, "falskt"},
+	{`{"a": 1} == {"b": 1}`, "falskt"},
+	{"la f = funksjon() { 1 }; [f] == [f]", "sant"},
+	{"[funksjon() { 1 }] == [funksjon() { 1 }]", "falskt"},
+	{`{[1, 2]: "a", [1, [2]]: "b"}[[1, 2]]`, "a"},
+	{`{[1, 2]: "a", [1, [2]]: "b"}[[1, [2]]]`, "b"},
+	{`{[1, 2]: "a"}[[2, 1]]`, "ingenting"},
+	{`{[]: "a"}[[]]`, "a"},
+	{`{[1]: "a", [1]: "b"}`, "{[1]: b}"},
+	{"{x: 1, y: 2}", "FEIL: navnet er ikke definert: x [K001]"},
+	{"{1: x, y: 2}", "FEIL: navnet er ikke definert: x [K001]"},
+
+	// Functions and closures
+	{"la f = funksjon() { 5 + 10 }; f()", "15"},
+	{"la f = funksjon() { 5; }; f()", "5"},
+	{"la f = funksjon() { returner 99; 100 }; f()", "99"},
+	{"la identity = funksjon(x) { x; }; identity(5);", "5"},
+	{"la add = funksjon(x, y) { x + y; }; add(5 + 5, add(5, 5));", "20"},
+	{"funksjon(x) { x; }(5)", "5"},
+	{"la f = funksjon(x) { x + 2 }; f", "fn(x) {\n(x + 2)\n}"},
+	{"la newAdder = funksjon(x) { funksjon(y) { x + y }; }; la addTwo = newAdder(2); addTwo(2);", "4"},
+	{"la a = funksjon(x) { funksjon(y) { funksjon(z) { x + y + z } } }; a(1)(2)(3)", "6"},
+	{"la global = 10; la f = funksjon() { la local = 1; funksjon() { global + local } }; f()()", "11"},
+	{"la f = funksjon(a) { la b = a * 2; la g = funksjon() { a + b }; g() }; f(2)", "6"},
+	{"la f = funksjon() { la x = 1; la g = funksjon() { x }; la x = 2; g() }; f()", "2"},
+	{"la f = funksjon() { la g = funksjon() { h() }; la h = funksjon() { 7 }; g() }; f()", "7"},
+	{"la f = funksjon() { x }; la x = 3; f()", "3"},
+	{"la f = funksjon(x) { x }; la g = f; f == g", "sant"},
+
+	// Names used before their la
+	{"la x = 1; la f = funksjon() { la y = x; la x = 2; y }; f()", "1"},
+	{"la x = 1; la f = funksjon() { la y = x; la x = 2; [y, x] }; f()", "[1, 2]"},
+	{"la f = funksjon(c) { hvis (c) { la lengde = 5 }; lengde }; [f(sant), f(falskt)([1, 2])]", "[5, 2]"},
+	{"la x = 1; la f = funksjon() { la g = funksjon() { x }; la a = g(); la x = 3; [a, g()] }; f()", "[1, 3]"},
+	{"la f = funksjon(x) { la g = funksjon() { la y = x; la x = 9; [y, x] }; g() }; f(7)", "[7, 9]"},
+	{"la n = lengde([1]); la lengde = 4; [n, lengde]", "[1, 4]"},
+	{"la f = funksjon() { la y = x; la x = 2; y }; f()", "FEIL: navnet er ikke definert: x [K001]"},
+	{"funksjon(x) { x } == funksjon(x) { x }", "falskt"},
+
+	// Recursion
+	{`la countDown = funksjon(x) { hvis (x == 0) { returner 0; } ellers { countDown(x - 1); } }; countDown(1);`, "0"},
+	{`la fib = funksjon(n) { hvis (n < 2) { n } ellers { fib(n - 1) + fib(n - 2) } }; fib(15)`, "610"},
+	{`la f = funksjon() { la g = funksjon(n) { hvis (n == 0) { 0 } ellers { g(n - 1) } }; g(3) }; f()`, "0"},
+	{`la deep = funksjon(n) { hvis (n == 0) { 0 } ellers { 1 + deep(n - 1) } }; deep(5000)`, "5000"},
+
+	// Builtins
+	{`lengde("")`, "0"},
+	{`lengde([1, 2, 3])`, "3"},
+	{`første([1, 2])`, "1"},
+	{`siste([])`, "ingenting"},
+	{`resten([1, 2, 3])`, "[2, 3]"},
+	{`tilføy([], 1)`, "[1]"},
+	{`kutt([1, 2, 3], 1)`, "[2, 3]"},
+	{`lengde("blåbær")`, "6"},
+	{`kutt("blåbærsyltetøy", 3, 6)`, "bær"},
+	{`kutt("blåbær", 3)`, "bær"},
+	{`kutt("blåbær", -2)`, "ær"},
+	{`kutt([1, 2, 3], -2, -1)`, "[2]"},
+	{`sett_sammen(del("blå,bær", ","), " og ")`, "blå og bær"},
+	{`store_bokstaver(fyll_venstre("å", 3, "ø"))`, "ØØÅ"},
+	{`finn("blåbær", "bær")`, "3"},
+	{`gjenta("ha", -1)`, "FEIL: antallet til 'gjenta' kan ikke være negativt, fikk -1 [K017]"},
+	{`gjenta("ha", 100000000)`, "FEIL: strengen fra 'gjenta' blir for lang, grensen er 67108864 byte [K026]"},
+	{`streng(12)`, "12"},
+	{"tilordne([1, 2, 3], funksjon(x) { x * 2 })", "[2, 4, 6]"},
+	{"la n = 10; tilordne([1, 2], funksjon(x) { x + n })", "[11, 12]"},
+	{"la f = funksjon(a) { tilordne(a, funksjon(x) { reduser(x, 0, funksjon(s, y) { s + y }) }) }; f([[1, 2], [3]])", "[3, 3]"},
+	{"tilordne([[1], [2, 3]], lengde)", "[1, 2]"},
+	{"filtrer([1, 2, 3, 4], funksjon(x) { x > 2 })", "[3, 4]"},
+	{`sorter(["ål", "øl", "ærfugl", "and"])`, "[and, ærfugl, øl, ål]"},
+	{"sorter([3, 1, 2], funksjon(a, b) { a > b })", "[3, 2, 1]"},
+	{"finn([1, 5, 7], funksjon(x) { x > 4 })", "5"},
+	{"alle([1, 2], funksjon(x) { x > 0 })", "sant"},
+	{"noen([1, 2], funksjon(x) { returner x < 0; })", "falskt"},
+	{"tilordne([1], funksjon(x) { x + sant })", "FEIL: typene passer ikke sammen: heltall + sannhetsverdi [K002]"},
+	{"tilordne([1], funksjon() { 1 })", "FEIL: feil antall argumenter, fikk 1, forventet 0 [K008]"},
+	{"la r = tilordne([1, 2], funksjon(x) { x }); lengde(r) + 1", "3"},
+	{`nøkler({"b": 1, "a": 2})`, "[b, a]"},
+	{`par({"a": 1})`, "[[a, 1]]"},
+	{`har_nøkkel({"a": første([])}, "a")`, "sant"},
+	{`slå_sammen(fjern({"a": 1, "b": 2}, "a"), {"c": 3})`, "{b: 2, c: 3}"},
+	{`formater("%-4s|%3d|%.1f", "a", 7, 2)`, "a   |  7|2.0"},
+	{`formater("%d %d", 1)`, "FEIL: malen har 2 plassholdere, men fikk 1 verdier [K019]"},
+	{`skrivevalg({"skille": ", "})`, `skrivevalg({"skille": ", ", "slutt": "\n"})`},
+	{`skrivevalg({"farge": "rød"})`, "FEIL: ukjent valg til 'skrivevalg': farge [K022]"},
+
+	// Interpolation
+	{`la navn = "Kari"; "Hei {navn}, du er {40 + 2} år"`, "Hei Kari, du er 42 år"},
+	{`la f = funksjon(x) { "<{x}>" }; "{f([1, sant])}{f(første([]))}"`, "<[1, sant]><ingenting>"},
+	{`"{"{"{1}"}"}"`, "1"},
+	{`"\{{1}\}"`, "{1}"},
+	{`"a{1 + sant}"`, "FEIL: typene passer ikke sammen: heltall + sannhetsverdi [K002]"},
+
+	// ingenting
+	{"ingenting", "ingenting"},
+	{"ingenting == første([])", "sant"},
+	{"er_ingenting(første([])) == !er_ingenting(0)", "sant"},
+	{"første([]) ?? 5", "5"},
+
+	// type and conversions
+	{`[type(1), type(desimal(1)), type([]), type(lengde)]`, "[heltall, desimaltall, liste, innebygd funksjon]"},
+	{`heltall("12") + heltall(desimal("2,5"))`, "14"},
+	{`heltall("tolv")`, `FEIL: kan ikke gjøre "tolv" om til heltall [K024]`},
+	{`desimal("0,5") + 1 == desimal(3) / 2`, "sant"},
+	{"-desimal(1) > 0", "falskt"},
+	{`[liste("ab"), sannhetsverdi(ingenting), er_tall(desimal(1)), er_funksjon(er_tall)]`, "[[a, b], falskt, sant, sant]"},
+	{"falskt ?? 5", "falskt"},
+	{"la f = funksjon() { ingenting }; f() ?? f() ?? [1, 2][5] ?? 3", "3"},
+	{"1 ?? [1][1 + sant]", "1"},
+	{`la t = {"a": {"b": 1}}; [t["a"]?["b"], t["x"]?["b"], t["x"]?["b"]?[0]]`, "[1, ingenting, ingenting]"},
+	{`første([])?[1 + sant]`, "ingenting"},
+	{`{"a": 1}?[funksjon() {}]`, "FEIL: kan ikke brukes som nøkkel i en tabell: funksjon [K005]"},
+	{`ingenting[0]`, "FEIL: kan ikke indeksere ingenting [K006]"},
+
+	// i
+	{`"a" i {"a": 1}`, "sant"},
+	{"[1] i {[2]: 1}", "falskt"},
+	{"la f = funksjon(x, l) { x i l }; f(3, [1, 2, 3])", "sant"},
+	{`"bær" i "blåbær"`, "sant"},
+	{"1 + 2 i [3] == sant", "sant"},
+	{`1 i "1"`, "FEIL: typene passer ikke sammen: heltall i streng [K002]"},
+	{"1 i 1", "FEIL: ukjent operator: heltall i heltall [K004]"},
+	{"[funksjon(x) { x }] i {}", "FEIL: kan ikke brukes som nøkkel i en tabell: liste [K005]"},
+	{`la l = lengde; l("abc")`, "3"},
+	{`la lengde = funksjon(x) { 42 }; lengde("a")`, "42"},
+	{`skriv == skriv`, "sant"},
+
+	// Errors
+	{"1 / 0", "FEIL: kan ikke dele på null [K025]"},
+	{"la f = funksjon(x) { 10 / x }; [f(5), f(0)]", "FEIL: kan ikke dele på null [K025]"},
+	{`1 / desimal("0")`, "FEIL: kan ikke dele på null [K025]"},
+	{`desimal("1,5") / 0`, "FEIL: kan ikke dele på null [K025]"},
+	{`desimal(1) / desimal("-0,0")`, "FEIL: kan ikke dele på null [K025]"},
+	{"5 + sant;", "FEIL: typene passer ikke sammen: heltall + sannhetsverdi [K002]"},
+	{"5 + sant; 5;", "FEIL: typene passer ikke sammen: heltall + sannhetsverdi [K002]"},
+	{"sant < 1", "FEIL: typene passer ikke sammen: sannhetsverdi < heltall [K002]"},
+	{"-sant", "FEIL: ukjent operator: -sannhetsverdi [K003]"},
+	{"sant + falskt;", "FEIL: ukjent operator: sannhetsverdi + sannhetsverdi [K004]"},
+	{"hvis (10 > 1) { hvis (10 > 1) { returner sant + falskt; } returner 1 }", "FEIL: ukjent operator: sannhetsverdi + sannhetsverdi [K004]"},
+	{`"Hello" - "World!"`, "FEIL: ukjent operator: streng - streng [K004]"},
+	{"foobar", "FEIL: navnet er ikke definert: foobar [K001]"},
+	{"hvis (falskt) { foobar }; 1", "1"},
+	{"la f = funksjon() { foobar }; f()", "FEIL: navnet er ikke definert: foobar [K001]"},
+	{"la f = funksjon() { la y = x; la x = 1; y }; f()", "FEIL: navnet er ikke definert: x [K001]"},
+	{"la f = funksjon() { la g = funksjon() { x }; la y = g(); la x = 1; y }; f()", "FEIL: navnet er ikke definert: x [K001]"},
+	{`{"name": "Monkey"}[funksjon(x) { x }];`, "FEIL: kan ikke brukes som nøkkel i en tabell: funksjon [K005]"},
+	{`{[1, funksjon() { 1 }]: 2}`, "FEIL: kan ikke brukes som nøkkel i en tabell: liste [K005]"},
+	{`{1: 2}[[funksjon() { 1 }]]`, "FEIL: kan ikke brukes som nøkkel i en tabell: liste [K005]"},
+	{"1[0]", "FEIL: kan ikke indeksere heltall [K006]"},
+	{"[1][sant]", "FEIL: kan ikke indeksere liste [K006]"},
+	{`"a"["a"]`, "FEIL: kan ikke indeksere streng [K006]"},
+	{"1()", "FEIL: ikke en funksjon: heltall [K007]"},
+	{"la f = funksjon(x, y) { x }; f(1)", "FEIL: feil antall argumenter, fikk 1, forventet 2 [K008]"},
+	{"la f = funksjon(x) { x }; f(1, 2)", "FEIL: feil antall argumenter, fikk 2, forventet 1 [K008]"},
+	{`lengde(1)`, "FEIL: argumentet til 'lengde' støttes ikke, fikk heltall [K011]"},
+	{`lengde()`, "FEIL: feil antall argumenter, fikk 0, forventet 1 [K008]"},
+	{`første(1); 2`, "FEIL: argumentet til 'første' må være liste, fikk heltall [K012]"},
+	{`sitat(1 + 2)`, "QUOTE((1 + 2))"},
+	{`la f = funksjon() { sitat(x * [1, "a"]) }; f()`, `QUOTE((x * [1, a]))`},
+	{`type(sitat(1))`, "sitat"},
+}
+
+func parse(t testing.TB, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors in %q: %v", input, p.Errors())
+	}
+	return program
+}
+
+func runEvaluator(t testing.TB, input string) object.Object {
+	env := object.NewEnvironment()
+	return evaluator.Eval(parse(t, input), env)
+}
+
+func runVM(t testing.TB, input string) object.Object {
+	t.Helper()
+
+	comp := compiler.New(token.Bokmal)
+	if err := comp.Compile(parse(t, input)); err != nil {
+		t.Fatalf("compiler error in %q: %s", input, err)
+	}
+
+	vm := New(comp.Bytecode())
+	if err := vm.Run(); err != nil {
+		errObj, ok := err.(*object.Error)
+		if !ok {
+			t.Fatalf("vm error in %q: %s", input, err)
+		}
+		return errObj
+	}
+	return vm.Result()
+}
+
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return ""
+	}
+	return obj.Inspect()
+}
+
+func TestSameAsEvaluator(t *testing.T) {
+	for _, tt := range semanticsTests {
+		if got := inspect(runEvaluator(t, tt.input)); got != tt.expected {
+			t.Errorf("evaluator: %q gave %q, expected %q", tt.input, got, tt.expected)
+		}
+
+		if got := inspect(runVM(t, tt.input)); got != tt.expected {
+			t.Errorf("vm: %q gave %q, expected %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	for _, tt := range semanticsTests {
+		evaluated, ok := runEvaluator(t, tt.input).(*object.Error)
+		if !ok {
+			continue
+		}
+
+		if err, ok := runVM(t, tt.input).(*object.Error); !ok || err.Code != evaluated.Code {
+			t.Errorf("vm: %q should fail with %s, got %v", tt.input, evaluated.Code, err)
+		}
+	}
+}
+
+func TestDialect(t *testing.T) {
+	p := parser.New(lexer.NewWithDialect("let f = fn(x) { len(x) }; f([1, 2])", token.English))
+	program := p.ParseProgram()
+
+	comp := compiler.New(token.English)
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	if inspect(vm.Result()) != "2" {
+		t.Errorf("wrong result, expected 2, got %q", inspect(vm.Result()))
+	}
+}
+
+func TestMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`la unless = makro(cond, a, b) { sitat(hvis (!(avsitat(cond))) { avsitat(a) } ellers { avsitat(b) }) }; unless(1 > 2, 10, 20)`, "10"},
+		{`la twice = makro(x) { sitat(avsitat(x) * 2) }; la f = funksjon(y) { twice(y + 1) }; f(4)`, "10"},
+		{`la konst = makro() { sitat(avsitat(6 * 7)) }; konst()`, "42"},
+	}
+
+	for _, tt := range tests {
+		program, err := evaluator.ExpandProgram(parse(t, tt.input), token.Bokmal)
+		if err != nil {
+			t.Fatalf("error expanding %q: %s", tt.input, err)
+		}
+
+		comp := compiler.New(token.Bokmal)
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("compiler error in %q: %s", tt.input, err)
+		}
+
+		vm := New(comp.Bytecode())
+		if err := vm.Run(); err != nil {
+			t.Fatalf("vm error in %q: %s", tt.input, err)
+		}
+
+		if got := inspect(vm.Result()); got != tt.expected {
+			t.Errorf("%q gave %q, expected %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestQuoteErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected diagnostic.Code
+	}{
+		{"sitat(1, 2)", diagnostic.QuoteArgumentCount},
+		{"sitat(1 + avsitat(2))", diagnostic.UnquoteNotCompiled},
+		{"funksjon() { sitat([avsitat(1)]) }", diagnostic.UnquoteNotCompiled},
+	}
+
+	for _, tt := range tests {
+		err := compiler.New(token.Bokmal).Compile(parse(t, tt.input))
+		if d, ok := err.(*diagnostic.Diagnostic); !ok || d.Code != tt.expected {
+			t.Errorf("%q should fail with %s, got %v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestProgramTooLarge(t *testing.T) {
+	var elements []string
+	for i := 0; i < 70000; i++ {
+		elements = append(elements, fmt.Sprintf("%q", fmt.Sprint(i)))
+	}
+	input := "[" + strings.Join(elements, ", ") + "]"
+
+	if got := inspect(runEvaluator(t, "lengde("+input+")")); got != "70000" {
+		t.Fatalf("evaluator: wrong length, got %q", got)
+	}
+
+	err := compiler.New(token.Bokmal).Compile(parse(t, input))
+	d, ok := err.(*diagnostic.Diagnostic)
+	if !ok || d.Code != diagnostic.OperandTooLarge {
+		t.Errorf("expected %s compiling 70000 constants, got %v", diagnostic.OperandTooLarge, err)
+	}
+
+	input = "[" + strings.Repeat("1, ", 65534) + "1]"
+	if got := runVM(t, "lengde("+input+")"); inspect(got) != "65535" {
+		t.Errorf("vm: wrong length of the largest array, got %q", inspect(got))
+	}
+}
+
+func TestGlobalsStore(t *testing.T) {
+	globals := make([]object.Object, GlobalsSize)
+	symbolTable := compiler.NewSymbolTable()
+	constants := []object.Object{}
+
+	lines := []struct {
+		input    string
+		expected string
+	}{
+		{"la f = funksjon() { x }", ""},
+		{"f()", "FEIL: navnet er ikke definert: x [K001]"},
+		{"la x = 2", ""},
+		{"f() + x", "4"},
+	}
+
+	for _, line := range lines {
+		comp := compiler.NewWithState(token.Bokmal, symbolTable, constants)
+		if err := comp.Compile(parse(t, line.input)); err != nil {
+			t.Fatalf("compiler error in %q: %s", line.input, err)
+		}
+		bytecode := comp.Bytecode()
+		constants = bytecode.Constants
+
+		vm := NewWithGlobalsStore(bytecode, globals)
+		var got string
+		if err := vm.Run(); err != nil {
+			got = err.(*object.Error).Inspect()
+		} else {
+			got = inspect(vm.Result())
+		}
+
+		if got != line.expected {
+			t.Errorf("%q gave %q, expected %q", line.input, got, line.expected)
+		}
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	input := `la f = funksjon(x) {
+  x + sant
+};
+f(1)`
+
+	comp := compiler.New(token.Bokmal)
+	if err := comp.Compile(parse(t, input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vm := New(comp.Bytecode())
+	if err := vm.Run(); err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	if line, column := vm.Position(); line != 2 || column != 5 {
+		t.Errorf("wrong position, expected 2:5, got %d:%d", line, column)
+	}
+}
+
+const fibonacci = `
+la fibonacci = funksjon(x) {
+  hvis (x < 2) {
+    x
+  } ellers {
+    fibonacci(x - 1) + fibonacci(x - 2)
+  }
+};
+fibonacci(20);`
+
+func BenchmarkFibonacci(b *testing.B) {
+	program := parse(b, fibonacci)
+
+	b.Run("evaluator", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			evaluator.Eval(program, object.NewEnvironment())
+		}
+	})
+
+	b.Run("vm", func(b *testing.B) {
+		comp := compiler.New(token.Bokmal)
+		if err := comp.Compile(program); err != nil {
+			b.Fatalf("compiler error: %s", err)
+		}
+		bytecode := comp.Bytecode()
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			vm := New(bytecode)
+			if err := vm.Run(); err != nil {
+				b.Fatalf("vm error: %s", err)
+			}
+		}
+	})
+}